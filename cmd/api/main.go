@@ -1,27 +1,183 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/config"
 	"github.com/nicest414/ogiri-server/internal/data"
 	"github.com/nicest414/ogiri-server/internal/handlers"
+	"github.com/nicest414/ogiri-server/internal/httputil"
+	"github.com/nicest414/ogiri-server/internal/logging"
+	"github.com/nicest414/ogiri-server/internal/metrics"
+	"github.com/nicest414/ogiri-server/internal/moderation"
+	"github.com/nicest414/ogiri-server/internal/ratelimit"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"github.com/nicest414/ogiri-server/internal/scheduler"
 )
 
-const (
-	defaultPort = "8080"
-	dataFile    = "ogiri_data.json"  // JSONファイル名
-)
+// newStore は設定に応じたデータストアを初期化する
+func newStore(cfg *config.Config) (data.DataStore, string, error) {
+	if cfg.Store != config.StoreMemory {
+		// データファイルのディレクトリが無ければ作る
+		if err := os.MkdirAll(filepath.Dir(cfg.DataPath), 0o755); err != nil {
+			return nil, "", err
+		}
+	}
+	switch cfg.Store {
+	case config.StoreMemory:
+		return data.NewInMemoryStore(), "メモリ内（再起動するとデータは消えます）", nil
+	case config.StoreJSON:
+		store, err := data.NewJSONStore(cfg.DataPath)
+		if err != nil {
+			return nil, "", err
+		}
+		return store, "JSONファイル (" + cfg.DataPath + ")", nil
+	case config.StoreSQLite:
+		store, err := data.NewSQLiteStore(cfg.DataPath)
+		if err != nil {
+			return nil, "", err
+		}
+		return store, "SQLite (" + cfg.DataPath + ")", nil
+	default:
+		return nil, "", fmt.Errorf("不明なデータストアです: %q（memory, json, sqlite のいずれかを指定してください）", cfg.Store)
+	}
+}
+
+// fatal はエラーをログに残して終了する
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
+}
+
+// promoteAdmins は管理者がまだいない場合に限り、指定されたユーザーの役割を管理者にする
+// 管理者がいれば何もしない（後から同じユーザー名で登録した人が、再起動のたびに管理者にされるのを防ぐ）
+func promoteAdmins(store data.DataStore, usernames []string) {
+	if len(usernames) == 0 {
+		return
+	}
+	admins, err := store.CountUsers(data.RoleAdmin)
+	if err != nil {
+		slog.Warn("管理者の有無を確認できませんでした", "error", err)
+		return
+	}
+	if admins > 0 {
+		slog.Info("管理者が既にいるため admin_usernames は使いません", "admins", admins)
+		return
+	}
+	for _, name := range usernames {
+		user, err := store.GetUserByUsername(name)
+		if err != nil {
+			slog.Warn("管理者に指定されたユーザーが見つかりません", "username", name)
+			continue
+		}
+		if user.EffectiveRole() == data.RoleAdmin {
+			continue
+		}
+		promoted := *user
+		promoted.Role = data.RoleAdmin
+		if err := store.UpdateUser(&promoted); err != nil {
+			slog.Error("ユーザーを管理者にできませんでした", "username", name, "error", err)
+			continue
+		}
+		slog.Info("ユーザーを管理者にしました", "username", name)
+	}
+}
+
+// newFilter はNGワードのファイルを読み込んでフィルターを作る（ファイルの指定が無ければ検査しない）
+func newFilter(cfg config.ModerationConfig) (*moderation.Filter, error) {
+	if cfg.NGWordsFile == "" {
+		return nil, nil
+	}
+	words, err := moderation.LoadWords(cfg.NGWordsFile)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := moderation.New(moderation.Config{Words: words, Action: moderation.Action(cfg.Action)})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("NGワードを読み込みました", "words", filter.Len(), "action", filter.Action())
+	return filter, nil
+}
+
+// rateLimiters は種類ごとのレート制限
+type rateLimiters struct {
+	reads   *ratelimit.Limiter // 取得（GET）
+	answers *ratelimit.Limiter // 回答の投稿
+	themes  *ratelimit.Limiter // お題の投稿
+}
+
+// newRateLimiters は設定からレート制限を初期化する
+func newRateLimiters(cfg config.RateLimitConfig) (*rateLimiters, error) {
+	limiter := func(value string) (*ratelimit.Limiter, error) {
+		rule, err := ratelimit.ParseRule(value)
+		if err != nil {
+			return nil, err
+		}
+		return ratelimit.NewLimiter(rule), nil
+	}
+
+	var limits rateLimiters
+	var err error
+	if limits.reads, err = limiter(cfg.Reads); err != nil {
+		return nil, err
+	}
+	if limits.answers, err = limiter(cfg.Answers); err != nil {
+		return nil, err
+	}
+	if limits.themes, err = limiter(cfg.Themes); err != nil {
+		return nil, err
+	}
+	slog.Info("レート制限", "reads", limits.reads.Rule().String(), "answers", limits.answers.Rule().String(), "themes", limits.themes.Rule().String())
+	return &limits, nil
+}
+
+// limiterFor はルートに応じたレート制限を返す（制限しない場合は nil）
+func (l *rateLimiters) limiterFor(r *http.Request) *ratelimit.Limiter {
+	path := httputil.RouteTemplate(r)
+	switch {
+	case r.Method == http.MethodGet:
+		return l.reads
+	case r.Method == http.MethodPost && path == "/api/themes/{themeID}/answers":
+		return l.answers
+	case r.Method == http.MethodPost && path == "/api/themes":
+		return l.themes
+	}
+	return nil
+}
 
 // CORSミドルウェアを実装
-func enableCORS(next http.Handler) http.Handler {
+// cors_origins に * が含まれていれば全てのオリジンを許可し、それ以外は列挙したオリジンだけを許可する
+func enableCORS(cfg *config.Config, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(cfg.CORSOrigins))
+	for _, origin := range cfg.CORSOrigins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// すべてのオリジンを許可
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		if cfg.AllowsAnyOrigin() {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); allowed[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client-Token, Last-Event-ID, X-Request-ID, X-Response-Format, If-Match, If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Request-ID, ETag, X-Next-Cursor, Link")
 
 		// OPTIONSリクエストは処理せずに返す
 		if r.Method == "OPTIONS" {
@@ -35,48 +191,171 @@ func enableCORS(next http.Handler) http.Handler {
 }
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	// export・import サブコマンドはサーバーを起動せずにお題と回答を移す（transfer.go を参照）
+	if len(os.Args) > 1 && (os.Args[1] == "export" || os.Args[1] == "import") {
+		os.Exit(runTransfer(os.Args[1], os.Args[2:]))
+	}
+
+	// 設定は既定値 < 設定ファイル < 環境変数 < フラグ の順に上書きする
+	cfg, opts, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fatal("設定の読み込みに失敗しました", err)
+	}
+	if opts.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			fatal("設定の表示に失敗しました", err)
+		}
+		return
+	}
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logging.Setup(os.Stderr, cfg.Log.Format, level)
+	if opts.ConfigFile != "" {
+		slog.Info("設定ファイルを読み込みました", "path", opts.ConfigFile)
+	}
+	// X-Response-Format ヘッダーを送らないクライアントへのレスポンスの形式
+	response.SetDefaultFormat(response.Format(cfg.ResponseFormat))
+	port := cfg.Port
+
+	// データストアを初期化
+	store, storeDesc, err := newStore(cfg)
+	if err != nil {
+		fatal("データストアの初期化に失敗しました", err)
+	}
+	slog.Info("データストアを開きました", "store", storeDesc)
+
+	// メトリクスを初期化し、データストアの操作の処理時間とエラーを記録する
+	m := metrics.New()
+	m.RegisterDomain(store)
+	store = m.InstrumentStore(store)
+
+	// 管理者がまだいなければ、admin_usernames に列挙された登録済みユーザーを管理者にする
+	promoteAdmins(store, cfg.AdminUsernames)
+
+	// お題・回答の変更をリアルタイム配信するため、ストアを包んでハブへイベントを流す
+	hub := realtime.NewHub()
+	store = realtime.NewPublishingStore(store, hub)
+	m.GaugeFunc("realtime_subscribers", "WebSocket・Server-Sent Events の購読者数", func() float64 {
+		return float64(hub.Count())
+	})
+
+	// Ctrl-C・SIGTERM を受け取ったら停止処理を始める
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// お題の受付期間に従って Active を切り替えるスケジューラーを起動
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler.New(store, scheduler.DefaultInterval).Run(ctx)
+	}()
+
+	// NGワードフィルターを初期化
+	filter, err := newFilter(cfg.Moderation)
+	if err != nil {
+		fatal("NGワードフィルターの初期化に失敗しました", err)
+	}
+
+	// レート制限を初期化
+	limits, err := newRateLimiters(cfg.RateLimit)
+	if err != nil {
+		fatal("レート制限の初期化に失敗しました", err)
+	}
+	// trusted_proxies に指定したプロキシからの接続では X-Forwarded-For・X-Forwarded-Proto を使う
+	proxies, err := httputil.ParseProxies(strings.Join(cfg.TrustedProxies, ","))
+	if err != nil {
+		fatal("trusted_proxies の読み込みに失敗しました", err)
 	}
-	// JSONファイルベースのデータストアを初期化
-	store := data.NewJSONStore(dataFile)
-	log.Printf("📁 データファイル: %s", dataFile)
 
 	// ハンドラー初期化
-	h := handlers.NewHandler(store)
-	// ルーターの設定
-	r := mux.NewRouter()
-	
-	// お題関連のエンドポイント
-	r.HandleFunc("/api/themes", h.ListThemes).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/themes", h.CreateTheme).Methods("POST", "OPTIONS")
-	r.HandleFunc("/api/themes/{id}", h.GetTheme).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/themes/{id}", h.UpdateTheme).Methods("PUT", "OPTIONS")
-	r.HandleFunc("/api/themes/{id}", h.DeleteTheme).Methods("DELETE", "OPTIONS")
-
-	// 回答関連のエンドポイント
-	r.HandleFunc("/api/themes/{themeID}/answers", h.ListAnswers).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/themes/{themeID}/answers", h.SubmitAnswer).Methods("POST", "OPTIONS")
-	r.HandleFunc("/api/themes/{themeID}/answers/{id}", h.GetAnswer).Methods("GET", "OPTIONS")
-	r.HandleFunc("/api/themes/{themeID}/answers/{id}", h.UpdateAnswer).Methods("PUT", "OPTIONS")
-	r.HandleFunc("/api/themes/{themeID}/answers/{id}", h.DeleteAnswer).Methods("DELETE", "OPTIONS")	// CORSミドルウェアを適用
-	corsRouter := enableCORS(r)
+	h := handlers.NewHandler(store, hub, filter, proxies)
+	// ルーターの設定（ルートの一覧は handlers.Routes を参照）
+	r := h.Routes()
+	// ミドルウェアは404・405のレスポンスにも適用する
+	handlers.Use(r,
+		// ルートごとのリクエスト数と処理時間を記録（認証エラーやレート制限も数えるため最初に適用）
+		m.Middleware,
+		// セッションを検証してログイン中のユーザーをリクエストに付加
+		auth.Middleware(store),
+		// リクエストIDを割り当て、リクエストごとにログを残す（ログイン中のユーザーを記録するため認証の後に適用）
+		logging.Middleware,
+		// ログイン中のユーザーまたはIPアドレスごとにリクエスト数を制限
+		ratelimit.Middleware(proxies, limits.limiterFor),
+	)
+
+	// CORSミドルウェアを適用
+	corsRouter := enableCORS(cfg, r)
 
 	// 静的ファイルハンドラー（HTMLテスター用）
-	// カレントディレクトリからの静的ファイル提供
-	fileServer := http.FileServer(http.Dir("."))
-	corsFileServer := enableCORS(fileServer)
-	
-	http.Handle("/", corsFileServer)
-	http.Handle("/api/", corsRouter)	// サーバー起動
-	log.Printf("--------------------------------------------------------")
-	log.Printf("🎉 大喜利サーバーを起動中...ポート: %s", port)
-	log.Printf("💾 データ保存方式: JSONファイル (%s)", dataFile)
-	log.Printf("🌐 以下のURLでアクセスできます:")
-	log.Printf("   - トップページ: http://localhost:%s/", port)
-	log.Printf("   - APIテスター: http://localhost:%s/api_tester.html", port)
-	log.Printf("   - お題募集: http://localhost:%s/theme_submission.html", port)
-	log.Printf("--------------------------------------------------------")
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	// static_dir からの静的ファイル提供（空なら提供しない）
+	if cfg.StaticDir != "" {
+		fileServer := http.FileServer(http.Dir(cfg.StaticDir))
+		corsFileServer := enableCORS(cfg, fileServer)
+		http.Handle("/", corsFileServer)
+	}
+	http.Handle("/api/", corsRouter)
+
+	// 死活監視・状態確認のエンドポイント（/api/ のCORS・認証・レート制限を通さない）
+	ops := h.OpsRoutes(m.Handler())
+	http.Handle("/healthz", ops)
+	http.Handle("/readyz", ops)
+	http.Handle("/version", ops)
+	http.Handle("/metrics", ops)
+
+	// サーバー起動
+	startAttrs := []any{"port", port, "store", storeDesc}
+	if cfg.StaticDir != "" {
+		startAttrs = append(startAttrs,
+			"top", "http://localhost:"+port+"/",
+			"api_tester", "http://localhost:"+port+"/api_tester.html",
+			"theme_submission", "http://localhost:"+port+"/theme_submission.html")
+	}
+	slog.Info("大喜利サーバーを起動しました", startAttrs...)
+
+	srv := &http.Server{Addr: ":" + port}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
+		// ポートが使用中などで起動できなかった
+		slog.Error("サーバーを起動できませんでした", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+	}
+	// 停止処理中にもう一度シグナルを受け取った場合は即座に終了する
+	stop()
+
+	if !shutdown(srv, hub, cfg.ShutdownDuration()) {
+		exitCode = 1
+	}
+	// スケジューラーが更新中のお題を書き終えてからストアを閉じる
+	<-schedulerDone
+	if err := store.Close(); err != nil {
+		slog.Error("データストアの終了に失敗しました", "error", err)
+		exitCode = 1
+	}
+	slog.Info("大喜利サーバーを停止しました")
+	os.Exit(exitCode)
+}
+
+// shutdown は新しい接続の受け付けをやめ、処理中のリクエストが終わるまで timeout だけ待つ
+// WebSocket・Server-Sent Events の購読者は待っても終わらないため、先にハブを閉じて切断する
+func shutdown(srv *http.Server, hub *realtime.Hub, timeout time.Duration) bool {
+	slog.Info("サーバーを停止しています", "timeout", timeout.String())
+	hub.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("処理中のリクエストを待ちきれなかったため、接続を切断します", "error", err)
+		srv.Close()
+		return false
+	}
+	return true
 }
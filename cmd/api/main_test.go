@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/handlers"
+	"github.com/nicest414/ogiri-server/internal/ratelimit"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+)
+
+// TestPromoteAdmins は管理者がまだいない場合に限り、指定されたユーザーを管理者にすることを確認する
+func TestPromoteAdmins(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  data.Role // 既にいるユーザー "owner" の役割
+		usernames []string
+		want      map[string]data.Role
+	}{
+		{"管理者がいない", data.RolePlayer, []string{"alice", "missing"}, map[string]data.Role{"alice": data.RoleAdmin, "bob": data.RolePlayer}},
+		{"ユーザー名の大文字小文字", data.RolePlayer, []string{"ALICE"}, map[string]data.Role{"alice": data.RoleAdmin}},
+		{"管理者がいる", data.RoleAdmin, []string{"alice"}, map[string]data.Role{"alice": data.RolePlayer, "owner": data.RoleAdmin}},
+		{"指定なし", data.RolePlayer, nil, map[string]data.Role{"alice": data.RolePlayer}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := data.NewInMemoryStore()
+			for _, user := range []*data.User{
+				{Username: "owner", Role: tt.existing},
+				{Username: "alice"},
+				{Username: "bob"},
+			} {
+				if err := store.CreateUser(user); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			promoteAdmins(store, tt.usernames)
+			for name, want := range tt.want {
+				user, err := store.GetUserByUsername(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := user.EffectiveRole(); got != want {
+					t.Errorf("%s: role = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestLimiterFor はルートごとに取得・回答の投稿・お題の投稿の制限を使い分けることを確認する
+func TestLimiterFor(t *testing.T) {
+	limits := &rateLimiters{
+		reads:   ratelimit.NewLimiter(ratelimit.Rule{Burst: 1, Per: 1}),
+		answers: ratelimit.NewLimiter(ratelimit.Rule{Burst: 2, Per: 1}),
+		themes:  ratelimit.NewLimiter(ratelimit.Rule{Burst: 3, Per: 1}),
+	}
+	tests := []struct {
+		method string
+		path   string
+		want   *ratelimit.Limiter
+	}{
+		{http.MethodGet, "/api/themes", limits.reads},
+		{http.MethodGet, "/api/themes/t1/answers", limits.reads},
+		{http.MethodPost, "/api/themes/t1/answers", limits.answers},
+		{http.MethodPost, "/api/themes", limits.themes},
+		{http.MethodPut, "/api/themes/t1", nil},
+		{http.MethodPost, "/api/auth/login", nil},
+	}
+	for _, tt := range tests {
+		var got *ratelimit.Limiter
+		r := handlers.NewHandler(data.NewInMemoryStore(), realtime.NewHub(), nil, nil).Routes()
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { got = limits.limiterFor(req) })
+		})
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+		if got != tt.want {
+			t.Errorf("%s %s: limiter = %p, want %p", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestShutdown はイベントストリームの購読者を切断してから、処理中のリクエストを待って停止することを確認する
+func TestShutdown(t *testing.T) {
+	hub := realtime.NewHub()
+	r := handlers.NewHandler(data.NewInMemoryStore(), hub, nil, nil).Routes()
+	started, released := make(chan struct{}), make(chan struct{})
+	r.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-released
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	stream, err := http.Get(ts.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	slow := make(chan int, 1)
+	go func() {
+		res, err := http.Get(ts.URL + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		res.Body.Close()
+		slow <- res.StatusCode
+	}()
+	<-started
+	// 処理中のリクエストは停止を始めた後に終わる
+	time.AfterFunc(100*time.Millisecond, func() { close(released) })
+
+	if !shutdown(ts.Config, hub, 5*time.Second) {
+		t.Fatal("処理中のリクエストを待ちきれませんでした")
+	}
+	if code := <-slow; code != http.StatusNoContent {
+		t.Errorf("処理中のリクエスト: status = %d, want %d", code, http.StatusNoContent)
+	}
+	// ストリームはサーバーから閉じられている
+	if _, err := io.ReadAll(stream.Body); err != nil {
+		t.Errorf("イベントストリームが正常に終わりませんでした: %v", err)
+	}
+	if hub.Count() != 0 {
+		t.Errorf("購読者が残っています: %d", hub.Count())
+	}
+}
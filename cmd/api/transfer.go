@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/config"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/logging"
+	"github.com/nicest414/ogiri-server/internal/transfer"
+)
+
+// transferUsage はサブコマンドの使い方
+const transferUsage = `使い方:
+  api export [-format jsonl|csv] [-theme-id ID,...] [-created-after 日時] [-created-before 日時] [-out ファイル] [設定のフラグ]
+  api import [-format jsonl|csv] [-ids preserve|regenerate] [-dry-run] [-in ファイル] [設定のフラグ]
+
+サーバーを停止してから実行してください（起動中は /api/admin/export・/api/admin/import を使います）。
+`
+
+// runTransfer は export・import サブコマンドを実行して終了コードを返す
+// 設定（データストアの種類とパス）はサーバーと同じように読み込む
+// サーバーを停止した状態で使う（JSONのデータファイルはサーバーがロックしているため開けない）
+func runTransfer(command string, args []string) int {
+	fs := flag.NewFlagSet("ogiri-server "+command, flag.ContinueOnError)
+	var run func(store data.DataStore) error
+	switch command {
+	case "export":
+		run = exportCommand(fs)
+	case "import":
+		run = importCommand(fs)
+	}
+
+	cfg, _, err := config.LoadFlags(fs, args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, transferUsage)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n%s", command, err, transferUsage)
+		return 2
+	}
+	level, _ := logging.ParseLevel(cfg.Log.Level)
+	logging.Setup(os.Stderr, cfg.Log.Format, level)
+	if cfg.Store == config.StoreMemory {
+		slog.Error("メモリ内のデータストアはサーバーと共有できません。store に json または sqlite を指定してください")
+		return 2
+	}
+
+	store, storeDesc, err := newStore(cfg)
+	if errors.Is(err, data.ErrLocked) {
+		slog.Error("サーバーが同じデータファイルを開いています。サーバーを停止するか、/api/admin/export・/api/admin/import を使ってください", "error", err)
+		return 1
+	}
+	if err != nil {
+		slog.Error("データストアの初期化に失敗しました", "error", err)
+		return 1
+	}
+	slog.Info("データストアを開きました", "store", storeDesc)
+
+	exitCode := 0
+	if err := run(store); err != nil {
+		slog.Error(command+"に失敗しました", "error", err)
+		exitCode = 1
+	}
+	if err := store.Close(); err != nil {
+		slog.Error("データストアの終了に失敗しました", "error", err)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// exportCommand は export サブコマンドのフラグを定義し、実行する関数を返す
+func exportCommand(fs *flag.FlagSet) func(data.DataStore) error {
+	format := fs.String("format", string(transfer.FormatJSONL), "ファイルの形式 (jsonl, csv)")
+	themeIDs := fs.String("theme-id", "", "書き出すお題のID（カンマ区切り、省略すると全て）")
+	after := fs.String("created-after", "", "この日時より後に作成したお題だけを書き出す（RFC3339）")
+	before := fs.String("created-before", "", "この日時より前に作成したお題だけを書き出す（RFC3339）")
+	out := fs.String("out", "-", "書き出すファイル（- なら標準出力）")
+
+	return func(store data.DataStore) error {
+		f := transfer.Format(*format)
+		if !f.Valid() {
+			return fmt.Errorf("-format には jsonl または csv を指定してください: %q", *format)
+		}
+		var filter transfer.Filter
+		for _, id := range strings.Split(*themeIDs, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				filter.ThemeIDs = append(filter.ThemeIDs, id)
+			}
+		}
+		for name, v := range map[string]struct {
+			value string
+			dst   *time.Time
+		}{
+			"-created-after":  {*after, &filter.CreatedAfter},
+			"-created-before": {*before, &filter.CreatedBefore},
+		} {
+			if v.value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v.value)
+			if err != nil {
+				return fmt.Errorf("%s はRFC3339形式（例: 2024-01-02T15:04:05+09:00）で指定してください: %q", name, v.value)
+			}
+			*v.dst = t
+		}
+
+		w := os.Stdout
+		if *out != "-" {
+			file, err := os.Create(*out)
+			if err != nil {
+				return err
+			}
+			w = file
+		}
+		counts, err := transfer.Export(store, w, f, filter)
+		if w != os.Stdout {
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		slog.Info("お題と回答をエクスポートしました", "themes", counts.Themes, "answers", counts.Answers, "out", *out)
+		return nil
+	}
+}
+
+// importCommand は import サブコマンドのフラグを定義し、実行する関数を返す
+func importCommand(fs *flag.FlagSet) func(data.DataStore) error {
+	format := fs.String("format", "", "ファイルの形式 (jsonl, csv。省略すると -in の拡張子が .csv なら csv、それ以外は jsonl)")
+	ids := fs.String("ids", "preserve", "preserve ならファイルのIDをそのまま使い、regenerate なら新しいIDを振る")
+	dryRun := fs.Bool("dry-run", false, "検査だけを行い、保存しない")
+	in := fs.String("in", "-", "読み込むファイル（- なら標準入力）")
+
+	return func(store data.DataStore) error {
+		opts := transfer.Options{Format: transfer.Format(*format), DryRun: *dryRun}
+		if opts.Format == "" {
+			opts.Format = transfer.FormatJSONL
+			if strings.EqualFold(filepath.Ext(*in), ".csv") {
+				opts.Format = transfer.FormatCSV
+			}
+		}
+		if !opts.Format.Valid() {
+			return fmt.Errorf("-format には jsonl または csv を指定してください: %q", *format)
+		}
+		switch *ids {
+		case "preserve":
+		case "regenerate":
+			opts.RegenerateIDs = true
+		default:
+			return fmt.Errorf("-ids には preserve または regenerate を指定してください: %q", *ids)
+		}
+
+		var r io.Reader = os.Stdin
+		if *in != "-" {
+			file, err := os.Open(*in)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			r = file
+		}
+		result, err := transfer.Import(store, r, opts)
+		if err != nil {
+			return fmt.Errorf("%w（何も保存していません）", err)
+		}
+		if len(result.Errors) > 0 {
+			for _, d := range result.Errors {
+				field := d.Field
+				if field == "" {
+					field = "-"
+				}
+				fmt.Fprintf(os.Stderr, "%d行目: %s: %s (%s)\n", d.Line, field, d.Message, d.Code)
+			}
+			if len(result.Errors) >= transfer.MaxErrors {
+				fmt.Fprintf(os.Stderr, "問題が%d件を超えたため、以降の行は検査していません\n", transfer.MaxErrors)
+			}
+			return fmt.Errorf("ファイルに%d件の問題があるため、何も保存していません", len(result.Errors))
+		}
+		if opts.DryRun {
+			slog.Info("問題は見つかりませんでした（-dry-run のため保存していません）", "themes", result.Themes, "answers", result.Answers)
+			return nil
+		}
+		slog.Info("お題と回答をインポートしました", "themes", result.Themes, "answers", result.Answers)
+		return nil
+	}
+}
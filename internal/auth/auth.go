@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// CookieName はセッショントークンを保存するクッキー名
+	CookieName = "ogiri_session"
+	// SessionTTL はセッションの有効期間
+	SessionTTL = 30 * 24 * time.Hour
+)
+
+type contextKey struct{}
+
+// HashPassword はパスワードをbcryptでハッシュ化する
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword はパスワードがハッシュと一致するか判定する
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// CheckUserPassword はユーザーのパスワードが一致するか判定する
+// user が nil（ユーザーが見つからない）でもダミーのハッシュと比較して同じだけ時間をかけ、
+// ログインにかかる時間からユーザー名の有無を推測されないようにする
+func CheckUserPassword(user *data.User, password string) bool {
+	if user == nil {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("ogiri-dummy-password"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return CheckPassword(user.PasswordHash, password)
+}
+
+// NewSession はユーザーの新しいセッションを作成し、クライアントに渡すトークンとともに返す
+func NewSession(userID string) (string, *data.Session, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(bytes)
+
+	now := time.Now()
+	session := &data.Session{
+		TokenHash: HashToken(token),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionTTL),
+	}
+	return token, session, nil
+}
+
+// HashToken はセッショントークンを保存用にハッシュ化する
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenFromRequest はAuthorizationヘッダー（Bearer）またはクッキーからトークンを取り出す
+func TokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token := strings.TrimPrefix(header, "Bearer "); token != header {
+			return strings.TrimSpace(token)
+		}
+	}
+	if cookie, err := r.Cookie(CookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// SetSessionCookie はセッショントークンをクッキーに保存する
+// クライアントがHTTPSで接続している場合は secure を true にしてSecure属性を付ける
+func SetSessionCookie(w http.ResponseWriter, token string, expires time.Time, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie はセッションのクッキーを削除する
+func ClearSessionCookie(w http.ResponseWriter, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Middleware はリクエストのセッションを検証し、ログイン中のユーザーをコンテキストに付加する
+// セッションが無い・無効な場合は匿名のまま次のハンドラーに渡す
+func Middleware(store data.DataStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := authenticate(store, r); user != nil {
+				r = r.WithContext(WithUser(r.Context(), user))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func authenticate(store data.DataStore, r *http.Request) *data.User {
+	token := TokenFromRequest(r)
+	if token == "" {
+		return nil
+	}
+
+	session, err := store.GetSession(HashToken(token))
+	if err != nil {
+		return nil
+	}
+	if time.Now().After(session.ExpiresAt) {
+		store.DeleteSession(session.TokenHash)
+		return nil
+	}
+
+	user, err := store.GetUser(session.UserID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// WithUser はユーザーを付加したコンテキストを返す
+func WithUser(ctx context.Context, user *data.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext はログイン中のユーザーを返す（匿名の場合はnil）
+func UserFromContext(ctx context.Context) *data.User {
+	user, _ := ctx.Value(contextKey{}).(*data.User)
+	return user
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestCheckUserPassword はユーザーがいない場合も、実際のハッシュと同じコストで比較することを確認する
+func TestCheckUserPassword(t *testing.T) {
+	hash, err := HashPassword("correct-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &data.User{ID: "u1", Username: "user", PasswordHash: hash}
+
+	tests := []struct {
+		name     string
+		user     *data.User
+		password string
+		want     bool
+	}{
+		{"正しいパスワード", user, "correct-password", true},
+		{"違うパスワード", user, "wrong-password", false},
+		{"ユーザーがいない", nil, "correct-password", false},
+		{"ユーザーがいない・ダミーのパスワード", nil, "ogiri-dummy-password", false},
+	}
+	for _, tt := range tests {
+		if got := CheckUserPassword(tt.user, tt.password); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// ユーザーがいない場合の比較にかかる時間は、ハッシュのコストで決まる
+	dummyCost, err := bcrypt.Cost(dummyHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userCost, _ := bcrypt.Cost([]byte(hash))
+	if dummyCost != userCost {
+		t.Errorf("ダミーのハッシュのコスト = %d, want %d", dummyCost, userCost)
+	}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import "github.com/nicest414/ogiri-server/internal/data"
+
+// Permission はAPIで行う操作の種類
+type Permission string
+
+const (
+	PermCreateTheme  Permission = "theme:create" // 審査を経ずに承認済みのお題を作成する
+	PermSubmitTheme  Permission = "theme:submit" // 審査待ちのお題を投稿する
+	PermApproveTheme Permission = "theme:approve"
+	PermUpdateTheme  Permission = "theme:update" // 公開・非公開の切り替えを含む
+	PermDeleteTheme  Permission = "theme:delete"
+	PermSubmitAnswer Permission = "answer:submit"
+	PermUpdateAnswer Permission = "answer:update"
+	PermDeleteAnswer Permission = "answer:delete"
+	PermHideAnswer   Permission = "answer:hide"
+	PermLikeAnswer   Permission = "answer:like"
+	PermReportAnswer Permission = "answer:report"
+	PermManageUsers  Permission = "user:manage"
+	PermTransferData Permission = "data:transfer" // お題・回答のエクスポート・インポート
+	// 確認待ちのお題・回答の閲覧
+	PermReviewContent Permission = "content:review"
+)
+
+// publicPermissions は匿名を含む全員に許可する操作
+var publicPermissions = map[Permission]bool{
+	PermSubmitTheme:  true,
+	PermSubmitAnswer: true,
+	PermLikeAnswer:   true,
+	PermReportAnswer: true,
+}
+
+// ownerPermissions は作成者本人に許可する操作
+var ownerPermissions = map[Permission]bool{
+	PermUpdateAnswer: true,
+	PermDeleteAnswer: true,
+}
+
+// rolePermissions は役割ごとに許可する操作（adminは全て許可）
+var rolePermissions = map[data.Role]map[Permission]bool{
+	data.RolePlayer: {},
+	data.RoleModerator: {
+		PermDeleteAnswer:  true,
+		PermHideAnswer:    true,
+		PermReviewContent: true,
+	},
+}
+
+// Can はユーザーが操作を行えるか判定する
+// user が nil の場合は匿名として扱い、ownerID には対象の作成者のユーザーID（無ければ空）を渡す
+func Can(user *data.User, perm Permission, ownerID string) bool {
+	if publicPermissions[perm] {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+
+	role := user.EffectiveRole()
+	if role == data.RoleAdmin || rolePermissions[role][perm] {
+		return true
+	}
+	return ownerPermissions[perm] && ownerID != "" && ownerID == user.ID
+}
@@ -0,0 +1,64 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// ビルド時に -ldflags で埋め込む値
+//
+//	go build -ldflags "-X github.com/nicest414/ogiri-server/internal/buildinfo.Version=v1.0.0 \
+//	  -X github.com/nicest414/ogiri-server/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/nicest414/ogiri-server/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/api
+var (
+	Version   = "dev"
+	Commit    = "" // 空の場合はGoが埋め込んだVCSの情報を使う
+	BuildTime = ""
+)
+
+// startedAt はプロセスの起動時刻
+var startedAt = time.Now()
+
+// Info はビルドと起動の情報
+type Info struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	BuildTime string    `json:"build_time,omitempty"`
+	GoVersion string    `json:"go_version"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime"`
+}
+
+// Get は現在のビルドと起動の情報を返す
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		StartedAt: startedAt,
+		Uptime:    time.Since(startedAt).Round(time.Second).String(),
+	}
+	if info.Commit == "" {
+		// go build はリポジトリ内でビルドするとコミットを埋め込む
+		if build, ok := debug.ReadBuildInfo(); ok {
+			modified := false
+			for _, setting := range build.Settings {
+				switch setting.Key {
+				case "vcs.revision":
+					info.Commit = setting.Value
+				case "vcs.modified":
+					modified = setting.Value == "true"
+				}
+			}
+			if info.Commit != "" && modified {
+				info.Commit += "-dirty"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,37 @@
+package buildinfo
+
+import (
+	"testing"
+	"time"
+)
+
+// TestGet は埋め込んだ値を優先し、コミットが分からなければ unknown にすることを確認する
+func TestGet(t *testing.T) {
+	version, commit, buildTime := Version, Commit, BuildTime
+	defer func() { Version, Commit, BuildTime = version, commit, buildTime }()
+
+	tests := []struct {
+		name       string
+		commit     string
+		wantCommit string // 空ならテストのバイナリに埋め込まれた値（無ければ unknown）
+	}{
+		{"埋め込んだコミット", "abc123", "abc123"},
+		{"コミットの指定なし", "", ""},
+	}
+	for _, tt := range tests {
+		Version, Commit, BuildTime = "v1.0.0", tt.commit, "2026-01-01T00:00:00Z"
+		info := Get()
+		if info.Version != "v1.0.0" || info.BuildTime != "2026-01-01T00:00:00Z" || info.GoVersion == "" {
+			t.Errorf("%s: info = %+v", tt.name, info)
+		}
+		if tt.wantCommit != "" && info.Commit != tt.wantCommit {
+			t.Errorf("%s: commit = %q, want %q", tt.name, info.Commit, tt.wantCommit)
+		}
+		if info.Commit == "" {
+			t.Errorf("%s: commit が空です", tt.name)
+		}
+		if info.StartedAt != startedAt || info.StartedAt.After(time.Now()) {
+			t.Errorf("%s: started_at = %v", tt.name, info.StartedAt)
+		}
+	}
+}
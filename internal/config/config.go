@@ -0,0 +1,346 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/httputil"
+	"github.com/nicest414/ogiri-server/internal/logging"
+	"github.com/nicest414/ogiri-server/internal/moderation"
+	"github.com/nicest414/ogiri-server/internal/ratelimit"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"gopkg.in/yaml.v3"
+)
+
+// データストアの種類
+const (
+	StoreMemory = "memory"
+	StoreJSON   = "json"
+	StoreSQLite = "sqlite"
+)
+
+// データファイルの既定値（data_path を指定しない場合）
+const (
+	DefaultJSONPath   = "ogiri_data.json"
+	DefaultSQLitePath = "ogiri_data.db"
+)
+
+// Config はサーバーの設定
+type Config struct {
+	Port            string           `yaml:"port"`
+	Store           string           `yaml:"store"`
+	DataPath        string           `yaml:"data_path"`  // 空の場合は store に応じた既定値
+	StaticDir       string           `yaml:"static_dir"` // 空の場合は静的ファイルを配信しない
+	CORSOrigins     []string         `yaml:"cors_origins"`
+	AdminUsernames  []string         `yaml:"admin_usernames"`
+	TrustedProxies  []string         `yaml:"trusted_proxies"`  // X-Forwarded-For・X-Forwarded-Proto を信用するプロキシ
+	ShutdownTimeout string           `yaml:"shutdown_timeout"` // 停止時に処理中のリクエストを待つ時間
+	ResponseFormat  string           `yaml:"response_format"`  // APIのレスポンスの形式（legacy, envelope）
+	Log             LogConfig        `yaml:"log"`
+	RateLimit       RateLimitConfig  `yaml:"rate_limit"`
+	Moderation      ModerationConfig `yaml:"moderation"`
+}
+
+// RateLimitConfig はレート制限の設定（「回数/期間」の形式、"0" で制限なし）
+type RateLimitConfig struct {
+	Reads   string `yaml:"reads"`
+	Answers string `yaml:"answers"`
+	Themes  string `yaml:"themes"`
+}
+
+// LogConfig はログの設定
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn, error
+	Format string `yaml:"format"` // json, text
+}
+
+// ModerationConfig はNGワードの設定
+type ModerationConfig struct {
+	NGWordsFile string `yaml:"ng_words_file"` // 空の場合は検査しない
+	Action      string `yaml:"action"`
+}
+
+// Default は既定の設定を返す
+func Default() Config {
+	return Config{
+		Port:            "8080",
+		Store:           StoreJSON,
+		StaticDir:       ".",
+		CORSOrigins:     []string{"*"},
+		ShutdownTimeout: "15s",
+		ResponseFormat:  string(response.FormatLegacy),
+		Log: LogConfig{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+		RateLimit: RateLimitConfig{
+			Reads:   "300/m",
+			Answers: "10/m",
+			Themes:  "10/h",
+		},
+		Moderation: ModerationConfig{
+			Action: string(moderation.ActionReject),
+		},
+	}
+}
+
+// setting は環境変数とフラグで上書きできる1つの設定項目
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string)
+}
+
+var settings = []setting{
+	{"port", "PORT", "待ち受けるポート番号", func(c *Config, v string) { c.Port = v }},
+	{"store", "STORE", "データストアの種類 (memory, json, sqlite)", func(c *Config, v string) { c.Store = v }},
+	{"data", "DATA_PATH", "データファイルのパス（json, sqlite）", func(c *Config, v string) { c.DataPath = v }},
+	{"static", "STATIC_DIR", "静的ファイルを配信するディレクトリ（空なら配信しない）", func(c *Config, v string) { c.StaticDir = v }},
+	{"cors-origins", "CORS_ORIGINS", "APIへのアクセスを許可するオリジン（カンマ区切り、* で全て）", func(c *Config, v string) { c.CORSOrigins = httputil.SplitList(v) }},
+	{"admin-usernames", "ADMIN_USERNAMES", "起動時に管理者にするユーザー名（カンマ区切り）", func(c *Config, v string) { c.AdminUsernames = httputil.SplitList(v) }},
+	{"trusted-proxies", "TRUSTED_PROXIES", "X-Forwarded-For・X-Forwarded-Proto を信用するプロキシのIPアドレスまたはCIDR（カンマ区切り）", func(c *Config, v string) { c.TrustedProxies = httputil.SplitList(v) }},
+	{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "停止時に処理中のリクエストを待つ時間（例: 15s）", func(c *Config, v string) { c.ShutdownTimeout = v }},
+	{"response-format", "RESPONSE_FORMAT", "APIのレスポンスの形式 (legacy, envelope)", func(c *Config, v string) { c.ResponseFormat = v }},
+	{"log-level", "LOG_LEVEL", "ログレベル (debug, info, warn, error)", func(c *Config, v string) { c.Log.Level = v }},
+	{"log-format", "LOG_FORMAT", "ログの形式 (json, text)", func(c *Config, v string) { c.Log.Format = v }},
+	{"rate-limit-reads", "RATE_LIMIT_READS", "取得のレート制限（例: 300/m）", func(c *Config, v string) { c.RateLimit.Reads = v }},
+	{"rate-limit-answers", "RATE_LIMIT_ANSWERS", "回答の投稿のレート制限（例: 10/m）", func(c *Config, v string) { c.RateLimit.Answers = v }},
+	{"rate-limit-themes", "RATE_LIMIT_THEMES", "お題の投稿のレート制限（例: 10/h）", func(c *Config, v string) { c.RateLimit.Themes = v }},
+	{"ng-words-file", "NG_WORDS_FILE", "NGワードのファイル", func(c *Config, v string) { c.Moderation.NGWordsFile = v }},
+	{"ng-word-action", "NG_WORD_ACTION", "NGワードを含む投稿の扱い (reject, review)", func(c *Config, v string) { c.Moderation.Action = v }},
+}
+
+// Options は設定以外のコマンドラインの指定
+type Options struct {
+	ConfigFile  string // 読み込んだ設定ファイル（無ければ空）
+	PrintConfig bool   // 設定を表示して終了する
+}
+
+// Load は既定値、設定ファイル、環境変数、コマンドラインフラグの順に重ねて設定を読み込み、検証する
+// 設定ファイルは -config フラグまたは環境変数 CONFIG_FILE で指定する
+func Load(args []string, getenv func(string) string) (*Config, Options, error) {
+	return LoadFlags(flag.NewFlagSet("ogiri-server", flag.ContinueOnError), args, getenv)
+}
+
+// LoadFlags は Load と同じように設定を読み込む
+// fs に定義済みのフラグ（サブコマンドのフラグなど）も、設定のフラグと一緒に args から読み込む
+func LoadFlags(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, Options, error) {
+	var opts Options
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.ConfigFile, "config", getenv("CONFIG_FILE"), "設定ファイル（YAML）のパス")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "最終的な設定を表示して終了する")
+	values := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		values[s.flag] = fs.String(s.flag, "", s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return nil, opts, err
+	}
+	if fs.NArg() > 0 {
+		return nil, opts, fmt.Errorf("不明な引数です: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := Default()
+	if opts.ConfigFile != "" {
+		if err := cfg.loadFile(opts.ConfigFile); err != nil {
+			return nil, opts, err
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			s.set(&cfg, v)
+		}
+	}
+	byFlag := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byFlag[s.flag] = s
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if s, ok := byFlag[f.Name]; ok {
+			s.set(&cfg, *values[f.Name])
+		}
+	})
+
+	if cfg.DataPath == "" {
+		switch cfg.Store {
+		case StoreJSON:
+			cfg.DataPath = DefaultJSONPath
+		case StoreSQLite:
+			cfg.DataPath = DefaultSQLitePath
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, err
+	}
+	return &cfg, opts, nil
+}
+
+// loadFile は設定ファイルの値で上書きする
+// ファイル内の相対パスは、作業ディレクトリではなく設定ファイルのディレクトリを基準にする
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("設定ファイルを開けません: %w", err)
+	}
+	defer file.Close()
+
+	var fromFile Config
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fromFile); err != nil && err != io.EOF {
+		return fmt.Errorf("設定ファイル %s を読み込めません: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	// 空でない項目だけを上書きする
+	overlay := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	overlay(&c.Port, fromFile.Port)
+	overlay(&c.Store, fromFile.Store)
+	overlay(&c.DataPath, resolve(fromFile.DataPath))
+	overlay(&c.StaticDir, resolve(fromFile.StaticDir))
+	if fromFile.CORSOrigins != nil {
+		c.CORSOrigins = fromFile.CORSOrigins
+	}
+	if fromFile.AdminUsernames != nil {
+		c.AdminUsernames = fromFile.AdminUsernames
+	}
+	if fromFile.TrustedProxies != nil {
+		c.TrustedProxies = fromFile.TrustedProxies
+	}
+	overlay(&c.ShutdownTimeout, fromFile.ShutdownTimeout)
+	overlay(&c.ResponseFormat, fromFile.ResponseFormat)
+	overlay(&c.Log.Level, fromFile.Log.Level)
+	overlay(&c.Log.Format, fromFile.Log.Format)
+	overlay(&c.RateLimit.Reads, fromFile.RateLimit.Reads)
+	overlay(&c.RateLimit.Answers, fromFile.RateLimit.Answers)
+	overlay(&c.RateLimit.Themes, fromFile.RateLimit.Themes)
+	overlay(&c.Moderation.NGWordsFile, resolve(fromFile.Moderation.NGWordsFile))
+	overlay(&c.Moderation.Action, fromFile.Moderation.Action)
+	return nil
+}
+
+// Validate は設定値を検証し、全ての問題をまとめて返す
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		addf("port は1〜65535の整数で指定してください: %q", c.Port)
+	}
+	switch c.Store {
+	case StoreMemory:
+	case StoreJSON, StoreSQLite:
+		if c.DataPath == "" {
+			addf("data_path を指定してください")
+		}
+	default:
+		addf("store には memory, json, sqlite のいずれかを指定してください: %q", c.Store)
+	}
+	if c.StaticDir != "" {
+		if info, err := os.Stat(c.StaticDir); err != nil || !info.IsDir() {
+			addf("static_dir のディレクトリが見つかりません: %q", c.StaticDir)
+		}
+	}
+	if len(c.CORSOrigins) == 0 {
+		addf("cors_origins を1つ以上指定してください（全て許可する場合は *）")
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			addf("cors_origins のオリジンが不正です（例: https://example.com）: %q", origin)
+		}
+	}
+	if _, err := httputil.ParseProxies(strings.Join(c.TrustedProxies, ",")); err != nil {
+		addf("trusted_proxies: %v", err)
+	}
+	if d, err := time.ParseDuration(c.ShutdownTimeout); err != nil || d <= 0 {
+		addf("shutdown_timeout は正の時間（例: 15s）で指定してください: %q", c.ShutdownTimeout)
+	}
+	if !response.Format(c.ResponseFormat).Valid() {
+		addf("response_format には %s か %s を指定してください: %q", response.FormatLegacy, response.FormatEnvelope, c.ResponseFormat)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		addf("log.level: %v", err)
+	}
+	if c.Log.Format != logging.FormatJSON && c.Log.Format != logging.FormatText {
+		addf("log.format には %s か %s を指定してください: %q", logging.FormatJSON, logging.FormatText, c.Log.Format)
+	}
+	for _, rule := range []struct{ name, value string }{
+		{"rate_limit.reads", c.RateLimit.Reads},
+		{"rate_limit.answers", c.RateLimit.Answers},
+		{"rate_limit.themes", c.RateLimit.Themes},
+	} {
+		if _, err := ratelimit.ParseRule(rule.value); err != nil {
+			addf("%s: %v", rule.name, err)
+		}
+	}
+	switch moderation.Action(c.Moderation.Action) {
+	case moderation.ActionReject, moderation.ActionReview:
+	default:
+		addf("moderation.action には %s か %s を指定してください: %q", moderation.ActionReject, moderation.ActionReview, c.Moderation.Action)
+	}
+	if c.Moderation.NGWordsFile != "" {
+		if _, err := os.Stat(c.Moderation.NGWordsFile); err != nil {
+			addf("moderation.ng_words_file が見つかりません: %q", c.Moderation.NGWordsFile)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("設定が不正です:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// Write は設定をYAML形式で書き出す
+func (c *Config) Write(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// ShutdownDuration は停止時に処理中のリクエストを待つ時間を返す
+func (c *Config) ShutdownDuration() time.Duration {
+	d, _ := time.ParseDuration(c.ShutdownTimeout)
+	return d
+}
+
+// AllowsAnyOrigin は全てのオリジンからのアクセスを許可するか判定する
+func (c *Config) AllowsAnyOrigin() bool {
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile は dir にファイルを作ってパスを返す
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoad は既定値、設定ファイル、環境変数、フラグの順に上書きされることを確認する
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "ogiri.yaml", `
+port: "9000"
+store: sqlite
+data_path: data/ogiri.db
+cors_origins: ["https://example.com"]
+rate_limit:
+  reads: 100/m
+`)
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(c *Config) bool
+	}{
+		{"既定値", nil, nil, func(c *Config) bool {
+			return c.Port == "8080" && c.Store == StoreJSON && c.DataPath == DefaultJSONPath && c.RateLimit.Reads == "300/m" && c.ResponseFormat == "legacy"
+		}},
+		{"sqlite の既定のパス", []string{"-store", "sqlite"}, nil, func(c *Config) bool {
+			return c.DataPath == DefaultSQLitePath
+		}},
+		{"設定ファイルの相対パスはファイルのディレクトリが基準", []string{"-config", file}, nil, func(c *Config) bool {
+			return c.Port == "9000" && c.Store == StoreSQLite && c.DataPath == filepath.Join(dir, "data", "ogiri.db") &&
+				reflect.DeepEqual(c.CORSOrigins, []string{"https://example.com"}) && c.RateLimit.Reads == "100/m" && c.RateLimit.Answers == "10/m"
+		}},
+		{"環境変数で設定ファイルを指定", nil, map[string]string{"CONFIG_FILE": file}, func(c *Config) bool {
+			return c.Port == "9000"
+		}},
+		{"環境変数は設定ファイルより優先", []string{"-config", file}, map[string]string{"PORT": "9100", "TRUSTED_PROXIES": "10.0.0.0/8, 192.0.2.1"}, func(c *Config) bool {
+			return c.Port == "9100" && reflect.DeepEqual(c.TrustedProxies, []string{"10.0.0.0/8", "192.0.2.1"})
+		}},
+		{"フラグは環境変数より優先", []string{"-config", file, "-port", "9200"}, map[string]string{"PORT": "9100"}, func(c *Config) bool {
+			return c.Port == "9200"
+		}},
+		{"フラグで空にできる", []string{"-static="}, nil, func(c *Config) bool {
+			return c.StaticDir == ""
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, opts, err := Load(tt.args, func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("設定 = %+v, options = %+v", cfg, opts)
+			}
+		})
+	}
+}
+
+// TestLoadErrors は不正な設定を、問題ごとにまとめて報告することを確認する
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	unknown := writeFile(t, dir, "unknown.yaml", "prot: 8080\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want []string // エラーメッセージに含まれる文字列
+	}{
+		{"ポート番号", []string{"-port", "70000"}, []string{"port"}},
+		{"データストア", []string{"-store", "mysql"}, []string{"store"}},
+		{"レスポンスの形式", []string{"-response-format", "json"}, []string{"response_format"}},
+		{"停止の待ち時間", []string{"-shutdown-timeout", "0s"}, []string{"shutdown_timeout"}},
+		{"オリジン", []string{"-cors-origins", "example.com"}, []string{"cors_origins"}},
+		{"レート制限とプロキシ", []string{"-rate-limit-reads", "many", "-trusted-proxies", "proxy"}, []string{"rate_limit.reads", "trusted_proxies"}},
+		{"NGワード", []string{"-ng-word-action", "ban", "-ng-words-file", filepath.Join(dir, "missing.txt")}, []string{"moderation.action", "moderation.ng_words_file"}},
+		{"ログ", []string{"-log-level", "verbose", "-log-format", "xml"}, []string{"log.level", "log.format"}},
+		{"静的ファイルのディレクトリ", []string{"-static", filepath.Join(dir, "missing")}, []string{"static_dir"}},
+		{"設定ファイルの不明な項目", []string{"-config", unknown}, []string{"prot"}},
+		{"設定ファイルが無い", []string{"-config", filepath.Join(dir, "missing.yaml")}, []string{"設定ファイルを開けません"}},
+		{"不明な引数", []string{"serve"}, []string{"serve"}},
+	}
+	for _, tt := range tests {
+		_, _, err := Load(tt.args, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("%s: エラーになりませんでした", tt.name)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: エラー %q に %q が含まれていません", tt.name, err, want)
+			}
+		}
+	}
+}
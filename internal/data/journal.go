@@ -0,0 +1,147 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// ジャーナルに記録する操作の種類
+const (
+	opPutTheme      = "put_theme"
+	opDeleteTheme   = "delete_theme"
+	opPutAnswer     = "put_answer"
+	opDeleteAnswer  = "delete_answer"
+	opLike          = "like"
+	opUnlike        = "unlike"
+	opPutUser       = "put_user"
+	opPutSession    = "put_session"
+	opDeleteSession = "delete_session"
+	opPutReport     = "put_report"
+	opDeleteReports = "delete_reports"
+	opImport        = "import"
+)
+
+// journalCompactThreshold はスナップショットへ書き戻すまでに溜めるジャーナル件数
+const journalCompactThreshold = 100
+
+// journalEntry はジャーナルの1行分の変更内容
+// 採番カウンターは絶対値で持つため、同じエントリを二重に再生しても結果は変わらない
+type journalEntry struct {
+	Op           string    `json:"op"`
+	ID           string    `json:"id,omitempty"`
+	Theme        *Theme    `json:"theme,omitempty"`
+	Answer       *Answer   `json:"answer,omitempty"`
+	Voter        string    `json:"voter,omitempty"`
+	User         *User     `json:"user,omitempty"`
+	Session      *Session  `json:"session,omitempty"`
+	Report       *Report   `json:"report,omitempty"`
+	Themes       []*Theme  `json:"themes,omitempty"`  // opImport のみ
+	Answers      []*Answer `json:"answers,omitempty"` // opImport のみ
+	NextThemeID  int       `json:"next_theme_id,omitempty"`
+	NextAnswerID int       `json:"next_answer_id,omitempty"`
+	NextUserID   int       `json:"next_user_id,omitempty"`
+}
+
+// writeFileAtomic は一時ファイルに書き込んでfsyncした後、renameで置き換える
+// 途中でクラッシュしても元のファイルが壊れることはない
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	// rename に成功した後は存在しないので、エラーは無視してよい
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir はrenameをディスクに確定させるためにディレクトリをfsyncする
+// Windowsなどディレクトリのfsyncに対応していない環境ではエラーを無視する
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// readJournal はジャーナルファイルを読み込み、エントリを順に返す
+// 末尾の書きかけの行（書き込み中のクラッシュ）は読み捨てる
+func readJournal(path string) ([]journalEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ジャーナル読み込みエラー: %w", err)
+	}
+	defer f.Close()
+
+	var entries []journalEntry
+	reader := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				slog.Warn("ジャーナルの末尾の行が不完全なため読み捨てます", "path", path, "line", lineNo)
+			}
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ジャーナル読み込みエラー: %w", err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("ジャーナル解析エラー (%d 行目): %w", lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// appendJournal はエントリをジャーナルに追記してfsyncする
+func appendJournal(f *os.File, entry journalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("JSON変換エラー: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("ジャーナル書き込みエラー: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("ジャーナル書き込みエラー: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package data
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestJSONStoreRecovery はクラッシュやファイルの破損から JSONStore を開き直せることを確認する
+func TestJSONStoreRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		// crash はストアを閉じずに（クラッシュした状態で）ファイルに手を加える
+		crash    func(t *testing.T, path string)
+		wantErr  bool
+		wantKept []string // 開き直した後に残っているお題のタイトル
+	}{
+		{
+			name:     "ジャーナルを再生する",
+			crash:    func(t *testing.T, path string) {},
+			wantKept: []string{"スナップショット", "ジャーナル"},
+		},
+		{
+			name: "末尾の書きかけの行は読み捨てる",
+			crash: func(t *testing.T, path string) {
+				appendFile(t, path+".journal", `{"op":"put_theme","theme":{"id":"theme_9","tit`)
+			},
+			wantKept: []string{"スナップショット", "ジャーナル"},
+		},
+		{
+			name: "スナップショットが壊れていれば直前のものを使う",
+			crash: func(t *testing.T, path string) {
+				writeFile(t, path, `{"themes":`)
+			},
+			wantKept: []string{"バックアップ", "ジャーナル"},
+		},
+		{
+			name: "スナップショットもバックアップも壊れていれば開かない",
+			crash: func(t *testing.T, path string) {
+				writeFile(t, path, `{"themes":`)
+				writeFile(t, path+".bak", `{"themes":`)
+			},
+			wantErr: true,
+		},
+		{
+			name: "ジャーナルの途中の行が壊れていれば開かない",
+			crash: func(t *testing.T, path string) {
+				journal, err := os.ReadFile(path + ".journal")
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, path+".journal", "{壊れた行}\n"+string(journal))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.json")
+
+			// 開き直すたびにジャーナルがスナップショットへ書き戻され、直前のスナップショットは .bak になる
+			for _, title := range []string{"バックアップ", "スナップショット", "ジャーナル"} {
+				store, err := NewJSONStore(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if title == "スナップショット" {
+					// 2回目はバックアップのお題を消してスナップショットにだけ残す
+					if err := store.DeleteTheme("theme_1", 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+				mustCreateTheme(t, store, title)
+				abandon(store)
+			}
+			tt.crash(t, path)
+
+			reopened, err := NewJSONStore(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("壊れたファイルを開けてしまいました")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { reopened.Close() })
+			themes, _, err := reopened.ListThemes(ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]bool)
+			for _, theme := range themes {
+				got[theme.Title] = true
+			}
+			for _, title := range tt.wantKept {
+				if !got[title] {
+					t.Errorf("お題 %q がありません（%v）", title, got)
+				}
+			}
+			if len(got) != len(tt.wantKept) {
+				t.Errorf("お題の数 = %d, want %d（%v）", len(got), len(tt.wantKept), got)
+			}
+		})
+	}
+}
+
+// TestJSONStoreLock は同じデータファイルを閉じるまで他から開けないことを確認する
+func TestJSONStoreLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	store, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJSONStore(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("開いている間 err = %v, want ErrLocked", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatalf("閉じた後に開けません: %v", err)
+	}
+	reopened.Close()
+}
+
+// abandon はクラッシュしたときと同じく、スナップショットへ書き戻さずにジャーナルとロックを手放す
+func abandon(store *JSONStore) {
+	store.journal.Close()
+	store.lock.Close()
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
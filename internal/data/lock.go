@@ -0,0 +1,31 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrLocked = errors.New("データファイルは他のプロセスが開いています")
+)
+
+// lockPath はデータファイルのロックファイルのパス
+func lockPath(filePath string) string { return filePath + ".lock" }
+
+// acquireLock はロックファイルを作成して排他ロックを取る
+// 他のプロセスがロックしていれば待たずに ErrLocked を返す。ロックはファイルを閉じると解除される
+func acquireLock(filePath string) (*os.File, error) {
+	f, err := os.OpenFile(lockPath(filePath), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("ロックファイルを作成できません: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, filePath)
+		}
+		return nil, fmt.Errorf("ロックファイルをロックできません: %w", err)
+	}
+	return f, nil
+}
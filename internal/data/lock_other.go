@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package data
+
+import "os"
+
+// lockFile はファイルロックの無いプラットフォームでは何もしない
+func lockFile(f *os.File) error { return nil }
@@ -0,0 +1,18 @@
+//go:build unix
+
+package data
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile は f に排他ロックを取る（flock。他のプロセスがロックしていれば ErrLocked）
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+	return err
+}
@@ -4,52 +4,120 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
 var (
-	ErrNotFound = errors.New("項目が見つかりません")
+	ErrNotFound     = errors.New("項目が見つかりません")
+	ErrAlreadyLiked = errors.New("すでにいいねしています")
+	ErrNotLiked     = errors.New("まだいいねしていません")
+	// ErrVersionConflict は更新しようとしたお題・回答が、読み込んだ後に他の操作で更新されていたことを表す
+	ErrVersionConflict = errors.New("他の操作で更新されています。取得し直してから再度お試しください")
+	ErrClosed          = errors.New("データストアは終了しています")
 )
 
 // Theme はお題を表す構造体
 type Theme struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	CreatedBy   string    `json:"created_by"`
-	Active      bool      `json:"active"`
+	ID               string           `json:"id"`
+	Title            string           `json:"title"`
+	Description      string           `json:"description"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	Version          int64            `json:"version"` // 変更のたびにストアが1増やす（ETagに使う）
+	CreatedBy        string           `json:"created_by"`
+	UserID           string           `json:"user_id,omitempty"` // 作成したユーザーのID（匿名の場合は空）
+	Active           bool             `json:"active"`
+	OpensAt          *time.Time       `json:"opens_at,omitempty"`         // 受付開始日時（指定が無ければ作成時から受付）
+	ClosesAt         *time.Time       `json:"closes_at,omitempty"`        // 受付終了日時（指定が無ければ無期限）
+	SubmissionStatus SubmissionStatus `json:"submission_status"`          // 審査状態（承認済みのお題だけを一般に表示する）
+	RejectionReason  string           `json:"rejection_reason,omitempty"` // 却下した理由（却下された場合のみ）
 }
 
 // Answer は大喜利の回答を表す構造体
 type Answer struct {
-	ID        string    `json:"id"`
-	ThemeID   string    `json:"theme_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CreatedBy string    `json:"created_by"`
-	Likes     int       `json:"likes"`
+	ID         string          `json:"id"`
+	ThemeID    string          `json:"theme_id"`
+	Content    string          `json:"content"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	Version    int64           `json:"version"` // 変更のたびにストアが1増やす（いいねを含む、ETagに使う）
+	CreatedBy  string          `json:"created_by"`
+	UserID     string          `json:"user_id,omitempty"` // 投稿したユーザーのID（匿名の場合は空）
+	Likes      int             `json:"likes"`
+	LikedByMe  bool            `json:"liked_by_me"`          // リクエストした投票者がいいね済みか（保存はしない）
+	Moderation ModerationState `json:"moderation,omitempty"` // 公開状態（空なら公開中）
 }
 
 // DataStore はデータ操作のためのインターフェース
 type DataStore interface {
 	// お題関連
 	GetTheme(id string) (*Theme, error)
-	ListThemes() ([]*Theme, error)
+	// ListThemes は条件に合うお題の1ページ分と、次のページのカーソル（最後のページなら空）を返す
+	ListThemes(opts ListOptions) ([]*Theme, string, error)
 	CreateTheme(theme *Theme) error
+	// UpdateTheme はお題を保存して theme.Version を1増やす
+	// theme.Version が保存されているものと違う（読み込んだ後に更新された）場合は ErrVersionConflict を返す
 	UpdateTheme(theme *Theme) error
-	DeleteTheme(id string) error
+	// DeleteTheme はお題とその回答を削除する
+	// version が0でなく、保存されているものと違う場合は削除せずに ErrVersionConflict を返す（0なら確認しない）
+	DeleteTheme(id string, version int64) error
 
 	// 回答関連
 	GetAnswer(id string, themeID string) (*Answer, error)
-	ListAnswers(themeID string) ([]*Answer, error)
+	// ListAnswers は条件に合う回答の1ページ分と、次のページのカーソル（最後のページなら空）を返す
+	ListAnswers(themeID string, opts ListOptions) ([]*Answer, string, error)
 	CreateAnswer(answer *Answer) error
+	// UpdateAnswer は回答を保存して answer.Version を1増やす
+	// answer.Version が保存されているものと違う場合は ErrVersionConflict を返す
 	UpdateAnswer(answer *Answer) error
-	DeleteAnswer(id string, themeID string) error
+	// DeleteAnswer は回答を削除する（version の扱いは DeleteTheme と同じ）
+	DeleteAnswer(id string, themeID string, version int64) error
+
+	// いいね関連
+	// LikeAnswer は投票者のいいねを記録していいね数を1増やす（同じ投票者は1回まで）
+	LikeAnswer(id string, themeID string, voterID string) (*Answer, error)
+	// UnlikeAnswer は投票者のいいねを取り消していいね数を1減らす
+	UnlikeAnswer(id string, themeID string, voterID string) (*Answer, error)
+	// HasLiked は投票者が回答にいいね済みか判定する
+	HasLiked(id string, voterID string) (bool, error)
+
+	// 通報関連
+	// CreateReport は回答への通報を保存する（同じ通報者は1つの回答に1回まで）
+	CreateReport(report *Report) error
+	// ListReports は未対応の通報を古い順に返す
+	ListReports() ([]*Report, error)
+	// DeleteReports は回答への通報を全て削除して対応済みにする
+	DeleteReports(answerID string) error
+
+	// 移行関連
+	// AnswerExists はどのお題かによらず、同じIDの回答があるか判定する
+	AnswerExists(id string) (bool, error)
+	// Import はエクスポートしたお題と回答を、IDや日時、審査状態もそのままにまとめて保存する（Version は1にする）
+	// いいね数も保存するが、誰がいいねしたかは残らない。IDが空なら新しいIDを振り、batch に保存したIDを入れる
+	// 同じIDのお題・回答があれば（別のお題の回答でも）ErrExists、回答のお題が無ければ ErrNotFound を返す
+	// 全てを保存するか、エラーなら何も保存しない
+	Import(batch *ImportBatch) error
+
+	// ユーザー・セッション関連
+	GetUser(id string) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	CreateUser(user *User) error
+	UpdateUser(user *User) error
+	// CountUsers は役割が role のユーザーの数を返す
+	CountUsers(role Role) (int, error)
+	CreateSession(session *Session) error
+	GetSession(tokenHash string) (*Session, error)
+	DeleteSession(tokenHash string) error
+
+	// 状態確認・終了処理
+	// Ping はストアが読み書きできる状態か確認する
+	Ping() error
+	// Close は未保存の変更を書き出してファイルなどを解放する。呼び出した後はストアを使わないこと
+	Close() error
 }
 
 // InMemoryStore はメモリ内にデータを保持する実装
@@ -58,13 +126,28 @@ type InMemoryStore struct {
 	answers      map[string]map[string]*Answer
 	themesMutex  sync.RWMutex
 	answersMutex sync.RWMutex
+	likes        map[string]map[string]bool    // 回答ID -> 投票者ID（answersMutexで保護）
+	reports      map[string]map[string]*Report // 回答ID -> 通報者ID -> 通報（answersMutexで保護）
+	users        map[string]*User
+	sessions     map[string]*Session
+	usersMutex   sync.RWMutex
+	nextThemeID  int
+	nextAnswerID int
+	nextUserID   int
 }
 
 // NewInMemoryStore は新しいInMemoryStoreインスタンスを返す
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
-		themes:  make(map[string]*Theme),
-		answers: make(map[string]map[string]*Answer),
+		themes:       make(map[string]*Theme),
+		answers:      make(map[string]map[string]*Answer),
+		likes:        make(map[string]map[string]bool),
+		reports:      make(map[string]map[string]*Report),
+		users:        make(map[string]*User),
+		sessions:     make(map[string]*Session),
+		nextThemeID:  1,
+		nextAnswerID: 1,
+		nextUserID:   1,
 	}
 }
 
@@ -81,15 +164,17 @@ func (s *InMemoryStore) GetTheme(id string) (*Theme, error) {
 }
 
 // ListThemes は全てのテーマをリストアップ
-func (s *InMemoryStore) ListThemes() ([]*Theme, error) {
+func (s *InMemoryStore) ListThemes(opts ListOptions) ([]*Theme, string, error) {
 	s.themesMutex.RLock()
 	defer s.themesMutex.RUnlock()
 
 	themes := make([]*Theme, 0, len(s.themes))
 	for _, theme := range s.themes {
-		themes = append(themes, theme)
+		if opts.matchTheme(theme) {
+			themes = append(themes, theme)
+		}
 	}
-	return themes, nil
+	return paginate(themes, opts, opts.themeKey)
 }
 
 // CreateTheme は新しいテーマを作成
@@ -97,6 +182,17 @@ func (s *InMemoryStore) CreateTheme(theme *Theme) error {
 	s.themesMutex.Lock()
 	defer s.themesMutex.Unlock()
 
+	// IDを自動生成（JSONStoreと同じ形式）
+	theme.ID = fmt.Sprintf("theme_%d", s.nextThemeID)
+	theme.CreatedAt = time.Now()
+	theme.UpdatedAt = theme.CreatedAt
+	theme.Version = 1
+	// 審査状態の指定が無ければ承認済みとして扱う
+	if theme.SubmissionStatus == "" {
+		theme.SubmissionStatus = SubmissionApproved
+	}
+	s.nextThemeID++
+
 	s.themes[theme.ID] = theme
 	return nil
 }
@@ -106,26 +202,43 @@ func (s *InMemoryStore) UpdateTheme(theme *Theme) error {
 	s.themesMutex.Lock()
 	defer s.themesMutex.Unlock()
 
-	_, exists := s.themes[theme.ID]
+	existing, exists := s.themes[theme.ID]
 	if !exists {
 		return ErrNotFound
 	}
+	if existing.Version != theme.Version {
+		return ErrVersionConflict
+	}
 
+	theme.Version++
 	s.themes[theme.ID] = theme
 	return nil
 }
 
 // DeleteTheme はテーマを削除
-func (s *InMemoryStore) DeleteTheme(id string) error {
+func (s *InMemoryStore) DeleteTheme(id string, version int64) error {
 	s.themesMutex.Lock()
 	defer s.themesMutex.Unlock()
 
-	_, exists := s.themes[id]
+	theme, exists := s.themes[id]
 	if !exists {
 		return ErrNotFound
 	}
+	if version != 0 && theme.Version != version {
+		return ErrVersionConflict
+	}
 
 	delete(s.themes, id)
+
+	// 関連する回答も削除
+	s.answersMutex.Lock()
+	for answerID := range s.answers[id] {
+		delete(s.likes, answerID)
+		delete(s.reports, answerID)
+	}
+	delete(s.answers, id)
+	s.answersMutex.Unlock()
+
 	return nil
 }
 
@@ -148,31 +261,44 @@ func (s *InMemoryStore) GetAnswer(id string, themeID string) (*Answer, error) {
 }
 
 // ListAnswers はテーマに対する全ての回答を取得
-func (s *InMemoryStore) ListAnswers(themeID string) ([]*Answer, error) {
+func (s *InMemoryStore) ListAnswers(themeID string, opts ListOptions) ([]*Answer, string, error) {
 	s.answersMutex.RLock()
 	defer s.answersMutex.RUnlock()
 
-	themeAnswers, exists := s.answers[themeID]
-	if !exists {
-		return []*Answer{}, nil
-	}
-
+	themeAnswers := s.answers[themeID]
 	answers := make([]*Answer, 0, len(themeAnswers))
 	for _, answer := range themeAnswers {
-		answers = append(answers, answer)
+		if opts.matchAnswer(answer) {
+			answers = append(answers, answer)
+		}
 	}
-	return answers, nil
+	return paginate(answers, opts, opts.answerKey)
 }
 
 // CreateAnswer は新しい回答を作成
 func (s *InMemoryStore) CreateAnswer(answer *Answer) error {
+	s.themesMutex.RLock()
+	defer s.themesMutex.RUnlock()
+
+	// お題の存在確認
+	if _, exists := s.themes[answer.ThemeID]; !exists {
+		return ErrNotFound
+	}
+
 	s.answersMutex.Lock()
 	defer s.answersMutex.Unlock()
 
+	// IDを自動生成（JSONStoreと同じ形式）
+	answer.ID = fmt.Sprintf("answer_%d", s.nextAnswerID)
+	answer.CreatedAt = time.Now()
+	answer.UpdatedAt = answer.CreatedAt
+	s.nextAnswerID++
+
 	if _, exists := s.answers[answer.ThemeID]; !exists {
 		s.answers[answer.ThemeID] = make(map[string]*Answer)
 	}
 
+	answer.Version = 1
 	s.answers[answer.ThemeID][answer.ID] = answer
 	return nil
 }
@@ -187,17 +313,23 @@ func (s *InMemoryStore) UpdateAnswer(answer *Answer) error {
 		return ErrNotFound
 	}
 
-	_, exists = themeAnswers[answer.ID]
+	existing, exists := themeAnswers[answer.ID]
 	if !exists {
 		return ErrNotFound
 	}
+	if existing.Version != answer.Version {
+		return ErrVersionConflict
+	}
 
+	// いいね数はLikeAnswer/UnlikeAnswerでのみ変更する
+	answer.Likes = existing.Likes
+	answer.Version++
 	themeAnswers[answer.ID] = answer
 	return nil
 }
 
 // DeleteAnswer は回答を削除
-func (s *InMemoryStore) DeleteAnswer(id string, themeID string) error {
+func (s *InMemoryStore) DeleteAnswer(id string, themeID string, version int64) error {
 	s.answersMutex.Lock()
 	defer s.answersMutex.Unlock()
 
@@ -206,74 +338,205 @@ func (s *InMemoryStore) DeleteAnswer(id string, themeID string) error {
 		return ErrNotFound
 	}
 
-	_, exists = themeAnswers[id]
+	answer, exists := themeAnswers[id]
 	if !exists {
 		return ErrNotFound
 	}
+	if version != 0 && answer.Version != version {
+		return ErrVersionConflict
+	}
 
 	delete(themeAnswers, id)
+	delete(s.likes, id)
+	delete(s.reports, id)
+	return nil
+}
+
+// LikeAnswer は回答にいいねする
+func (s *InMemoryStore) LikeAnswer(id string, themeID string, voterID string) (*Answer, error) {
+	s.answersMutex.Lock()
+	defer s.answersMutex.Unlock()
+
+	answer, exists := s.answers[themeID][id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	if s.likes[id][voterID] {
+		return nil, ErrAlreadyLiked
+	}
+
+	if s.likes[id] == nil {
+		s.likes[id] = make(map[string]bool)
+	}
+	s.likes[id][voterID] = true
+	// 読み出したポインタはロックの外でも参照されるため、コピーを更新して置き換える
+	liked := *answer
+	liked.Likes++
+	liked.Version++
+	s.answers[themeID][id] = &liked
+
+	result := liked
+	return &result, nil
+}
+
+// UnlikeAnswer は回答へのいいねを取り消す
+func (s *InMemoryStore) UnlikeAnswer(id string, themeID string, voterID string) (*Answer, error) {
+	s.answersMutex.Lock()
+	defer s.answersMutex.Unlock()
+
+	answer, exists := s.answers[themeID][id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	if !s.likes[id][voterID] {
+		return nil, ErrNotLiked
+	}
+
+	delete(s.likes[id], voterID)
+	unliked := *answer
+	unliked.Likes--
+	unliked.Version++
+	s.answers[themeID][id] = &unliked
+
+	result := unliked
+	return &result, nil
+}
+
+// HasLiked は投票者が回答にいいね済みか判定する
+func (s *InMemoryStore) HasLiked(id string, voterID string) (bool, error) {
+	s.answersMutex.RLock()
+	defer s.answersMutex.RUnlock()
+
+	return s.likes[id][voterID], nil
+}
+
+// Ping implements DataStore
+func (s *InMemoryStore) Ping() error {
+	return nil
+}
+
+// Close implements DataStore（メモリ内のデータは破棄される）
+func (s *InMemoryStore) Close() error {
 	return nil
 }
 
 // JSONファイル用のデータ構造
 type JSONData struct {
-	Themes       map[string]*Theme  `json:"themes"`
-	Answers      map[string]*Answer `json:"answers"`
-	NextThemeID  int               `json:"next_theme_id"`
-	NextAnswerID int               `json:"next_answer_id"`
+	Themes       map[string]*Theme             `json:"themes"`
+	Answers      map[string]*Answer            `json:"answers"`
+	NextThemeID  int                           `json:"next_theme_id"`
+	NextAnswerID int                           `json:"next_answer_id"`
+	NextUserID   int                           `json:"next_user_id"`
+	Likes        map[string]map[string]bool    `json:"likes,omitempty"` // 回答ID -> 投票者ID
+	Users        map[string]*User              `json:"users,omitempty"`
+	Sessions     map[string]*Session           `json:"sessions,omitempty"` // トークンのハッシュ -> セッション
+	Reports      map[string]map[string]*Report `json:"reports,omitempty"`  // 回答ID -> 通報者ID -> 通報
 }
 
 // JSONファイルベースのデータストア
+// 変更はジャーナル（<filePath>.journal）に追記し、一定件数ごとにスナップショット（filePath）へ書き戻す
 type JSONStore struct {
 	mu           sync.RWMutex
 	themes       map[string]*Theme
 	answers      map[string]*Answer
+	likes        map[string]map[string]bool
+	reports      map[string]map[string]*Report
+	users        map[string]*User
+	sessions     map[string]*Session
 	filePath     string
 	nextThemeID  int
 	nextAnswerID int
+	nextUserID   int
+	journal      *os.File
+	journalCount int
+	lock         *os.File // 他のプロセスが同じデータファイルを開かないよう、閉じるまでロックを取る
 }
 
 // 新しいJSONストアを作成
-func NewJSONStore(filePath string) *JSONStore {
+// データファイルが壊れている場合は直前のスナップショット（.bak）に戻し、それも読めなければエラーを返す
+// 他のプロセスが同じデータファイルを開いていれば ErrLocked を返す
+func NewJSONStore(filePath string) (*JSONStore, error) {
+	lock, err := acquireLock(filePath)
+	if err != nil {
+		return nil, err
+	}
 	store := &JSONStore{
 		themes:       make(map[string]*Theme),
 		answers:      make(map[string]*Answer),
+		likes:        make(map[string]map[string]bool),
+		reports:      make(map[string]map[string]*Report),
+		users:        make(map[string]*User),
+		sessions:     make(map[string]*Session),
 		filePath:     filePath,
 		nextThemeID:  1,
 		nextAnswerID: 1,
+		nextUserID:   1,
+		lock:         lock,
 	}
-	
+
 	// ファイルからデータを読み込み
-	store.loadFromFile()
-	
-	return store
+	if err := store.loadFromFile(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	return store, nil
 }
 
-// ファイルからデータを読み込み
+func (s *JSONStore) journalPath() string { return s.filePath + ".journal" }
+func (s *JSONStore) backupPath() string  { return s.filePath + ".bak" }
+
+// readSnapshot はスナップショットファイルを読み込んで解析する
+func readSnapshot(path string) (*JSONData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonData JSONData
+	if err := json.Unmarshal(data, &jsonData); err != nil {
+		return nil, fmt.Errorf("JSON解析エラー: %w", err)
+	}
+	return &jsonData, nil
+}
+
+// ファイルからデータを読み込み、ジャーナルを再生する
 func (s *JSONStore) loadFromFile() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	// ファイルが存在しない場合は何もしない
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		return nil
+
+	jsonData, err := readSnapshot(s.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		slog.Warn("データファイルを読み込めません", "path", s.filePath, "error", err)
 	}
-	
-	data, err := os.ReadFile(s.filePath)
 	if err != nil {
-		return fmt.Errorf("ファイル読み込みエラー: %w", err)
+		// 書き戻し途中で停止した場合や、ファイルが壊れている場合は直前のスナップショットを使う
+		backup, backupErr := readSnapshot(s.backupPath())
+		switch {
+		case backupErr == nil:
+			slog.Warn("直前のスナップショットから復元します", "path", s.backupPath())
+			jsonData = backup
+		case os.IsNotExist(err) && os.IsNotExist(backupErr):
+			// どちらも存在しない場合は新規作成
+		case os.IsNotExist(backupErr):
+			return fmt.Errorf("ファイル読み込みエラー: %w", err)
+		default:
+			return fmt.Errorf("ファイル読み込みエラー: %v（バックアップも読み込めません: %v）", err, backupErr)
+		}
 	}
-	
-	var jsonData JSONData
-	if err := json.Unmarshal(data, &jsonData); err != nil {
-		return fmt.Errorf("JSON解析エラー: %w", err)
+
+	if jsonData != nil {
+		s.themes = jsonData.Themes
+		s.answers = jsonData.Answers
+		s.nextThemeID = jsonData.NextThemeID
+		s.nextAnswerID = jsonData.NextAnswerID
+		s.nextUserID = jsonData.NextUserID
+		s.likes = jsonData.Likes
+		s.users = jsonData.Users
+		s.sessions = jsonData.Sessions
+		s.reports = jsonData.Reports
 	}
-	
-	s.themes = jsonData.Themes
-	s.answers = jsonData.Answers
-	s.nextThemeID = jsonData.NextThemeID
-	s.nextAnswerID = jsonData.NextAnswerID
-	
+
 	// nilマップの初期化
 	if s.themes == nil {
 		s.themes = make(map[string]*Theme)
@@ -281,31 +544,195 @@ func (s *JSONStore) loadFromFile() error {
 	if s.answers == nil {
 		s.answers = make(map[string]*Answer)
 	}
-	
+	if s.likes == nil {
+		s.likes = make(map[string]map[string]bool)
+	}
+	if s.users == nil {
+		s.users = make(map[string]*User)
+	}
+	if s.sessions == nil {
+		s.sessions = make(map[string]*Session)
+	}
+	if s.reports == nil {
+		s.reports = make(map[string]map[string]*Report)
+	}
+	if s.nextThemeID < 1 {
+		s.nextThemeID = 1
+	}
+	if s.nextAnswerID < 1 {
+		s.nextAnswerID = 1
+	}
+	if s.nextUserID < 1 {
+		s.nextUserID = 1
+	}
+
+	// スナップショット以降の変更をジャーナルから再生
+	entries, err := readJournal(s.journalPath())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.apply(entry)
+	}
+	// 審査の導入前に作成されたお題は承認済みとして扱う
+	for _, theme := range s.themes {
+		if theme.SubmissionStatus == "" {
+			theme.SubmissionStatus = SubmissionApproved
+		}
+	}
+
+	s.journal, err = os.OpenFile(s.journalPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("ジャーナル作成エラー: %w", err)
+	}
+	s.journalCount = len(entries)
+
+	// 再生した内容をスナップショットへ書き戻す
+	if s.journalCount > 0 {
+		return s.compact()
+	}
 	return nil
 }
 
 // ファイルにデータを保存
+// 既存のスナップショットは.bakに退避してから、一時ファイル経由で置き換える
 func (s *JSONStore) saveToFile() error {
 	jsonData := JSONData{
 		Themes:       s.themes,
 		Answers:      s.answers,
 		NextThemeID:  s.nextThemeID,
 		NextAnswerID: s.nextAnswerID,
+		NextUserID:   s.nextUserID,
+		Likes:        s.likes,
+		Users:        s.users,
+		Sessions:     s.sessions,
+		Reports:      s.reports,
 	}
-	
+
 	data, err := json.MarshalIndent(jsonData, "", "  ")
 	if err != nil {
 		return fmt.Errorf("JSON変換エラー: %w", err)
 	}
-	
-	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
+
+	if err := os.Rename(s.filePath, s.backupPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("バックアップ作成エラー: %w", err)
+	}
+	if err := writeFileAtomic(s.filePath, data, 0644); err != nil {
 		return fmt.Errorf("ファイル書き込みエラー: %w", err)
 	}
-	
+
+	return nil
+}
+
+// compact はスナップショットを書き出してジャーナルを空にする
+func (s *JSONStore) compact() error {
+	if err := s.saveToFile(); err != nil {
+		return err
+	}
+	if err := s.journal.Truncate(0); err != nil {
+		return fmt.Errorf("ジャーナル切り詰めエラー: %w", err)
+	}
+	s.journalCount = 0
+	return nil
+}
+
+// record は変更をジャーナルに書き込んでからメモリ上のデータに反映する
+// 呼び出し側でs.muのロックを取得していること
+func (s *JSONStore) record(entry journalEntry) error {
+	if s.journal == nil {
+		return ErrClosed
+	}
+	if err := appendJournal(s.journal, entry); err != nil {
+		return err
+	}
+	s.apply(entry)
+	s.journalCount++
+
+	// 変更はジャーナルで永続化済みなので、書き戻しの失敗はログに残すだけにする
+	if s.journalCount >= journalCompactThreshold {
+		if err := s.compact(); err != nil {
+			slog.Error("スナップショットの書き戻しに失敗しました", "path", s.filePath, "error", err)
+		}
+	}
 	return nil
 }
 
+// apply はジャーナルのエントリをメモリ上のデータに反映する
+func (s *JSONStore) apply(entry journalEntry) {
+	switch entry.Op {
+	case opPutTheme:
+		s.themes[entry.Theme.ID] = entry.Theme
+	case opDeleteTheme:
+		delete(s.themes, entry.ID)
+		// 関連する回答も削除
+		for answerID, answer := range s.answers {
+			if answer.ThemeID == entry.ID {
+				delete(s.answers, answerID)
+				delete(s.likes, answerID)
+				delete(s.reports, answerID)
+			}
+		}
+	case opPutAnswer:
+		s.answers[entry.Answer.ID] = entry.Answer
+	case opDeleteAnswer:
+		delete(s.answers, entry.ID)
+		delete(s.likes, entry.ID)
+		delete(s.reports, entry.ID)
+	case opLike:
+		if answer, exists := s.answers[entry.ID]; exists && !s.likes[entry.ID][entry.Voter] {
+			if s.likes[entry.ID] == nil {
+				s.likes[entry.ID] = make(map[string]bool)
+			}
+			s.likes[entry.ID][entry.Voter] = true
+			// 読み出したポインタはロックの外でも参照されるため、コピーを更新して置き換える
+			liked := *answer
+			liked.Likes++
+			liked.Version++
+			s.answers[entry.ID] = &liked
+		}
+	case opUnlike:
+		if answer, exists := s.answers[entry.ID]; exists && s.likes[entry.ID][entry.Voter] {
+			delete(s.likes[entry.ID], entry.Voter)
+			unliked := *answer
+			unliked.Likes--
+			unliked.Version++
+			s.answers[entry.ID] = &unliked
+		}
+	case opPutUser:
+		s.users[entry.User.ID] = entry.User
+	case opPutSession:
+		s.sessions[entry.Session.TokenHash] = entry.Session
+	case opDeleteSession:
+		delete(s.sessions, entry.ID)
+	case opPutReport:
+		if _, exists := s.answers[entry.Report.AnswerID]; exists {
+			if s.reports[entry.Report.AnswerID] == nil {
+				s.reports[entry.Report.AnswerID] = make(map[string]*Report)
+			}
+			s.reports[entry.Report.AnswerID][entry.Report.ReporterID] = entry.Report
+		}
+	case opDeleteReports:
+		delete(s.reports, entry.ID)
+	case opImport:
+		for _, theme := range entry.Themes {
+			s.themes[theme.ID] = theme
+		}
+		for _, answer := range entry.Answers {
+			s.answers[answer.ID] = answer
+		}
+	}
+
+	if entry.NextThemeID > s.nextThemeID {
+		s.nextThemeID = entry.NextThemeID
+	}
+	if entry.NextAnswerID > s.nextAnswerID {
+		s.nextAnswerID = entry.NextAnswerID
+	}
+	if entry.NextUserID > s.nextUserID {
+		s.nextUserID = entry.NextUserID
+	}
+}
+
 // GetTheme implements DataStore
 func (s *JSONStore) GetTheme(id string) (*Theme, error) {
 	s.mu.RLock()
@@ -320,16 +747,18 @@ func (s *JSONStore) GetTheme(id string) (*Theme, error) {
 }
 
 // ListThemes implements DataStore
-func (s *JSONStore) ListThemes() ([]*Theme, error) {
+func (s *JSONStore) ListThemes(opts ListOptions) ([]*Theme, string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
 	themes := make([]*Theme, 0, len(s.themes))
 	for _, theme := range s.themes {
-		themes = append(themes, theme)
+		if opts.matchTheme(theme) {
+			themes = append(themes, theme)
+		}
 	}
 	
-	return themes, nil
+	return paginate(themes, opts, opts.themeKey)
 }
 
 // CreateTheme implements DataStore
@@ -341,13 +770,14 @@ func (s *JSONStore) CreateTheme(theme *Theme) error {
 	theme.ID = fmt.Sprintf("theme_%d", s.nextThemeID)
 	theme.CreatedAt = time.Now()
 	theme.UpdatedAt = time.Now()
-	theme.Active = true
-	
-	s.themes[theme.ID] = theme
-	s.nextThemeID++
+	theme.Version = 1
+	// 審査状態の指定が無ければ承認済みとして扱う
+	if theme.SubmissionStatus == "" {
+		theme.SubmissionStatus = SubmissionApproved
+	}
 	
-	// ファイルに保存
-	return s.saveToFile()
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opPutTheme, Theme: theme, NextThemeID: s.nextThemeID + 1})
 }
 
 // UpdateTheme implements DataStore
@@ -355,37 +785,36 @@ func (s *JSONStore) UpdateTheme(theme *Theme) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
-	if _, exists := s.themes[theme.ID]; !exists {
+	existing, exists := s.themes[theme.ID]
+	if !exists {
 		return ErrNotFound
 	}
+	if existing.Version != theme.Version {
+		return ErrVersionConflict
+	}
 	
 	theme.UpdatedAt = time.Now()
-	s.themes[theme.ID] = theme
+	theme.Version++
 	
-	// ファイルに保存
-	return s.saveToFile()
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opPutTheme, Theme: theme})
 }
 
 // DeleteTheme implements DataStore
-func (s *JSONStore) DeleteTheme(id string) error {
+func (s *JSONStore) DeleteTheme(id string, version int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
-	if _, exists := s.themes[id]; !exists {
+	theme, exists := s.themes[id]
+	if !exists {
 		return ErrNotFound
 	}
-	
-	delete(s.themes, id)
-	
-	// 関連する回答も削除
-	for answerID, answer := range s.answers {
-		if answer.ThemeID == id {
-			delete(s.answers, answerID)
-		}
+	if version != 0 && theme.Version != version {
+		return ErrVersionConflict
 	}
 	
-	// ファイルに保存
-	return s.saveToFile()
+	// 関連する回答の削除はapplyで行う
+	return s.record(journalEntry{Op: opDeleteTheme, ID: id})
 }
 
 // GetAnswer implements DataStore
@@ -402,18 +831,18 @@ func (s *JSONStore) GetAnswer(id string, themeID string) (*Answer, error) {
 }
 
 // ListAnswers implements DataStore
-func (s *JSONStore) ListAnswers(themeID string) ([]*Answer, error) {
+func (s *JSONStore) ListAnswers(themeID string, opts ListOptions) ([]*Answer, string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
 	answers := make([]*Answer, 0)
 	for _, answer := range s.answers {
-		if answer.ThemeID == themeID {
+		if answer.ThemeID == themeID && opts.matchAnswer(answer) {
 			answers = append(answers, answer)
 		}
 	}
 	
-	return answers, nil
+	return paginate(answers, opts, opts.answerKey)
 }
 
 // CreateAnswer implements DataStore
@@ -430,12 +859,10 @@ func (s *JSONStore) CreateAnswer(answer *Answer) error {
 	answer.ID = fmt.Sprintf("answer_%d", s.nextAnswerID)
 	answer.CreatedAt = time.Now()
 	answer.UpdatedAt = time.Now()
+	answer.Version = 1
 	
-	s.answers[answer.ID] = answer
-	s.nextAnswerID++
-	
-	// ファイルに保存
-	return s.saveToFile()
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opPutAnswer, Answer: answer, NextAnswerID: s.nextAnswerID + 1})
 }
 
 // UpdateAnswer implements DataStore
@@ -447,16 +874,21 @@ func (s *JSONStore) UpdateAnswer(answer *Answer) error {
 	if !exists || existing.ThemeID != answer.ThemeID {
 		return ErrNotFound
 	}
+	if existing.Version != answer.Version {
+		return ErrVersionConflict
+	}
 	
+	// いいね数はLikeAnswer/UnlikeAnswerでのみ変更する
+	answer.Likes = existing.Likes
 	answer.UpdatedAt = time.Now()
-	s.answers[answer.ID] = answer
+	answer.Version++
 	
-	// ファイルに保存
-	return s.saveToFile()
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opPutAnswer, Answer: answer})
 }
 
 // DeleteAnswer implements DataStore
-func (s *JSONStore) DeleteAnswer(id string, themeID string) error {
+func (s *JSONStore) DeleteAnswer(id string, themeID string, version int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	
@@ -464,9 +896,111 @@ func (s *JSONStore) DeleteAnswer(id string, themeID string) error {
 	if !exists || answer.ThemeID != themeID {
 		return ErrNotFound
 	}
+	if version != 0 && answer.Version != version {
+		return ErrVersionConflict
+	}
 	
-	delete(s.answers, id)
-	
-	// ファイルに保存
-	return s.saveToFile()
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opDeleteAnswer, ID: id})
+}
+
+// LikeAnswer implements DataStore
+func (s *JSONStore) LikeAnswer(id string, themeID string, voterID string) (*Answer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	answer, exists := s.answers[id]
+	if !exists || answer.ThemeID != themeID {
+		return nil, ErrNotFound
+	}
+	if s.likes[id][voterID] {
+		return nil, ErrAlreadyLiked
+	}
+
+	// ジャーナルに記録（いいね数の加算はapplyで行う）
+	if err := s.record(journalEntry{Op: opLike, ID: id, Voter: voterID}); err != nil {
+		return nil, err
+	}
+
+	liked := *s.answers[id]
+	return &liked, nil
+}
+
+// UnlikeAnswer implements DataStore
+func (s *JSONStore) UnlikeAnswer(id string, themeID string, voterID string) (*Answer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	answer, exists := s.answers[id]
+	if !exists || answer.ThemeID != themeID {
+		return nil, ErrNotFound
+	}
+	if !s.likes[id][voterID] {
+		return nil, ErrNotLiked
+	}
+
+	// ジャーナルに記録（いいね数の減算はapplyで行う）
+	if err := s.record(journalEntry{Op: opUnlike, ID: id, Voter: voterID}); err != nil {
+		return nil, err
+	}
+
+	unliked := *s.answers[id]
+	return &unliked, nil
+}
+
+// HasLiked implements DataStore
+func (s *JSONStore) HasLiked(id string, voterID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.likes[id][voterID], nil
+}
+
+// Ping はジャーナルが開いていて、スナップショットを書き戻せるか確認する
+// 書き戻しは同じディレクトリの一時ファイル経由で行うため、ディレクトリにも書き込める必要がある
+func (s *JSONStore) Ping() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.journal == nil {
+		return ErrClosed
+	}
+	if file, err := os.OpenFile(s.filePath, os.O_WRONLY, 0); err == nil {
+		file.Close()
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("データファイルに書き込めません: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), filepath.Base(s.filePath)+".ping*")
+	if err != nil {
+		return fmt.Errorf("データファイルのディレクトリに書き込めません: %w", err)
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// Close はジャーナルの内容をスナップショットへ書き戻してからジャーナルを閉じる
+func (s *JSONStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return nil
+	}
+	var compactErr error
+	if s.journalCount > 0 {
+		compactErr = s.compact()
+	}
+	closeErr := s.journal.Close()
+	s.journal = nil
+	// ロックはジャーナルを書き戻して閉じてから解除する
+	s.lock.Close()
+	s.lock = nil
+	if compactErr != nil {
+		// 変更はジャーナルに残っているため、次回の起動時に再生される
+		return fmt.Errorf("スナップショットの書き戻しに失敗しました: %w", compactErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("ジャーナルのクローズに失敗しました: %w", closeErr)
+	}
+	return nil
 }
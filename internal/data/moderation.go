@@ -0,0 +1,24 @@
+package data
+
+// ModerationState は回答の公開状態
+// お題の公開は SubmissionStatus で管理する
+type ModerationState string
+
+const (
+	ModerationNone   ModerationState = ""       // 公開中
+	ModerationReview ModerationState = "review" // NGワードを含むため確認待ち（一般のユーザーには表示しない）
+	ModerationHidden ModerationState = "hidden" // moderator が非表示にした（一般のユーザーには表示しない）
+)
+
+// AllModerationStates は回答の一覧取得で全ての公開状態を対象にするときに ListOptions.Moderation に指定する
+var AllModerationStates = []ModerationState{ModerationNone, ModerationReview, ModerationHidden}
+
+// Valid は定義済みの公開状態か判定する
+func (s ModerationState) Valid() bool {
+	for _, state := range AllModerationStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
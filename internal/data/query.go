@@ -0,0 +1,188 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("カーソルが不正です")
+)
+
+// 一覧取得で指定できる並び替えキー
+const (
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+	SortByLikes     = "likes" // 回答のみ
+)
+
+// ListOptions は一覧取得時のページング・並び替え・絞り込み条件
+type ListOptions struct {
+	Limit         int         // 0 の場合は件数を制限しない
+	Cursor        string      // 前のページの next_cursor
+	SortBy        string      // 空の場合は created_at
+	Desc          bool        // true なら降順
+	Active        *bool       // お題のみ。nil なら絞り込まない
+	Status        ThemeStatus // お題のみ。空なら絞り込まない
+	CreatedBy     string      // 空なら絞り込まない
+	CreatedBefore time.Time   // ゼロ値なら絞り込まない
+	CreatedAfter  time.Time   // ゼロ値なら絞り込まない
+	// 回答のみ。指定した公開状態のものだけを返す。空なら公開中（ModerationNone）のみ
+	Moderation []ModerationState
+	// お題のみ。指定した審査状態のものだけを返す。空なら承認済み（SubmissionApproved）のみ
+	Submission []SubmissionStatus
+}
+
+// cursor はページの最後の要素の並び替えキーとIDを表す
+type cursor struct {
+	Value int64  `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func (o ListOptions) sortBy() string {
+	if o.SortBy == "" {
+		return SortByCreatedAt
+	}
+	return o.SortBy
+}
+
+// matchCreated は作成者・作成日時の絞り込み条件に合うか判定する
+func (o ListOptions) matchCreated(createdBy string, createdAt time.Time) bool {
+	if o.CreatedBy != "" && createdBy != o.CreatedBy {
+		return false
+	}
+	if !o.CreatedBefore.IsZero() && !createdAt.Before(o.CreatedBefore) {
+		return false
+	}
+	if !o.CreatedAfter.IsZero() && !createdAt.After(o.CreatedAfter) {
+		return false
+	}
+	return true
+}
+
+// matchModeration は公開状態の絞り込み条件に合うか判定する
+func (o ListOptions) matchModeration(state ModerationState) bool {
+	if len(o.Moderation) == 0 {
+		return state == ModerationNone
+	}
+	for _, s := range o.Moderation {
+		if state == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSubmission は審査状態の絞り込み条件に合うか判定する
+func (o ListOptions) matchSubmission(status SubmissionStatus) bool {
+	if len(o.Submission) == 0 {
+		return status == SubmissionApproved
+	}
+	for _, s := range o.Submission {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (o ListOptions) matchTheme(theme *Theme) bool {
+	if !o.matchSubmission(theme.SubmissionStatus) {
+		return false
+	}
+	if o.Active != nil && theme.Active != *o.Active {
+		return false
+	}
+	if o.Status != "" && theme.Status(time.Now()) != o.Status {
+		return false
+	}
+	return o.matchCreated(theme.CreatedBy, theme.CreatedAt)
+}
+
+func (o ListOptions) matchAnswer(answer *Answer) bool {
+	if !o.matchModeration(answer.Moderation) {
+		return false
+	}
+	return o.matchCreated(answer.CreatedBy, answer.CreatedAt)
+}
+
+func (o ListOptions) themeKey(theme *Theme) cursor {
+	switch o.sortBy() {
+	case SortByUpdatedAt:
+		return cursor{Value: theme.UpdatedAt.UnixNano(), ID: theme.ID}
+	default:
+		return cursor{Value: theme.CreatedAt.UnixNano(), ID: theme.ID}
+	}
+}
+
+func (o ListOptions) answerKey(answer *Answer) cursor {
+	switch o.sortBy() {
+	case SortByUpdatedAt:
+		return cursor{Value: answer.UpdatedAt.UnixNano(), ID: answer.ID}
+	case SortByLikes:
+		return cursor{Value: int64(answer.Likes), ID: answer.ID}
+	default:
+		return cursor{Value: answer.CreatedAt.UnixNano(), ID: answer.ID}
+	}
+}
+
+// less は並び順で a が b より前に来るか判定する（同じ値ならIDで比較）
+func (o ListOptions) less(a, b cursor) bool {
+	if o.Desc {
+		a, b = b, a
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.ID < b.ID
+}
+
+// paginate はフィルタ済みの要素を並び替え、カーソル以降の1ページ分と次のカーソルを返す
+// メモリ上にデータを持つストアで共通して使う
+func paginate[T any](items []T, opts ListOptions, key func(T) cursor) ([]T, string, error) {
+	after, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return opts.less(key(items[i]), key(items[j]))
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return opts.less(*after, key(items[i]))
+		})
+	}
+	items = items[start:]
+
+	if opts.Limit <= 0 || len(items) <= opts.Limit {
+		return items, "", nil
+	}
+	page := items[:opts.Limit]
+	return page, encodeCursor(key(page[len(page)-1])), nil
+}
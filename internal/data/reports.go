@@ -0,0 +1,214 @@
+package data
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrAlreadyReported = errors.New("この回答は既に通報済みです")
+)
+
+// ReportReason は通報の理由
+type ReportReason string
+
+const (
+	ReportSpam       ReportReason = "spam"       // 宣伝・荒らし
+	ReportOffensive  ReportReason = "offensive"  // 不快・不適切な表現
+	ReportHarassment ReportReason = "harassment" // 誹謗中傷・嫌がらせ
+	ReportOffTopic   ReportReason = "off_topic"  // お題と無関係
+	ReportOther      ReportReason = "other"      // その他
+)
+
+// ReportReasons は定義済みの通報理由の一覧
+var ReportReasons = []ReportReason{ReportSpam, ReportOffensive, ReportHarassment, ReportOffTopic, ReportOther}
+
+// Valid は定義済みの通報理由か判定する
+func (r ReportReason) Valid() bool {
+	for _, reason := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Report は回答への通報を表す構造体
+// 同じ通報者は1つの回答に1回まで通報できる
+// ReporterID は投票者IDと同じ形式で X-Client-Token を含むため、APIのレスポンスにそのまま使わないこと
+type Report struct {
+	AnswerID   string       `json:"answer_id"`
+	ThemeID    string       `json:"theme_id"`
+	ReporterID string       `json:"reporter_id"`
+	Reason     ReportReason `json:"reason"`
+	Comment    string       `json:"comment,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// sortReports は通報を古い順に並べる
+func sortReports(reports []*Report) {
+	sort.Slice(reports, func(i, j int) bool {
+		a, b := reports[i], reports[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.AnswerID != b.AnswerID {
+			return a.AnswerID < b.AnswerID
+		}
+		return a.ReporterID < b.ReporterID
+	})
+}
+
+// ---------- InMemoryStore ----------
+
+// CreateReport は回答への通報を保存
+func (s *InMemoryStore) CreateReport(report *Report) error {
+	s.answersMutex.Lock()
+	defer s.answersMutex.Unlock()
+
+	if _, exists := s.answers[report.ThemeID][report.AnswerID]; !exists {
+		return ErrNotFound
+	}
+	if _, exists := s.reports[report.AnswerID][report.ReporterID]; exists {
+		return ErrAlreadyReported
+	}
+
+	report.CreatedAt = time.Now()
+	if s.reports[report.AnswerID] == nil {
+		s.reports[report.AnswerID] = make(map[string]*Report)
+	}
+	stored := *report
+	s.reports[report.AnswerID][report.ReporterID] = &stored
+	return nil
+}
+
+// ListReports は未対応の通報を古い順に取得
+func (s *InMemoryStore) ListReports() ([]*Report, error) {
+	s.answersMutex.RLock()
+	defer s.answersMutex.RUnlock()
+
+	return collectReports(s.reports), nil
+}
+
+// DeleteReports は回答への通報を全て削除（対応済みにする）
+func (s *InMemoryStore) DeleteReports(answerID string) error {
+	s.answersMutex.Lock()
+	defer s.answersMutex.Unlock()
+
+	delete(s.reports, answerID)
+	return nil
+}
+
+// collectReports は回答ID -> 通報者ID -> 通報のマップから通報のコピーを古い順に取り出す
+func collectReports(byAnswer map[string]map[string]*Report) []*Report {
+	reports := make([]*Report, 0)
+	for _, byReporter := range byAnswer {
+		for _, report := range byReporter {
+			copied := *report
+			reports = append(reports, &copied)
+		}
+	}
+	sortReports(reports)
+	return reports
+}
+
+// ---------- JSONStore ----------
+
+// CreateReport implements DataStore
+func (s *JSONStore) CreateReport(report *Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	answer, exists := s.answers[report.AnswerID]
+	if !exists || answer.ThemeID != report.ThemeID {
+		return ErrNotFound
+	}
+	if _, exists := s.reports[report.AnswerID][report.ReporterID]; exists {
+		return ErrAlreadyReported
+	}
+
+	report.CreatedAt = time.Now()
+	stored := *report
+	return s.record(journalEntry{Op: opPutReport, Report: &stored})
+}
+
+// ListReports implements DataStore
+func (s *JSONStore) ListReports() ([]*Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return collectReports(s.reports), nil
+}
+
+// DeleteReports implements DataStore
+func (s *JSONStore) DeleteReports(answerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.reports[answerID]) == 0 {
+		return nil
+	}
+	return s.record(journalEntry{Op: opDeleteReports, ID: answerID})
+}
+
+// ---------- SQLiteStore ----------
+
+const reportColumns = `answer_id, theme_id, reporter_id, reason, comment, created_at`
+
+// CreateReport implements DataStore
+func (s *SQLiteStore) CreateReport(report *Report) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM answers WHERE id = ? AND theme_id = ?`, report.AnswerID, report.ThemeID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+
+	report.CreatedAt = time.Now()
+	res, err := tx.Exec(`INSERT OR IGNORE INTO answer_reports (`+reportColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		report.AnswerID, report.ThemeID, report.ReporterID, report.Reason, report.Comment, report.CreatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAlreadyReported
+	}
+	return tx.Commit()
+}
+
+// ListReports implements DataStore
+func (s *SQLiteStore) ListReports() ([]*Report, error) {
+	rows, err := s.db.Query(`SELECT ` + reportColumns + ` FROM answer_reports ORDER BY created_at, answer_id, reporter_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]*Report, 0)
+	for rows.Next() {
+		var report Report
+		var createdAt int64
+		if err := rows.Scan(&report.AnswerID, &report.ThemeID, &report.ReporterID, &report.Reason, &report.Comment, &createdAt); err != nil {
+			return nil, err
+		}
+		report.CreatedAt = time.Unix(0, createdAt)
+		reports = append(reports, &report)
+	}
+	return reports, rows.Err()
+}
+
+// DeleteReports implements DataStore
+func (s *SQLiteStore) DeleteReports(answerID string) error {
+	_, err := s.db.Exec(`DELETE FROM answer_reports WHERE answer_id = ?`, answerID)
+	return err
+}
@@ -0,0 +1,63 @@
+package data
+
+import "time"
+
+// ThemeStatus はお題の受付状態
+type ThemeStatus string
+
+const (
+	ThemeUpcoming ThemeStatus = "upcoming" // 受付開始前
+	ThemeOpen     ThemeStatus = "open"     // 受付中
+	ThemeClosed   ThemeStatus = "closed"   // 受付終了・停止中
+)
+
+// Valid は定義済みの受付状態か判定する
+func (s ThemeStatus) Valid() bool {
+	switch s {
+	case ThemeUpcoming, ThemeOpen, ThemeClosed:
+		return true
+	}
+	return false
+}
+
+// InWindow は時刻が受付期間内か判定する（opens_at・closes_at が無い側は無制限）
+func (t *Theme) InWindow(now time.Time) bool {
+	if t.OpensAt != nil && now.Before(*t.OpensAt) {
+		return false
+	}
+	if t.ClosesAt != nil && !now.Before(*t.ClosesAt) {
+		return false
+	}
+	return true
+}
+
+// Status は時刻 now におけるお題の受付状態を返す
+func (t *Theme) Status(now time.Time) ThemeStatus {
+	if t.OpensAt != nil && now.Before(*t.OpensAt) {
+		return ThemeUpcoming
+	}
+	if t.Active && t.InWindow(now) {
+		return ThemeOpen
+	}
+	return ThemeClosed
+}
+
+// ScheduledActive は受付期間の境界を過ぎていれば、スケジュールに従った Active の値と true を返す
+// 境界を過ぎた後に手動で更新されたお題（UpdatedAt が境界以降）と、承認されていないお題は変更しない
+func (t *Theme) ScheduledActive(now time.Time) (bool, bool) {
+	if !t.Approved() {
+		return t.Active, false
+	}
+	if t.ClosesAt != nil && !now.Before(*t.ClosesAt) {
+		if t.Active && t.UpdatedAt.Before(*t.ClosesAt) {
+			return false, true
+		}
+		return t.Active, false
+	}
+	if t.OpensAt != nil && !now.Before(*t.OpensAt) {
+		if !t.Active && t.UpdatedAt.Before(*t.OpensAt) {
+			return true, true
+		}
+	}
+	return t.Active, false
+}
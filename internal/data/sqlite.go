@@ -0,0 +1,650 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // SQLiteドライバー（cgo不要）
+)
+
+// sqliteMigrations はSQLiteStoreのスキーマ変更履歴
+// PRAGMA user_version に適用済みの件数を記録し、起動時に未適用のものだけを順に実行する
+// 既存の要素は書き換えず、変更は末尾に追加すること
+var sqliteMigrations = []string{
+	// 1: お題・回答
+	`
+CREATE TABLE IF NOT EXISTS counters (
+	name  TEXT PRIMARY KEY,
+	value INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO counters (name, value) VALUES ('theme', 0), ('answer', 0);
+
+CREATE TABLE IF NOT EXISTS themes (
+	id          TEXT PRIMARY KEY,
+	title       TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	created_at  INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL,
+	created_by  TEXT NOT NULL DEFAULT '',
+	active      INTEGER NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_themes_created_at ON themes (created_at);
+
+CREATE TABLE IF NOT EXISTS answers (
+	id         TEXT PRIMARY KEY,
+	theme_id   TEXT NOT NULL REFERENCES themes (id) ON DELETE CASCADE,
+	content    TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	created_by TEXT NOT NULL DEFAULT '',
+	likes      INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_answers_theme_created_at ON answers (theme_id, created_at);
+`,
+	// 2: 一覧の並び替え用インデックス
+	`
+CREATE INDEX IF NOT EXISTS idx_themes_updated_at ON themes (updated_at);
+CREATE INDEX IF NOT EXISTS idx_answers_theme_likes ON answers (theme_id, likes);
+`,
+	// 3: いいね
+	`
+CREATE TABLE IF NOT EXISTS answer_likes (
+	answer_id  TEXT NOT NULL REFERENCES answers (id) ON DELETE CASCADE,
+	voter_id   TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (answer_id, voter_id)
+);
+`,
+	// 4: ユーザーとセッション
+	`
+INSERT OR IGNORE INTO counters (name, value) VALUES ('user', 0);
+
+CREATE TABLE IF NOT EXISTS users (
+	id            TEXT PRIMARY KEY,
+	username      TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at    INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	token_hash TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+ALTER TABLE themes ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE answers ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+`,
+	// 5: ユーザーの役割
+	`
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'player';
+`,
+	// 6: お題の受付期間
+	`
+ALTER TABLE themes ADD COLUMN opens_at INTEGER;
+ALTER TABLE themes ADD COLUMN closes_at INTEGER;
+`,
+	// 7: NGワードによる確認待ち
+	`
+ALTER TABLE themes ADD COLUMN moderation TEXT NOT NULL DEFAULT '';
+ALTER TABLE answers ADD COLUMN moderation TEXT NOT NULL DEFAULT '';
+`,
+	// 8: 回答への通報
+	`
+CREATE TABLE IF NOT EXISTS answer_reports (
+	answer_id   TEXT NOT NULL REFERENCES answers (id) ON DELETE CASCADE,
+	theme_id    TEXT NOT NULL,
+	reporter_id TEXT NOT NULL,
+	reason      TEXT NOT NULL,
+	comment     TEXT NOT NULL DEFAULT '',
+	created_at  INTEGER NOT NULL,
+	PRIMARY KEY (answer_id, reporter_id)
+);
+`,
+	// 9: お題の審査状態
+	// NGワードで確認待ちになっていたお題は審査待ちに移し、themes.moderation を削除する
+	`
+ALTER TABLE themes ADD COLUMN submission_status TEXT NOT NULL DEFAULT 'approved';
+ALTER TABLE themes ADD COLUMN rejection_reason TEXT NOT NULL DEFAULT '';
+UPDATE themes SET submission_status = 'pending', active = 0 WHERE moderation = 'review';
+ALTER TABLE themes DROP COLUMN moderation;
+CREATE INDEX IF NOT EXISTS idx_themes_submission_status ON themes (submission_status);
+`,
+	// 10: 楽観的排他制御のバージョン（既存のものは0から始める）
+	`
+ALTER TABLE themes ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE answers ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+`,
+}
+
+const (
+	themeColumns  = `id, title, description, created_at, updated_at, created_by, active, user_id, opens_at, closes_at, submission_status, rejection_reason, version`
+	answerColumns = `id, theme_id, content, created_at, updated_at, created_by, likes, user_id, moderation, version`
+)
+
+// SQLiteStore はSQLiteデータベースにデータを保持する実装
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore はSQLiteデータベースを開き、スキーマを準備したSQLiteStoreを返す
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("データベース接続エラー: %w", err)
+	}
+	// SQLiteは書き込みが直列化されるため、接続は1本に絞る
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("スキーマ作成エラー: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// Ping implements DataStore
+func (s *SQLiteStore) Ping() error {
+	return s.db.Ping()
+}
+
+// Close はWALの内容をデータベースファイルへ書き戻してから接続を閉じる
+func (s *SQLiteStore) Close() error {
+	if _, err := s.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		s.db.Close()
+		return fmt.Errorf("チェックポイントエラー: %w", err)
+	}
+	return s.db.Close()
+}
+
+// migrate は未適用のマイグレーションを1件ずつトランザクション内で適用する
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("マイグレーション %d: %w", i+1, err)
+		}
+		// PRAGMAはプレースホルダーを使えないため、整数を直接埋め込む
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		slog.Info("SQLiteのスキーマを更新しました", "version", i+1)
+	}
+	return nil
+}
+
+// rowScanner は*sql.Rowと*sql.Rowsの共通インターフェース
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTheme(row rowScanner) (*Theme, error) {
+	var theme Theme
+	var createdAt, updatedAt int64
+	var opensAt, closesAt sql.NullInt64
+	if err := row.Scan(&theme.ID, &theme.Title, &theme.Description, &createdAt, &updatedAt, &theme.CreatedBy, &theme.Active, &theme.UserID, &opensAt, &closesAt, &theme.SubmissionStatus, &theme.RejectionReason, &theme.Version); err != nil {
+		return nil, err
+	}
+	theme.CreatedAt = time.Unix(0, createdAt)
+	theme.UpdatedAt = time.Unix(0, updatedAt)
+	theme.OpensAt = fromNullTime(opensAt)
+	theme.ClosesAt = fromNullTime(closesAt)
+	return &theme, nil
+}
+
+// nullTime は省略可能な日時をNULL許容の整数列に変換する
+func nullTime(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func fromNullTime(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(0, v.Int64)
+	return &t
+}
+
+func scanAnswer(row rowScanner) (*Answer, error) {
+	var answer Answer
+	var createdAt, updatedAt int64
+	if err := row.Scan(&answer.ID, &answer.ThemeID, &answer.Content, &createdAt, &updatedAt, &answer.CreatedBy, &answer.Likes, &answer.UserID, &answer.Moderation, &answer.Version); err != nil {
+		return nil, err
+	}
+	answer.CreatedAt = time.Unix(0, createdAt)
+	answer.UpdatedAt = time.Unix(0, updatedAt)
+	return &answer, nil
+}
+
+// nextID はカウンターを進めて新しいIDを払い出す
+func nextID(tx *sql.Tx, name string) (int64, error) {
+	var value int64
+	err := tx.QueryRow(`UPDATE counters SET value = value + 1 WHERE name = ? RETURNING value`, name).Scan(&value)
+	if err != nil {
+		return 0, fmt.Errorf("ID採番エラー: %w", err)
+	}
+	return value, nil
+}
+
+// placeholders は IN 句に使う n 個のプレースホルダーを返す
+func placeholders(n int) string {
+	return "?" + strings.Repeat(", ?", n-1)
+}
+
+// versionConflict はバージョンを条件にした更新が0件だった理由を調べる
+// 行が無ければErrNotFound、あればバージョンが違うのでErrVersionConflictを返す
+func (s *SQLiteStore) versionConflict(query string, args ...interface{}) error {
+	var exists int
+	if err := s.db.QueryRow(query, args...).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+// affectedOrNotFound は更新件数が0件ならErrNotFoundを返す
+func affectedOrNotFound(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTheme implements DataStore
+func (s *SQLiteStore) GetTheme(id string) (*Theme, error) {
+	row := s.db.QueryRow(`SELECT `+themeColumns+` FROM themes WHERE id = ?`, id)
+	theme, err := scanTheme(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return theme, err
+}
+
+// listQuery は一覧取得用のWHERE句・ORDER BY句・LIMIT句を組み立てる
+func listQuery(opts ListOptions, where []string, args []interface{}) (string, []interface{}, error) {
+	after, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if opts.CreatedBy != "" {
+		where = append(where, "created_by = ?")
+		args = append(args, opts.CreatedBy)
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where = append(where, "created_at < ?")
+		args = append(args, opts.CreatedBefore.UnixNano())
+	}
+	if !opts.CreatedAfter.IsZero() {
+		where = append(where, "created_at > ?")
+		args = append(args, opts.CreatedAfter.UnixNano())
+	}
+
+	// 並び替えキーはListOptionsの定数のみを受け付けるため、列名として埋め込んでも安全
+	column := opts.sortBy()
+	switch column {
+	case SortByCreatedAt, SortByUpdatedAt, SortByLikes:
+	default:
+		return "", nil, fmt.Errorf("不明な並び替えキーです: %q", column)
+	}
+	cmp, dir := ">", "ASC"
+	if opts.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	if after != nil {
+		where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, cmp, column, cmp))
+		args = append(args, after.Value, after.Value, after.ID)
+	}
+
+	query := ""
+	if len(where) > 0 {
+		query = " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, dir, dir)
+	if opts.Limit > 0 {
+		// 次のページの有無を判定するため1件多く取得する
+		query += " LIMIT ?"
+		args = append(args, opts.Limit+1)
+	}
+	return query, args, nil
+}
+
+// ListThemes implements DataStore
+func (s *SQLiteStore) ListThemes(opts ListOptions) ([]*Theme, string, error) {
+	if opts.sortBy() == SortByLikes {
+		return nil, "", fmt.Errorf("お題は %q で並び替えできません", SortByLikes)
+	}
+
+	statuses := opts.Submission
+	if len(statuses) == 0 {
+		statuses = []SubmissionStatus{SubmissionApproved}
+	}
+	where := []string{"submission_status IN (" + placeholders(len(statuses)) + ")"}
+	var args []interface{}
+	for _, status := range statuses {
+		args = append(args, string(status))
+	}
+	if opts.Active != nil {
+		where = append(where, "active = ?")
+		args = append(args, *opts.Active)
+	}
+	// Theme.Status と同じ条件
+	now := time.Now().UnixNano()
+	const (
+		upcoming = "(opens_at IS NOT NULL AND opens_at > ?)"
+		open     = "(active = 1 AND (opens_at IS NULL OR opens_at <= ?) AND (closes_at IS NULL OR closes_at > ?))"
+	)
+	switch opts.Status {
+	case ThemeUpcoming:
+		where = append(where, upcoming)
+		args = append(args, now)
+	case ThemeOpen:
+		where = append(where, open)
+		args = append(args, now, now)
+	case ThemeClosed:
+		where = append(where, "NOT "+upcoming+" AND NOT "+open)
+		args = append(args, now, now, now)
+	}
+	query, args, err := listQuery(opts, where, args)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := s.db.Query(`SELECT `+themeColumns+` FROM themes`+query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	themes := make([]*Theme, 0)
+	for rows.Next() {
+		theme, err := scanTheme(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		themes = append(themes, theme)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if opts.Limit <= 0 || len(themes) <= opts.Limit {
+		return themes, "", nil
+	}
+	themes = themes[:opts.Limit]
+	return themes, encodeCursor(opts.themeKey(themes[len(themes)-1])), nil
+}
+
+// CreateTheme implements DataStore
+func (s *SQLiteStore) CreateTheme(theme *Theme) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seq, err := nextID(tx, "theme")
+	if err != nil {
+		return err
+	}
+
+	// IDを自動生成
+	theme.ID = fmt.Sprintf("theme_%d", seq)
+	theme.CreatedAt = time.Now()
+	theme.UpdatedAt = theme.CreatedAt
+	theme.Version = 1
+	// 審査状態の指定が無ければ承認済みとして扱う
+	if theme.SubmissionStatus == "" {
+		theme.SubmissionStatus = SubmissionApproved
+	}
+
+	_, err = tx.Exec(`INSERT INTO themes (`+themeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		theme.ID, theme.Title, theme.Description, theme.CreatedAt.UnixNano(), theme.UpdatedAt.UnixNano(), theme.CreatedBy, theme.Active, theme.UserID,
+		nullTime(theme.OpensAt), nullTime(theme.ClosesAt), theme.SubmissionStatus, theme.RejectionReason, theme.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateTheme implements DataStore
+func (s *SQLiteStore) UpdateTheme(theme *Theme) error {
+	updatedAt := time.Now()
+	res, err := s.db.Exec(`UPDATE themes SET title = ?, description = ?, updated_at = ?, created_by = ?, active = ?, opens_at = ?, closes_at = ?, submission_status = ?, rejection_reason = ?, version = version + 1 WHERE id = ? AND version = ?`,
+		theme.Title, theme.Description, updatedAt.UnixNano(), theme.CreatedBy, theme.Active, nullTime(theme.OpensAt), nullTime(theme.ClosesAt),
+		theme.SubmissionStatus, theme.RejectionReason, theme.ID, theme.Version)
+	if err != nil {
+		return err
+	}
+	if err := affectedOrNotFound(res); err == ErrNotFound {
+		return s.versionConflict(`SELECT COUNT(*) FROM themes WHERE id = ?`, theme.ID)
+	} else if err != nil {
+		return err
+	}
+	theme.UpdatedAt = updatedAt
+	theme.Version++
+	return nil
+}
+
+// DeleteTheme implements DataStore
+// 関連する回答は外部キーのON DELETE CASCADEで削除される
+func (s *SQLiteStore) DeleteTheme(id string, version int64) error {
+	res, err := s.db.Exec(`DELETE FROM themes WHERE id = ? AND (? = 0 OR version = ?)`, id, version, version)
+	if err != nil {
+		return err
+	}
+	if err := affectedOrNotFound(res); err == ErrNotFound && version != 0 {
+		return s.versionConflict(`SELECT COUNT(*) FROM themes WHERE id = ?`, id)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetAnswer implements DataStore
+func (s *SQLiteStore) GetAnswer(id string, themeID string) (*Answer, error) {
+	row := s.db.QueryRow(`SELECT `+answerColumns+` FROM answers WHERE id = ? AND theme_id = ?`, id, themeID)
+	answer, err := scanAnswer(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return answer, err
+}
+
+// ListAnswers implements DataStore
+func (s *SQLiteStore) ListAnswers(themeID string, opts ListOptions) ([]*Answer, string, error) {
+	states := opts.Moderation
+	if len(states) == 0 {
+		states = []ModerationState{ModerationNone}
+	}
+	where := []string{"theme_id = ?", "moderation IN (" + placeholders(len(states)) + ")"}
+	args := []interface{}{themeID}
+	for _, state := range states {
+		args = append(args, string(state))
+	}
+	query, args, err := listQuery(opts, where, args)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := s.db.Query(`SELECT `+answerColumns+` FROM answers`+query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	answers := make([]*Answer, 0)
+	for rows.Next() {
+		answer, err := scanAnswer(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		answers = append(answers, answer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if opts.Limit <= 0 || len(answers) <= opts.Limit {
+		return answers, "", nil
+	}
+	answers = answers[:opts.Limit]
+	return answers, encodeCursor(opts.answerKey(answers[len(answers)-1])), nil
+}
+
+// CreateAnswer implements DataStore
+func (s *SQLiteStore) CreateAnswer(answer *Answer) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// お題の存在確認
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM themes WHERE id = ?`, answer.ThemeID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+
+	seq, err := nextID(tx, "answer")
+	if err != nil {
+		return err
+	}
+
+	// IDを自動生成
+	answer.ID = fmt.Sprintf("answer_%d", seq)
+	answer.CreatedAt = time.Now()
+	answer.UpdatedAt = answer.CreatedAt
+	answer.Version = 1
+
+	_, err = tx.Exec(`INSERT INTO answers (`+answerColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		answer.ID, answer.ThemeID, answer.Content, answer.CreatedAt.UnixNano(), answer.UpdatedAt.UnixNano(), answer.CreatedBy, answer.Likes, answer.UserID, answer.Moderation, answer.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateAnswer implements DataStore
+func (s *SQLiteStore) UpdateAnswer(answer *Answer) error {
+	updatedAt := time.Now()
+	// いいね数はLikeAnswer/UnlikeAnswerでのみ変更する
+	row := s.db.QueryRow(`UPDATE answers SET content = ?, updated_at = ?, created_by = ?, moderation = ?, version = version + 1 WHERE id = ? AND theme_id = ? AND version = ? RETURNING likes, version`,
+		answer.Content, updatedAt.UnixNano(), answer.CreatedBy, answer.Moderation, answer.ID, answer.ThemeID, answer.Version)
+	err := row.Scan(&answer.Likes, &answer.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.versionConflict(`SELECT COUNT(*) FROM answers WHERE id = ? AND theme_id = ?`, answer.ID, answer.ThemeID)
+	}
+	if err != nil {
+		return err
+	}
+	answer.UpdatedAt = updatedAt
+	return nil
+}
+
+// DeleteAnswer implements DataStore
+func (s *SQLiteStore) DeleteAnswer(id string, themeID string, version int64) error {
+	res, err := s.db.Exec(`DELETE FROM answers WHERE id = ? AND theme_id = ? AND (? = 0 OR version = ?)`, id, themeID, version, version)
+	if err != nil {
+		return err
+	}
+	if err := affectedOrNotFound(res); err == ErrNotFound && version != 0 {
+		return s.versionConflict(`SELECT COUNT(*) FROM answers WHERE id = ? AND theme_id = ?`, id, themeID)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// changeLike はいいねの記録といいね数の増減を1つのトランザクションで行う
+func (s *SQLiteStore) changeLike(id string, themeID string, voterID string, like bool) (*Answer, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM answers WHERE id = ? AND theme_id = ?`, id, themeID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, ErrNotFound
+	}
+
+	var res sql.Result
+	delta := 1
+	if like {
+		res, err = tx.Exec(`INSERT OR IGNORE INTO answer_likes (answer_id, voter_id, created_at) VALUES (?, ?, ?)`, id, voterID, time.Now().UnixNano())
+	} else {
+		delta = -1
+		res, err = tx.Exec(`DELETE FROM answer_likes WHERE answer_id = ? AND voter_id = ?`, id, voterID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 && like {
+		return nil, ErrAlreadyLiked
+	} else if n == 0 {
+		return nil, ErrNotLiked
+	}
+
+	row := tx.QueryRow(`UPDATE answers SET likes = likes + ?, version = version + 1 WHERE id = ? RETURNING `+answerColumns, delta, id)
+	answer, err := scanAnswer(row)
+	if err != nil {
+		return nil, err
+	}
+	return answer, tx.Commit()
+}
+
+// LikeAnswer implements DataStore
+func (s *SQLiteStore) LikeAnswer(id string, themeID string, voterID string) (*Answer, error) {
+	return s.changeLike(id, themeID, voterID, true)
+}
+
+// UnlikeAnswer implements DataStore
+func (s *SQLiteStore) UnlikeAnswer(id string, themeID string, voterID string) (*Answer, error) {
+	return s.changeLike(id, themeID, voterID, false)
+}
+
+// HasLiked implements DataStore
+func (s *SQLiteStore) HasLiked(id string, voterID string) (bool, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM answer_likes WHERE answer_id = ? AND voter_id = ?`, id, voterID).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,669 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// eachStore は全てのバックエンドについて test を実行する
+func eachStore(t *testing.T, test func(t *testing.T, store DataStore)) {
+	t.Helper()
+	backends := map[string]func(t *testing.T) DataStore{
+		"memory": func(t *testing.T) DataStore { return NewInMemoryStore() },
+		"json": func(t *testing.T) DataStore {
+			store, err := NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+		"sqlite": func(t *testing.T) DataStore {
+			store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "data.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+	for name, newStore := range backends {
+		t.Run(name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+// mustCreateTheme はお題を作成する
+func mustCreateTheme(t *testing.T, store DataStore, title string) *Theme {
+	t.Helper()
+	theme := &Theme{Title: title, Active: true}
+	if err := store.CreateTheme(theme); err != nil {
+		t.Fatal(err)
+	}
+	return theme
+}
+
+// mustCreateAnswer はお題に回答を作成する
+func mustCreateAnswer(t *testing.T, store DataStore, themeID string) *Answer {
+	t.Helper()
+	answer := &Answer{ThemeID: themeID, Content: "回答"}
+	if err := store.CreateAnswer(answer); err != nil {
+		t.Fatal(err)
+	}
+	return answer
+}
+
+// TestCreateAssignsIDs は全てのバックエンドでストアが同じ規則でIDと時刻を振ることを確認する
+func TestCreateAssignsIDs(t *testing.T) {
+	eachStore(t, func(t *testing.T, store DataStore) {
+		first := mustCreateTheme(t, store, "お題1")
+		second := mustCreateTheme(t, store, "お題2")
+		// 呼び出し側が指定したIDは使わない
+		answer := &Answer{ID: "指定", ThemeID: first.ID, Content: "回答"}
+		if err := store.CreateAnswer(answer); err != nil {
+			t.Fatal(err)
+		}
+		user := &User{ID: "指定", Username: "alice", PasswordHash: "-"}
+		if err := store.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			got  string
+			want string
+		}{
+			{"1件目のお題", first.ID, "theme_1"},
+			{"2件目のお題", second.ID, "theme_2"},
+			{"1件目の回答", answer.ID, "answer_1"},
+			{"1人目のユーザー", user.ID, "user_1"},
+		}
+		for _, tt := range tests {
+			if tt.got != tt.want {
+				t.Errorf("%s: ID = %q, want %q", tt.name, tt.got, tt.want)
+			}
+		}
+		if first.CreatedAt.IsZero() || answer.CreatedAt.IsZero() {
+			t.Error("作成日時が設定されていない")
+		}
+	})
+}
+
+// TestCRUD は全てのバックエンドでお題と回答の作成・取得・更新・削除が同じ結果になることを確認する
+func TestCRUD(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store DataStore)
+	}{
+		{"作成したお題を取得できる", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			got, err := store.GetTheme(theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != "お題" || !got.Active {
+				t.Errorf("got %+v", got)
+			}
+		}},
+		{"お題を更新できる", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			updated := *theme
+			updated.Title = "更新"
+			updated.Active = false
+			if err := store.UpdateTheme(&updated); err != nil {
+				t.Fatal(err)
+			}
+			got, err := store.GetTheme(theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != "更新" || got.Active {
+				t.Errorf("got %+v", got)
+			}
+		}},
+		{"存在しないお題の更新はErrNotFound", func(t *testing.T, store DataStore) {
+			if err := store.UpdateTheme(&Theme{ID: "theme_404", Title: "x"}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("err = %v, want ErrNotFound", err)
+			}
+		}},
+		{"お題を一覧できる", func(t *testing.T, store DataStore) {
+			mustCreateTheme(t, store, "お題1")
+			mustCreateTheme(t, store, "お題2")
+			themes, _, err := store.ListThemes(ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(themes) != 2 {
+				t.Errorf("len = %d, want 2", len(themes))
+			}
+		}},
+		{"お題の削除で回答も消える", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			if err := store.DeleteTheme(theme.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.GetTheme(theme.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetTheme err = %v, want ErrNotFound", err)
+			}
+			if _, err := store.GetAnswer(answer.ID, theme.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetAnswer err = %v, want ErrNotFound", err)
+			}
+		}},
+		{"存在しないお題の削除はErrNotFound", func(t *testing.T, store DataStore) {
+			if err := store.DeleteTheme("theme_404", 0); !errors.Is(err, ErrNotFound) {
+				t.Errorf("err = %v, want ErrNotFound", err)
+			}
+		}},
+		{"存在しないお題への回答はErrNotFound", func(t *testing.T, store DataStore) {
+			if err := store.CreateAnswer(&Answer{ThemeID: "theme_404", Content: "回答"}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("err = %v, want ErrNotFound", err)
+			}
+		}},
+		{"回答を取得・一覧できる", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			other := mustCreateTheme(t, store, "別のお題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			mustCreateAnswer(t, store, other.ID)
+
+			got, err := store.GetAnswer(answer.ID, theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Content != "回答" {
+				t.Errorf("content = %q", got.Content)
+			}
+			if _, err := store.GetAnswer(answer.ID, other.ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("別のお題からの取得 err = %v, want ErrNotFound", err)
+			}
+			answers, _, err := store.ListAnswers(theme.ID, ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(answers) != 1 || answers[0].ID != answer.ID {
+				t.Errorf("answers = %+v", answers)
+			}
+		}},
+		{"回答を更新してもいいね数は変わらない", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			updated := *answer
+			updated.Content = "更新"
+			updated.Likes = 3
+			if err := store.UpdateAnswer(&updated); err != nil {
+				t.Fatal(err)
+			}
+			got, err := store.GetAnswer(answer.ID, theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Content != "更新" || got.Likes != 0 {
+				t.Errorf("got %+v", got)
+			}
+		}},
+		{"いいねは投票者ごとに1回", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			steps := []struct {
+				like      bool
+				voter     string
+				wantErr   error
+				wantLikes int
+			}{
+				{true, "v1", nil, 1},
+				{true, "v1", ErrAlreadyLiked, 1},
+				{true, "v2", nil, 2},
+				{false, "v1", nil, 1},
+				{false, "v1", ErrNotLiked, 1},
+			}
+			for i, step := range steps {
+				var err error
+				if step.like {
+					_, err = store.LikeAnswer(answer.ID, theme.ID, step.voter)
+				} else {
+					_, err = store.UnlikeAnswer(answer.ID, theme.ID, step.voter)
+				}
+				if !errors.Is(err, step.wantErr) {
+					t.Errorf("%d: err = %v, want %v", i, err, step.wantErr)
+				}
+				got, err := store.GetAnswer(answer.ID, theme.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Likes != step.wantLikes {
+					t.Errorf("%d: likes = %d, want %d", i, got.Likes, step.wantLikes)
+				}
+			}
+			if liked, _ := store.HasLiked(answer.ID, "v2"); !liked {
+				t.Error("v2 のいいねが記録されていない")
+			}
+			if _, err := store.LikeAnswer("answer_404", theme.ID, "v1"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("存在しない回答 err = %v, want ErrNotFound", err)
+			}
+		}},
+		{"作成したお題と回答はバージョン1から始まる", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			if theme.Version != 1 || answer.Version != 1 {
+				t.Errorf("theme.Version = %d, answer.Version = %d, want 1", theme.Version, answer.Version)
+			}
+		}},
+		{"更新といいねでバージョンが上がる", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			updatedTheme := *theme
+			updatedTheme.Title = "更新"
+			if err := store.UpdateTheme(&updatedTheme); err != nil {
+				t.Fatal(err)
+			}
+			updatedAnswer := *answer
+			updatedAnswer.Content = "更新"
+			if err := store.UpdateAnswer(&updatedAnswer); err != nil {
+				t.Fatal(err)
+			}
+			liked, err := store.LikeAnswer(answer.ID, theme.ID, "v1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			unliked, err := store.UnlikeAnswer(answer.ID, theme.ID, "v1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotTheme, err := store.GetTheme(theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotAnswer, err := store.GetAnswer(answer.ID, theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updatedTheme.Version != 2 || gotTheme.Version != 2 {
+				t.Errorf("お題のバージョン = %d（保存 %d）, want 2", updatedTheme.Version, gotTheme.Version)
+			}
+			if updatedAnswer.Version != 2 || liked.Version != 3 || unliked.Version != 4 || gotAnswer.Version != 4 {
+				t.Errorf("回答のバージョン = %d, %d, %d（保存 %d）, want 2, 3, 4", updatedAnswer.Version, liked.Version, unliked.Version, gotAnswer.Version)
+			}
+		}},
+		{"古いバージョンでの更新はErrVersionConflict", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			if _, err := store.LikeAnswer(answer.ID, theme.ID, "v1"); err != nil {
+				t.Fatal(err)
+			}
+			first, stale := *theme, *theme
+			if err := store.UpdateTheme(&first); err != nil {
+				t.Fatal(err)
+			}
+			stale.Title = "古い"
+			if err := store.UpdateTheme(&stale); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("お題 err = %v, want ErrVersionConflict", err)
+			}
+			staleAnswer := *answer
+			staleAnswer.Content = "古い"
+			if err := store.UpdateAnswer(&staleAnswer); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("回答 err = %v, want ErrVersionConflict", err)
+			}
+			got, err := store.GetTheme(theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Title != "お題" || got.Version != 2 {
+				t.Errorf("got %+v", got)
+			}
+		}},
+		{"回答を削除できる", func(t *testing.T, store DataStore) {
+			theme := mustCreateTheme(t, store, "お題")
+			answer := mustCreateAnswer(t, store, theme.ID)
+			if err := store.DeleteAnswer(answer.ID, theme.ID, 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.DeleteAnswer(answer.ID, theme.ID, 0); !errors.Is(err, ErrNotFound) {
+				t.Errorf("2回目の削除 err = %v, want ErrNotFound", err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			eachStore(t, tt.run)
+		})
+	}
+}
+
+// TestClose は閉じる前の変更が開き直した後も残り、閉じた後の変更はエラーになることを確認する
+func TestClose(t *testing.T) {
+	tests := []struct {
+		name string
+		open func(path string) (DataStore, error)
+	}{
+		{"json", func(path string) (DataStore, error) { return NewJSONStore(path) }},
+		{"sqlite", func(path string) (DataStore, error) { return NewSQLiteStore(path) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data")
+			store, err := tt.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			theme := mustCreateTheme(t, store, "お題")
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.CreateTheme(&Theme{Title: "閉じた後のお題"}); err == nil {
+				t.Error("閉じた後に作成できてしまいました")
+			}
+
+			reopened, err := tt.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			if _, err := reopened.GetTheme(theme.ID); err != nil {
+				t.Errorf("開き直した後にお題が取得できない: %v", err)
+			}
+		})
+	}
+
+	// JSONStore はジャーナルをスナップショットへ書き戻して空にする
+	path := filepath.Join(t.TempDir(), "data.json")
+	store, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCreateTheme(t, store, "お題")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path + ".journal"); err != nil || info.Size() != 0 {
+		t.Errorf("閉じた後のジャーナル: %v, %v", info, err)
+	}
+}
+
+// TestSQLiteMigrate は再オープン時に適用済みのマイグレーションを繰り返さないことを確認する
+func TestSQLiteMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theme := mustCreateTheme(t, store, "お題")
+	store.Close()
+
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var version int
+	if err := store.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(sqliteMigrations) {
+		t.Errorf("user_version = %d, want %d", version, len(sqliteMigrations))
+	}
+	if _, err := store.GetTheme(theme.ID); err != nil {
+		t.Errorf("再オープン後にお題が取得できない: %v", err)
+	}
+}
+
+// TestSQLiteMigrateSubmission は審査状態の導入前に確認待ちだったお題が審査待ちになり、themes.moderation が削除されることを確認する
+func TestSQLiteMigrateSubmission(t *testing.T) {
+	const before = 8 // お題の審査状態を追加する前のスキーマ
+	path := filepath.Join(t.TempDir(), "data.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range sqliteMigrations[:before] {
+		if _, err := db.Exec(migration); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, before)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO themes (id, title, description, created_at, updated_at, created_by, active, moderation)
+VALUES ('theme_1', '確認待ち', '', 0, 0, '', 1, 'review'), ('theme_2', '公開中', '', 0, 0, '', 1, '')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	tests := []struct {
+		id         string
+		wantStatus SubmissionStatus
+		wantActive bool
+	}{
+		{"theme_1", SubmissionPending, false},
+		{"theme_2", SubmissionApproved, true},
+	}
+	for _, tt := range tests {
+		theme, err := store.GetTheme(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if theme.SubmissionStatus != tt.wantStatus || theme.Active != tt.wantActive {
+			t.Errorf("%s: submission_status = %s, active = %v, want %s, %v", tt.id, theme.SubmissionStatus, theme.Active, tt.wantStatus, tt.wantActive)
+		}
+	}
+
+	var n int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('themes') WHERE name = 'moderation'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("themes.moderation が残っています")
+	}
+}
+
+// TestListPaging は全てのバックエンドで並び替え・絞り込み・カーソルによる続きの取得が同じ結果になることを確認する
+func TestListPaging(t *testing.T) {
+	eachStore(t, func(t *testing.T, store DataStore) {
+		theme := mustCreateTheme(t, store, "お題")
+		var ids []string
+		for i, likes := range []int{1, 3, 1, 0, 3} {
+			answer := &Answer{ThemeID: theme.ID, Content: "回答", Likes: likes}
+			if i%2 == 0 {
+				answer.CreatedBy = "太郎"
+			}
+			if err := store.CreateAnswer(answer); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, answer.ID)
+		}
+
+		tests := []struct {
+			name string
+			opts ListOptions
+			want []string
+		}{
+			{"作成日時の昇順", ListOptions{Limit: 2}, ids},
+			{"作成日時の降順", ListOptions{Limit: 2, Desc: true}, []string{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+			{"いいねの多い順（同数はIDの降順）", ListOptions{Limit: 2, SortBy: SortByLikes, Desc: true}, []string{ids[4], ids[1], ids[2], ids[0], ids[3]}},
+			{"いいねの少ない順（同数はIDの昇順）", ListOptions{Limit: 3, SortBy: SortByLikes}, []string{ids[3], ids[0], ids[2], ids[1], ids[4]}},
+			{"作成者で絞り込み", ListOptions{Limit: 2, CreatedBy: "太郎"}, []string{ids[0], ids[2], ids[4]}},
+			{"件数を制限しない", ListOptions{}, ids},
+		}
+		for _, tt := range tests {
+			var got []string
+			opts := tt.opts
+			for pages := 0; ; pages++ {
+				if pages > len(ids) {
+					t.Fatalf("%s: ページが終わりません", tt.name)
+				}
+				answers, next, err := store.ListAnswers(theme.ID, opts)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				if opts.Limit > 0 && len(answers) > opts.Limit {
+					t.Errorf("%s: %d件, want %d件以下", tt.name, len(answers), opts.Limit)
+				}
+				for _, answer := range answers {
+					got = append(got, answer.ID)
+				}
+				if next == "" {
+					break
+				}
+				opts.Cursor = next
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			}
+		}
+
+		if _, _, err := store.ListAnswers(theme.ID, ListOptions{Cursor: "!!"}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("不正なカーソル err = %v, want ErrInvalidCursor", err)
+		}
+	})
+}
+
+// TestDeleteVersion は削除に渡したバージョンが保存されているものと違えば削除しないことを確認する
+func TestDeleteVersion(t *testing.T) {
+	eachStore(t, func(t *testing.T, store DataStore) {
+		theme := mustCreateTheme(t, store, "お題")
+		answer := mustCreateAnswer(t, store, theme.ID)
+
+		// 読み込んだ後に他の操作で更新される
+		readTheme, readAnswer := *theme, *answer
+		updatedTheme := *theme
+		updatedTheme.Title = "更新"
+		if err := store.UpdateTheme(&updatedTheme); err != nil {
+			t.Fatal(err)
+		}
+		updatedAnswer := *answer
+		updatedAnswer.Content = "更新"
+		if err := store.UpdateAnswer(&updatedAnswer); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			del  func() error
+			want error
+		}{
+			{"古いバージョンの回答", func() error { return store.DeleteAnswer(answer.ID, theme.ID, readAnswer.Version) }, ErrVersionConflict},
+			{"古いバージョンのお題", func() error { return store.DeleteTheme(theme.ID, readTheme.Version) }, ErrVersionConflict},
+			{"存在しない回答", func() error { return store.DeleteAnswer("none", theme.ID, 1) }, ErrNotFound},
+			{"存在しないお題", func() error { return store.DeleteTheme("none", 1) }, ErrNotFound},
+			{"現在のバージョンの回答", func() error { return store.DeleteAnswer(answer.ID, theme.ID, updatedAnswer.Version) }, nil},
+			{"バージョンを確認しない", func() error { return store.DeleteTheme(theme.ID, 0) }, nil},
+		}
+		for _, tt := range tests {
+			if err := tt.del(); err != tt.want {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+		if _, err := store.GetTheme(theme.ID); err != ErrNotFound {
+			t.Errorf("削除したお題: err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// TestImport はインポートが全てを保存するか、何も保存しないことを確認する
+func TestImport(t *testing.T) {
+	eachStore(t, func(t *testing.T, store DataStore) {
+		theme := mustCreateTheme(t, store, "既存")
+		answerID := mustCreateAnswer(t, store, theme.ID).ID
+
+		tests := []struct {
+			name  string
+			batch *ImportBatch
+			want  error
+		}{
+			{"別のお題にある回答のID", &ImportBatch{
+				Themes:  []*Theme{{ID: "new_theme", Title: "新しい"}},
+				Answers: []*Answer{{ID: "new_1", ThemeID: "new_theme", Content: "1"}, {ID: answerID, ThemeID: "new_theme", Content: "2"}},
+			}, ErrExists},
+			{"既にあるお題のID", &ImportBatch{
+				Themes: []*Theme{{ID: "new_theme", Title: "新しい"}, {ID: theme.ID, Title: "重複"}},
+			}, ErrExists},
+			{"バッチの中で重なるID", &ImportBatch{
+				Themes:  []*Theme{{ID: "new_theme", Title: "新しい"}},
+				Answers: []*Answer{{ID: "dup", ThemeID: "new_theme"}, {ID: "dup", ThemeID: "new_theme"}},
+			}, ErrExists},
+			{"無いお題の回答", &ImportBatch{
+				Themes:  []*Theme{{ID: "new_theme", Title: "新しい"}},
+				Answers: []*Answer{{ID: "new_1", ThemeID: "none"}},
+			}, ErrNotFound},
+		}
+		for _, tt := range tests {
+			if err := store.Import(tt.batch); err != tt.want {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			}
+			if _, err := store.GetTheme("new_theme"); err != ErrNotFound {
+				t.Errorf("%s: 失敗したインポートのお題が保存されています", tt.name)
+			}
+			if exists, _ := store.AnswerExists("new_1"); exists {
+				t.Errorf("%s: 失敗したインポートの回答が保存されています", tt.name)
+			}
+		}
+
+		// IDを振り直すと、回答のお題も新しいIDになる
+		batch := &ImportBatch{
+			Themes:        []*Theme{{ID: theme.ID, Title: "移行"}},
+			Answers:       []*Answer{{ID: answerID, ThemeID: theme.ID, Content: "移行", Likes: 3}},
+			RegenerateIDs: true,
+		}
+		if err := store.Import(batch); err != nil {
+			t.Fatal(err)
+		}
+		newTheme, newAnswer := batch.Themes[0], batch.Answers[0]
+		if newTheme.ID == theme.ID || newAnswer.ID == answerID || newAnswer.ThemeID != newTheme.ID {
+			t.Fatalf("IDが振り直されていません: theme=%s answer=%s answer.theme_id=%s", newTheme.ID, newAnswer.ID, newAnswer.ThemeID)
+		}
+		stored, err := store.GetAnswer(newAnswer.ID, newTheme.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Likes != 3 || stored.Version != 1 {
+			t.Errorf("保存した回答: likes=%d version=%d, want 3, 1", stored.Likes, stored.Version)
+		}
+		// 振り直したIDの後も、作成したお題のIDは重ならない
+		created := mustCreateTheme(t, store, "作成")
+		if created.ID == newTheme.ID || created.ID == theme.ID {
+			t.Errorf("作成したお題のID %s がインポートしたお題と重なっています", created.ID)
+		}
+	})
+}
+
+// TestImportSurvivesReopen はJSONストアにインポートした内容がジャーナルから復元されることを確認する
+func TestImportSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	store, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := &ImportBatch{
+		Themes:  []*Theme{{ID: "theme_7", Title: "移行"}},
+		Answers: []*Answer{{ID: "answer_9", ThemeID: "theme_7", Content: "回答"}},
+	}
+	if err := store.Import(batch); err != nil {
+		t.Fatal(err)
+	}
+	// Close はスナップショットを書き出すため、書き戻さずに手放してジャーナルだけから読む
+	abandon(store)
+	reopened, err := NewJSONStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, err := reopened.GetAnswer("answer_9", "theme_7"); err != nil {
+		t.Errorf("インポートした回答: %v", err)
+	}
+	theme := mustCreateTheme(t, reopened, "作成")
+	if theme.ID != "theme_8" {
+		t.Errorf("作成したお題のID = %s, want theme_8", theme.ID)
+	}
+}
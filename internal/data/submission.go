@@ -0,0 +1,28 @@
+package data
+
+// SubmissionStatus は投稿されたお題の審査状態
+type SubmissionStatus string
+
+const (
+	SubmissionPending  SubmissionStatus = "pending"  // 審査待ち（一般のユーザーには表示しない）
+	SubmissionApproved SubmissionStatus = "approved" // 承認済み
+	SubmissionRejected SubmissionStatus = "rejected" // 却下（一般のユーザーには表示しない）
+)
+
+// AllSubmissionStatuses は一覧取得で全ての審査状態を対象にするときに ListOptions.Submission に指定する
+var AllSubmissionStatuses = []SubmissionStatus{SubmissionPending, SubmissionApproved, SubmissionRejected}
+
+// Valid は定義済みの審査状態か判定する
+func (s SubmissionStatus) Valid() bool {
+	for _, status := range AllSubmissionStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Approved はお題が承認済みか判定する
+func (t *Theme) Approved() bool {
+	return t.SubmissionStatus == SubmissionApproved
+}
@@ -0,0 +1,325 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrExists = errors.New("同じIDのものが既にあります")
+)
+
+// ImportBatch はまとめてインポートするお題と回答
+type ImportBatch struct {
+	Themes  []*Theme
+	Answers []*Answer
+	// RegenerateIDs なら全てのお題と回答に新しいIDを振り、回答の ThemeID が Themes のお題を指していればその新しいIDに置き換える
+	// false ならIDをそのまま使う（空のものには新しいIDを振る）
+	RegenerateIDs bool
+}
+
+// sequenceOf は "theme_12" のようにストアが振ったIDの番号を返す（他の形式なら0）
+// IDを残してインポートしたときに、以降に振るIDと重ならないようにするために使う
+func sequenceOf(id, prefix string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, prefix+"_"))
+	if err != nil || !strings.HasPrefix(id, prefix+"_") || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// importTarget は保存先ごとのIDの確認と採番
+type importTarget interface {
+	// exists は保存先に kind（"theme" または "answer"）の id が既にあるか判定する
+	exists(kind, id string) (bool, error)
+	// useID は kind のIDを決める
+	// id が空なら新しいIDを振り、空でなければ保存先に無いことを確認して、以降に振るIDと重ならないようにする
+	useID(kind string, id *string) error
+}
+
+// plan はインポートするお題と回答のコピーを作り、保存するIDを決める（batch は変更しない）
+// 同じIDが保存先やバッチの中にあれば ErrExists、回答のお題がどちらにも無ければ ErrNotFound を返す
+func (b *ImportBatch) plan(target importTarget) ([]*Theme, []*Answer, error) {
+	themes := make([]*Theme, len(b.Themes))
+	newThemeIDs := make(map[string]string, len(b.Themes)) // バッチでのID -> 保存するID
+	seen := make(map[string]bool, len(b.Themes)+len(b.Answers))
+	for i, t := range b.Themes {
+		theme := *t
+		if b.RegenerateIDs {
+			theme.ID = ""
+		}
+		if err := target.useID("theme", &theme.ID); err != nil {
+			return nil, nil, err
+		}
+		if seen["theme:"+theme.ID] {
+			return nil, nil, ErrExists
+		}
+		seen["theme:"+theme.ID] = true
+		if t.ID != "" {
+			newThemeIDs[t.ID] = theme.ID
+		}
+		theme.Version = 1
+		themes[i] = &theme
+	}
+
+	answers := make([]*Answer, len(b.Answers))
+	for i, a := range b.Answers {
+		answer := *a
+		if id, ok := newThemeIDs[answer.ThemeID]; ok {
+			answer.ThemeID = id
+		} else if exists, err := target.exists("theme", answer.ThemeID); err != nil {
+			return nil, nil, err
+		} else if !exists {
+			return nil, nil, ErrNotFound
+		}
+		if b.RegenerateIDs {
+			answer.ID = ""
+		}
+		if err := target.useID("answer", &answer.ID); err != nil {
+			return nil, nil, err
+		}
+		if seen["answer:"+answer.ID] {
+			return nil, nil, ErrExists
+		}
+		seen["answer:"+answer.ID] = true
+		answer.Version = 1
+		answer.LikedByMe = false
+		answers[i] = &answer
+	}
+	return themes, answers, nil
+}
+
+// done は保存したIDを batch に反映する
+func (b *ImportBatch) done(themes []*Theme, answers []*Answer) {
+	for i, theme := range themes {
+		b.Themes[i].ID = theme.ID
+		b.Themes[i].Version = theme.Version
+	}
+	for i, answer := range answers {
+		b.Answers[i].ID = answer.ID
+		b.Answers[i].ThemeID = answer.ThemeID
+		b.Answers[i].Version = answer.Version
+	}
+}
+
+// mapTarget は InMemoryStore・JSONStore の importTarget
+// 採番カウンターはコピーを進め、保存するときにストアへ反映する
+type mapTarget struct {
+	themeExists  func(id string) bool
+	answerExists func(id string) bool
+	nextThemeID  int
+	nextAnswerID int
+}
+
+func (t *mapTarget) exists(kind, id string) (bool, error) {
+	if kind == "theme" {
+		return t.themeExists(id), nil
+	}
+	return t.answerExists(id), nil
+}
+
+func (t *mapTarget) useID(kind string, id *string) error {
+	next, exists := &t.nextThemeID, t.themeExists
+	if kind == "answer" {
+		next, exists = &t.nextAnswerID, t.answerExists
+	}
+	if *id == "" {
+		*id = fmt.Sprintf("%s_%d", kind, *next)
+	} else if exists(*id) {
+		return ErrExists
+	}
+	if n := sequenceOf(*id, kind); n >= *next {
+		*next = n + 1
+	}
+	return nil
+}
+
+// ---------- InMemoryStore ----------
+
+// AnswerExists implements DataStore
+func (s *InMemoryStore) AnswerExists(id string) (bool, error) {
+	s.answersMutex.RLock()
+	defer s.answersMutex.RUnlock()
+	return s.answerExists(id), nil
+}
+
+// answerExists はどのお題かによらず回答があるか判定する（answersMutex を取ってから呼ぶ）
+func (s *InMemoryStore) answerExists(id string) bool {
+	for _, themeAnswers := range s.answers {
+		if _, exists := themeAnswers[id]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// Import implements DataStore
+func (s *InMemoryStore) Import(batch *ImportBatch) error {
+	s.themesMutex.Lock()
+	defer s.themesMutex.Unlock()
+	s.answersMutex.Lock()
+	defer s.answersMutex.Unlock()
+
+	target := &mapTarget{
+		themeExists: func(id string) bool {
+			_, exists := s.themes[id]
+			return exists
+		},
+		answerExists: s.answerExists,
+		nextThemeID:  s.nextThemeID,
+		nextAnswerID: s.nextAnswerID,
+	}
+	themes, answers, err := batch.plan(target)
+	if err != nil {
+		return err
+	}
+
+	for _, theme := range themes {
+		s.themes[theme.ID] = theme
+	}
+	for _, answer := range answers {
+		if _, exists := s.answers[answer.ThemeID]; !exists {
+			s.answers[answer.ThemeID] = make(map[string]*Answer)
+		}
+		s.answers[answer.ThemeID][answer.ID] = answer
+	}
+	s.nextThemeID, s.nextAnswerID = target.nextThemeID, target.nextAnswerID
+	batch.done(themes, answers)
+	return nil
+}
+
+// ---------- JSONStore ----------
+
+// AnswerExists implements DataStore
+func (s *JSONStore) AnswerExists(id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.answers[id]
+	return exists, nil
+}
+
+// Import implements DataStore
+// バッチ全体をジャーナルの1行に記録するので、途中でクラッシュしても一部だけが残ることは無い
+func (s *JSONStore) Import(batch *ImportBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := &mapTarget{
+		themeExists: func(id string) bool {
+			_, exists := s.themes[id]
+			return exists
+		},
+		answerExists: func(id string) bool {
+			_, exists := s.answers[id]
+			return exists
+		},
+		nextThemeID:  s.nextThemeID,
+		nextAnswerID: s.nextAnswerID,
+	}
+	themes, answers, err := batch.plan(target)
+	if err != nil {
+		return err
+	}
+
+	err = s.record(journalEntry{
+		Op:           opImport,
+		Themes:       themes,
+		Answers:      answers,
+		NextThemeID:  target.nextThemeID,
+		NextAnswerID: target.nextAnswerID,
+	})
+	if err != nil {
+		return err
+	}
+	batch.done(themes, answers)
+	return nil
+}
+
+// ---------- SQLiteStore ----------
+
+// AnswerExists implements DataStore
+func (s *SQLiteStore) AnswerExists(id string) (bool, error) {
+	var exists int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM answers WHERE id = ?`, id).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// Import implements DataStore
+// 1つのトランザクションで保存するので、途中で失敗すれば何も保存しない
+func (s *SQLiteStore) Import(batch *ImportBatch) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	themes, answers, err := batch.plan(sqlTarget{tx})
+	if err != nil {
+		return err
+	}
+	for _, theme := range themes {
+		_, err = tx.Exec(`INSERT INTO themes (`+themeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			theme.ID, theme.Title, theme.Description, theme.CreatedAt.UnixNano(), theme.UpdatedAt.UnixNano(), theme.CreatedBy, theme.Active, theme.UserID,
+			nullTime(theme.OpensAt), nullTime(theme.ClosesAt), theme.SubmissionStatus, theme.RejectionReason, theme.Version)
+		if err != nil {
+			return err
+		}
+	}
+	for _, answer := range answers {
+		_, err = tx.Exec(`INSERT INTO answers (`+answerColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			answer.ID, answer.ThemeID, answer.Content, answer.CreatedAt.UnixNano(), answer.UpdatedAt.UnixNano(), answer.CreatedBy, answer.Likes, answer.UserID, answer.Moderation, answer.Version)
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	batch.done(themes, answers)
+	return nil
+}
+
+// sqlTarget は SQLiteStore の importTarget（採番カウンターはトランザクションの中で進める）
+type sqlTarget struct {
+	tx *sql.Tx
+}
+
+func (t sqlTarget) exists(kind, id string) (bool, error) {
+	var exists int
+	if err := t.tx.QueryRow(`SELECT COUNT(*) FROM `+kind+`s WHERE id = ?`, id).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+// useID は kind のIDを決める
+// 空なら counters から新しいIDを振り、指定されていれば重複を確認して、以降に振るIDと重ならないよう counters を進める
+func (t sqlTarget) useID(kind string, id *string) error {
+	if *id == "" {
+		seq, err := nextID(t.tx, kind)
+		if err != nil {
+			return err
+		}
+		*id = fmt.Sprintf("%s_%d", kind, seq)
+		return nil
+	}
+
+	exists, err := t.exists(kind, *id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrExists
+	}
+	if n := sequenceOf(*id, kind); n > 0 {
+		if _, err := t.tx.Exec(`UPDATE counters SET value = MAX(value, ?) WHERE name = ?`, n, kind); err != nil {
+			return fmt.Errorf("ID採番エラー: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUsernameTaken = errors.New("このユーザー名は既に使われています")
+)
+
+// Role はユーザーの役割
+type Role string
+
+const (
+	RolePlayer    Role = "player"    // 回答の投稿と自分の回答の編集・削除
+	RoleModerator Role = "moderator" // 加えて全ての回答の非表示・削除
+	RoleAdmin     Role = "admin"     // 全ての操作
+)
+
+// Valid は定義済みの役割か判定する
+func (r Role) Valid() bool {
+	switch r {
+	case RolePlayer, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// User はログインできるユーザーを表す構造体
+// PasswordHash を含むため、APIのレスポンスにそのまま使わないこと
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// EffectiveRole はユーザーの役割を返す（役割導入前に作成されたユーザーはplayer）
+func (u *User) EffectiveRole() Role {
+	if !u.Role.Valid() {
+		return RolePlayer
+	}
+	return u.Role
+}
+
+// Session はログイン中のセッションを表す構造体
+// トークンそのものは保存せず、SHA-256ハッシュだけを保持する
+type Session struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ---------- InMemoryStore ----------
+
+// GetUser はIDからユーザーを取得
+func (s *InMemoryStore) GetUser(id string) (*User, error) {
+	s.usersMutex.RLock()
+	defer s.usersMutex.RUnlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// GetUserByUsername はユーザー名からユーザーを取得
+func (s *InMemoryStore) GetUserByUsername(username string) (*User, error) {
+	s.usersMutex.RLock()
+	defer s.usersMutex.RUnlock()
+
+	return findUserByUsername(s.users, username)
+}
+
+// CreateUser は新しいユーザーを作成
+func (s *InMemoryStore) CreateUser(user *User) error {
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+
+	if _, err := findUserByUsername(s.users, user.Username); err == nil {
+		return ErrUsernameTaken
+	}
+	if user.Role == "" {
+		user.Role = RolePlayer
+	}
+
+	// IDを自動生成（お題・回答と同じ形式）
+	user.ID = fmt.Sprintf("user_%d", s.nextUserID)
+	user.CreatedAt = time.Now()
+	s.nextUserID++
+
+	s.users[user.ID] = user
+	return nil
+}
+
+// UpdateUser はユーザーを更新
+func (s *InMemoryStore) UpdateUser(user *User) error {
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+
+	if _, exists := s.users[user.ID]; !exists {
+		return ErrNotFound
+	}
+	s.users[user.ID] = user
+	return nil
+}
+
+// CountUsers は役割が role のユーザーの数を返す
+func (s *InMemoryStore) CountUsers(role Role) (int, error) {
+	s.usersMutex.RLock()
+	defer s.usersMutex.RUnlock()
+
+	return countUsers(s.users, role), nil
+}
+
+// CreateSession は新しいセッションを保存
+func (s *InMemoryStore) CreateSession(session *Session) error {
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+
+	s.sessions[session.TokenHash] = session
+	return nil
+}
+
+// GetSession はトークンのハッシュからセッションを取得
+func (s *InMemoryStore) GetSession(tokenHash string) (*Session, error) {
+	s.usersMutex.RLock()
+	defer s.usersMutex.RUnlock()
+
+	session, exists := s.sessions[tokenHash]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+// DeleteSession はセッションを削除
+func (s *InMemoryStore) DeleteSession(tokenHash string) error {
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+
+	if _, exists := s.sessions[tokenHash]; !exists {
+		return ErrNotFound
+	}
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+// findUserByUsername はユーザー名を大文字小文字を区別せずに検索する
+func findUserByUsername(users map[string]*User, username string) (*User, error) {
+	for _, user := range users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// countUsers は役割が role のユーザーを数える
+func countUsers(users map[string]*User, role Role) int {
+	n := 0
+	for _, user := range users {
+		if user.EffectiveRole() == role {
+			n++
+		}
+	}
+	return n
+}
+
+// ---------- JSONStore ----------
+
+// GetUser implements DataStore
+func (s *JSONStore) GetUser(id string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// GetUserByUsername implements DataStore
+func (s *JSONStore) GetUserByUsername(username string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return findUserByUsername(s.users, username)
+}
+
+// CreateUser implements DataStore
+func (s *JSONStore) CreateUser(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := findUserByUsername(s.users, user.Username); err == nil {
+		return ErrUsernameTaken
+	}
+	if user.Role == "" {
+		user.Role = RolePlayer
+	}
+
+	// IDを自動生成
+	user.ID = fmt.Sprintf("user_%d", s.nextUserID)
+	user.CreatedAt = time.Now()
+
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opPutUser, User: user, NextUserID: s.nextUserID + 1})
+}
+
+// UpdateUser implements DataStore
+func (s *JSONStore) UpdateUser(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[user.ID]; !exists {
+		return ErrNotFound
+	}
+
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opPutUser, User: user})
+}
+
+// CountUsers implements DataStore
+func (s *JSONStore) CountUsers(role Role) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return countUsers(s.users, role), nil
+}
+
+// CreateSession implements DataStore
+func (s *JSONStore) CreateSession(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opPutSession, Session: session})
+}
+
+// GetSession implements DataStore
+func (s *JSONStore) GetSession(tokenHash string) (*Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[tokenHash]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return session, nil
+}
+
+// DeleteSession implements DataStore
+func (s *JSONStore) DeleteSession(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[tokenHash]; !exists {
+		return ErrNotFound
+	}
+
+	// ジャーナルに記録
+	return s.record(journalEntry{Op: opDeleteSession, ID: tokenHash})
+}
+
+// ---------- SQLiteStore ----------
+
+const userColumns = `id, username, password_hash, role, created_at`
+
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	var createdAt int64
+	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	user.CreatedAt = time.Unix(0, createdAt)
+	return &user, nil
+}
+
+// GetUser implements DataStore
+func (s *SQLiteStore) GetUser(id string) (*User, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+// GetUserByUsername implements DataStore
+func (s *SQLiteStore) GetUserByUsername(username string) (*User, error) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ? COLLATE NOCASE`, username))
+}
+
+// CreateUser implements DataStore
+func (s *SQLiteStore) CreateUser(user *User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ? COLLATE NOCASE`, user.Username).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrUsernameTaken
+	}
+
+	if user.Role == "" {
+		user.Role = RolePlayer
+	}
+	seq, err := nextID(tx, "user")
+	if err != nil {
+		return err
+	}
+
+	// IDを自動生成
+	user.ID = fmt.Sprintf("user_%d", seq)
+	user.CreatedAt = time.Now()
+	_, err = tx.Exec(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.PasswordHash, user.Role, user.CreatedAt.UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateUser implements DataStore
+func (s *SQLiteStore) UpdateUser(user *User) error {
+	res, err := s.db.Exec(`UPDATE users SET username = ?, password_hash = ?, role = ? WHERE id = ?`,
+		user.Username, user.PasswordHash, user.Role, user.ID)
+	if err != nil {
+		return err
+	}
+	return affectedOrNotFound(res)
+}
+
+// CountUsers implements DataStore
+func (s *SQLiteStore) CountUsers(role Role) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, role).Scan(&n)
+	return n, err
+}
+
+// CreateSession implements DataStore
+func (s *SQLiteStore) CreateSession(session *Session) error {
+	_, err := s.db.Exec(`INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+		session.TokenHash, session.UserID, session.CreatedAt.UnixNano(), session.ExpiresAt.UnixNano())
+	return err
+}
+
+// GetSession implements DataStore
+func (s *SQLiteStore) GetSession(tokenHash string) (*Session, error) {
+	var session Session
+	var createdAt, expiresAt int64
+	err := s.db.QueryRow(`SELECT token_hash, user_id, created_at, expires_at FROM sessions WHERE token_hash = ?`, tokenHash).
+		Scan(&session.TokenHash, &session.UserID, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.CreatedAt = time.Unix(0, createdAt)
+	session.ExpiresAt = time.Unix(0, expiresAt)
+	return &session, nil
+}
+
+// DeleteSession implements DataStore
+func (s *SQLiteStore) DeleteSession(tokenHash string) error {
+	res, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	if err != nil {
+		return err
+	}
+	return affectedOrNotFound(res)
+}
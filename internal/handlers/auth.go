@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"github.com/nicest414/ogiri-server/internal/validation"
+)
+
+// ユーザー名とパスワードの長さの制限
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+	minPasswordLength = 8
+	maxPasswordBytes  = 72 // bcryptが扱える上限
+)
+
+// credentials はサインアップ・ログインのリクエストボディ
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// userResponse はレスポンス用のユーザー情報（パスワードハッシュを含まない）
+type userResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Role      data.Role `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newUserResponse(user *data.User) userResponse {
+	return userResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Role:      user.EffectiveRole(),
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+// validateCredentials はサインアップ時のユーザー名とパスワードを検証し、問題を errs に追加する
+// 読み込みの段階で問題のあった項目は検査しない
+func validateCredentials(c credentials, errs *validation.Errors) {
+	if !errs.Has("username") {
+		n := utf8.RuneCountInString(c.Username)
+		switch {
+		case n < minUsernameLength:
+			errs.Add("username", response.CodeTooShort, "ユーザー名は3〜32文字で入力してください")
+		case n > maxUsernameLength:
+			errs.Add("username", response.CodeTooLong, "ユーザー名は3〜32文字で入力してください")
+		case strings.IndexFunc(c.Username, unicode.IsSpace) >= 0:
+			errs.Add("username", response.CodeInvalidValue, "ユーザー名に空白は使えません")
+		}
+	}
+	if !errs.Has("password") {
+		switch {
+		case utf8.RuneCountInString(c.Password) < minPasswordLength:
+			errs.Add("password", response.CodeTooShort, "パスワードは8文字以上で入力してください")
+		case len(c.Password) > maxPasswordBytes:
+			errs.Add("password", response.CodeTooLong, "パスワードが長すぎます")
+		}
+	}
+}
+
+// startSession はセッションを作成してクッキーに保存し、レスポンスを送信する
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, user *data.User, code int, message string) {
+	token, session, err := auth.NewSession(user.ID)
+	if err == nil {
+		err = h.store.CreateSession(session)
+	}
+	if err != nil {
+		sendServerError(w, r, "ログインに失敗しました", err)
+		return
+	}
+	auth.SetSessionCookie(w, token, session.ExpiresAt, h.proxies.IsHTTPS(r))
+
+	response.Write(w, code, message, map[string]interface{}{
+		"user":       newUserResponse(user),
+		"token":      token,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// Signup は新しいユーザーを登録してログインする
+func (h *Handler) Signup(w http.ResponseWriter, r *http.Request) {
+	var c credentials
+	errs, ok := decodeBody(w, r, &c)
+	if !ok {
+		return
+	}
+	c.Username = strings.TrimSpace(c.Username)
+
+	// バリデーション（問題は全てまとめて返す）
+	validateCredentials(c, &errs)
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
+	}
+
+	hash, err := auth.HashPassword(c.Password)
+	if err != nil {
+		sendServerError(w, r, "ユーザー登録に失敗しました", err)
+		return
+	}
+
+	user := &data.User{
+		Username:     c.Username,
+		PasswordHash: hash,
+	}
+	if err := h.store.CreateUser(user); err == data.ErrUsernameTaken {
+		sendError(w, r, conflictError(err))
+		return
+	} else if err != nil {
+		sendServerError(w, r, "ユーザー登録に失敗しました", err)
+		return
+	}
+
+	h.startSession(w, r, user, http.StatusCreated, "ユーザー登録に成功しました")
+}
+
+// Login はユーザー名とパスワードでログインする
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	var c credentials
+	errs, ok := decodeBody(w, r, &c)
+	if !ok {
+		return
+	}
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
+	}
+
+	user, err := h.store.GetUserByUsername(strings.TrimSpace(c.Username))
+	if err != nil && err != data.ErrNotFound {
+		sendServerError(w, r, "ログインに失敗しました", err)
+		return
+	}
+	// ユーザーの有無を推測されないよう、どちらの場合も同じ時間をかけて同じエラーにする
+	if !auth.CheckUserPassword(user, c.Password) {
+		sendErrorResponse(w, r, http.StatusUnauthorized, response.CodeInvalidCredentials, "ユーザー名またはパスワードが正しくありません")
+		return
+	}
+
+	h.startSession(w, r, user, http.StatusOK, "ログインに成功しました")
+}
+
+// Logout は現在のセッションを破棄する
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if token := auth.TokenFromRequest(r); token != "" {
+		if err := h.store.DeleteSession(auth.HashToken(token)); err != nil && err != data.ErrNotFound {
+			sendServerError(w, r, "ログアウトに失敗しました", err)
+			return
+		}
+	}
+	auth.ClearSessionCookie(w, h.proxies.IsHTTPS(r))
+	response.NoContent(w)
+}
+
+// Me はログイン中のユーザー情報を返す
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		sendError(w, r, errLoginRequired)
+		return
+	}
+
+	response.Write(w, http.StatusOK, "ユーザー情報の取得に成功しました", newUserResponse(user))
+}
+
+// UpdateUserRole はユーザーの役割を変更する（管理者のみ）
+func (h *Handler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermManageUsers, "") {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var body struct {
+		Role data.Role `json:"role"`
+	}
+	errs, ok := decodeBody(w, r, &body)
+	if !ok {
+		return
+	}
+	if !errs.Has("role") && !body.Role.Valid() {
+		errs.Add("role", response.CodeInvalidValue, "role には player, moderator, admin のいずれかを指定してください")
+	}
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
+	}
+
+	user, err := h.store.GetUser(id)
+	if err == data.ErrNotFound {
+		sendError(w, r, errUserNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "ユーザーの取得に失敗しました", err)
+		return
+	}
+
+	// 最後の管理者がいなくなると誰も役割を変更できなくなるため、降格させない
+	if user.EffectiveRole() == data.RoleAdmin && body.Role != data.RoleAdmin {
+		admins, err := h.store.CountUsers(data.RoleAdmin)
+		if err != nil {
+			sendServerError(w, r, "ユーザーの取得に失敗しました", err)
+			return
+		}
+		if admins <= 1 {
+			sendErrorResponse(w, r, http.StatusConflict, response.CodeLastAdmin, "最後の管理者の役割は変更できません")
+			return
+		}
+	}
+
+	// ストアが返すポインタは共有されている場合があるため、コピーを更新する
+	updated := *user
+	updated.Role = body.Role
+	if err := h.store.UpdateUser(&updated); err != nil {
+		sendServerError(w, r, "ユーザーの更新に失敗しました", err)
+		return
+	}
+
+	response.Write(w, http.StatusOK, "役割を変更しました", newUserResponse(&updated))
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/httputil"
+)
+
+// session はサインアップ・ログインのレスポンスの data
+type session struct {
+	Token string `json:"token"`
+	User  struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+// signup はユーザーを登録してトークンを返す
+func (s *testServer) signup(username, password string) string {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/api/auth/signup", `{"username":"`+username+`","password":"`+password+`"}`)
+	if rec.Code != http.StatusCreated {
+		s.t.Fatalf("サインアップ: status = %d\n%s", rec.Code, rec.Body.String())
+	}
+	var body session
+	decodeEnvelope(s.t, rec, &body)
+	return body.Token
+}
+
+// TestLogin はログインの成否と、ユーザーの有無でエラーが変わらないことを確認する
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{"正しいパスワード", "alice", "correct-password", http.StatusOK},
+		{"ユーザー名の大文字小文字と前後の空白は区別しない", " ALICE ", "correct-password", http.StatusOK},
+		{"違うパスワード", "alice", "wrong-password", http.StatusUnauthorized},
+		{"いないユーザー", "bob", "correct-password", http.StatusUnauthorized},
+		{"パスワードが空", "alice", "", http.StatusUnauthorized},
+	}
+	s := newTestServer(t)
+	s.signup("alice", "correct-password")
+	var unauthorized string
+	for _, tt := range tests {
+		rec := s.do(http.MethodPost, "/api/auth/login", `{"username":"`+tt.username+`","password":"`+tt.password+`"}`)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+			continue
+		}
+		if tt.want != http.StatusOK {
+			// ユーザーの有無を推測されないよう、どの失敗も同じレスポンスになる
+			if unauthorized == "" {
+				unauthorized = rec.Body.String()
+			} else if rec.Body.String() != unauthorized {
+				t.Errorf("%s: レスポンスが他の失敗と違います: %s", tt.name, rec.Body.String())
+			}
+			continue
+		}
+		var body session
+		decodeEnvelope(t, rec, &body)
+		if body.Token == "" || body.User.Username != "alice" {
+			t.Errorf("%s: レスポンス = %+v", tt.name, body)
+		}
+		if !strings.Contains(rec.Header().Get("Set-Cookie"), auth.CookieName+"=") {
+			t.Errorf("%s: セッションのクッキーが設定されていません", tt.name)
+		}
+	}
+}
+
+// TestSignup はサインアップの検査と、ユーザーIDをストアが振ることを確認する
+func TestSignup(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		{"登録できる", "newuser", "password1", http.StatusCreated},
+		{"大文字小文字だけが違うユーザー名", "ALICE", "password1", http.StatusConflict},
+		{"短いユーザー名", "ab", "password1", http.StatusBadRequest},
+		{"空白を含むユーザー名", "a b c", "password1", http.StatusBadRequest},
+		{"短いパスワード", "newuser", "pass", http.StatusBadRequest},
+		{"長すぎるパスワード", "newuser", strings.Repeat("p", maxPasswordBytes+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.signup("alice", "correct-password")
+			rec := s.do(http.MethodPost, "/api/auth/signup", `{"username":"`+tt.username+`","password":"`+tt.password+`"}`)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+			var body session
+			decodeEnvelope(t, rec, &body)
+			if body.User.ID != "user_2" {
+				t.Errorf("id = %q, want user_2", body.User.ID)
+			}
+			user, err := s.store.GetUserByUsername(tt.username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.PasswordHash == tt.password || !auth.CheckPassword(user.PasswordHash, tt.password) {
+				t.Error("パスワードがハッシュ化されて保存されていません")
+			}
+		})
+	}
+}
+
+// TestSession はトークン・クッキーで認証し、ログアウトや期限切れのセッションを使えないことを確認する
+func TestSession(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "correct-password")
+	other := s.signup("bob", "correct-password")
+
+	// 期限切れのセッション
+	user, _ := s.store.GetUserByUsername("alice")
+	expiredToken, expired, err := auth.NewSession(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := s.store.CreateSession(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers []string
+		want    int
+	}{
+		{"Bearer トークン", http.MethodGet, "/api/auth/me", []string{"Authorization", "Bearer " + token}, http.StatusOK},
+		{"クッキー", http.MethodGet, "/api/auth/me", []string{"Cookie", auth.CookieName + "=" + token}, http.StatusOK},
+		{"未ログイン", http.MethodGet, "/api/auth/me", nil, http.StatusUnauthorized},
+		{"知らないトークン", http.MethodGet, "/api/auth/me", []string{"Authorization", "Bearer unknown"}, http.StatusUnauthorized},
+		{"期限切れ", http.MethodGet, "/api/auth/me", []string{"Authorization", "Bearer " + expiredToken}, http.StatusUnauthorized},
+		{"ログアウト", http.MethodPost, "/api/auth/logout", []string{"Authorization", "Bearer " + token}, http.StatusNoContent},
+		{"ログアウトしたトークン", http.MethodGet, "/api/auth/me", []string{"Authorization", "Bearer " + token}, http.StatusUnauthorized},
+		{"他のユーザーのセッションは残る", http.MethodGet, "/api/auth/me", []string{"Authorization", "Bearer " + other}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := s.do(tt.method, tt.path, "", tt.headers...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+	if _, err := s.store.GetSession(expired.TokenHash); err != data.ErrNotFound {
+		t.Errorf("期限切れのセッションが削除されていません: %v", err)
+	}
+}
+
+// TestSecureCookie はHTTPSで受けたリクエストだけクッキーに Secure 属性を付けることを確認する
+// X-Forwarded-Proto は信用するプロキシ（httptest の接続元 192.0.2.1）から届いた場合だけ使う
+func TestSecureCookie(t *testing.T) {
+	s := newTestServer(t)
+	s.signup("alice", "correct-password")
+	body := `{"username":"alice","password":"correct-password"}`
+
+	proxies, err := httputil.ParseProxies("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewHandler(s.store, s.hub, nil, proxies).Routes()
+	api.Use(auth.Middleware(s.store))
+	behindProxy := &testServer{t: t, store: s.store, hub: s.hub, handler: api}
+
+	tests := []struct {
+		name    string
+		server  *testServer
+		url     string
+		headers []string
+		want    bool
+	}{
+		{"HTTPS", s, "https://example.com/api/auth/login", nil, true},
+		{"HTTP", s, "http://example.com/api/auth/login", nil, false},
+		{"クライアントが付けた X-Forwarded-Proto は信用しない", s, "http://example.com/api/auth/login", []string{"X-Forwarded-Proto", "https"}, false},
+		{"信用するプロキシの X-Forwarded-Proto", behindProxy, "http://example.com/api/auth/login", []string{"X-Forwarded-Proto", "https"}, true},
+		{"信用するプロキシからのHTTP", behindProxy, "http://example.com/api/auth/login", []string{"X-Forwarded-Proto", "http"}, false},
+	}
+	for _, tt := range tests {
+		rec := tt.server.do(http.MethodPost, tt.url, body, tt.headers...)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d\n%s", tt.name, rec.Code, rec.Body.String())
+		}
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("%s: クッキー = %v", tt.name, cookies)
+		}
+		if cookies[0].Secure != tt.want {
+			t.Errorf("%s: Secure = %v, want %v", tt.name, cookies[0].Secure, tt.want)
+		}
+	}
+}
+
+// TestOwnership は回答を投稿したユーザーが更新・削除でき、他のユーザーはできないことを確認する
+func TestOwnership(t *testing.T) {
+	s := newTestServer(t)
+	alice := "Bearer " + s.signup("alice", "correct-password")
+	bob := "Bearer " + s.signup("bob", "correct-password")
+	theme := s.createTheme("お題")
+
+	rec := s.do(http.MethodPost, "/api/themes/"+theme.ID+"/answers", `{"content":"回答"}`, "Authorization", alice)
+	var answer data.Answer
+	decodeEnvelope(t, rec, &answer)
+	path := "/api/themes/" + theme.ID + "/answers/" + answer.ID
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		headers []string
+		want    int
+	}{
+		{"匿名では更新できない", http.MethodPut, `{"content":"更新"}`, nil, http.StatusUnauthorized},
+		{"他のユーザーは更新できない", http.MethodPut, `{"content":"更新"}`, []string{"Authorization", bob}, http.StatusForbidden},
+		{"投稿者は更新できる", http.MethodPut, `{"content":"更新"}`, []string{"Authorization", alice}, http.StatusOK},
+		{"他のユーザーは削除できない", http.MethodDelete, "", []string{"Authorization", bob}, http.StatusForbidden},
+		{"投稿者は削除できる", http.MethodDelete, "", []string{"Authorization", alice}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		rec := s.do(tt.method, path, tt.body, tt.headers...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+// TestCreatedBy は作成者をリクエストボディではなくログイン中のユーザーから決めることを確認する
+func TestCreatedBy(t *testing.T) {
+	s := newTestServer(t)
+	token := s.signup("alice", "correct-password")
+	theme := s.createTheme("お題")
+
+	tests := []struct {
+		name          string
+		headers       []string
+		wantCreatedBy string
+	}{
+		{"ログイン中", []string{"Authorization", "Bearer " + token}, "alice"},
+		{"匿名", []string{"X-Client-Token", "client-1"}, ""},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodPost, "/api/themes/"+theme.ID+"/answers", `{"content":"回答","created_by":"なりすまし"}`, tt.headers...)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: status = %d\n%s", tt.name, rec.Code, rec.Body.String())
+		}
+		var answer data.Answer
+		decodeEnvelope(t, rec, &answer)
+		if answer.CreatedBy != tt.wantCreatedBy {
+			t.Errorf("%s: created_by = %q, want %q", tt.name, answer.CreatedBy, tt.wantCreatedBy)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// よく使うエラーレスポンス（送信時に変更しないため共有してよい）
+var (
+	errInvalidJSON         = response.NewError(http.StatusBadRequest, response.CodeInvalidJSON, "無効なリクエスト形式です")
+	errBodyTooLarge        = response.NewError(http.StatusRequestEntityTooLarge, response.CodeBodyTooLarge, fmt.Sprintf("リクエストボディは%dバイト以内にしてください", maxBodyBytes))
+	errLoginRequired       = response.NewError(http.StatusUnauthorized, response.CodeUnauthorized, "ログインが必要です")
+	errForbidden           = response.NewError(http.StatusForbidden, response.CodeForbidden, "この操作を行う権限がありません")
+	errThemeNotFound       = response.NewError(http.StatusNotFound, response.CodeThemeNotFound, "お題が見つかりません")
+	errAnswerNotFound      = response.NewError(http.StatusNotFound, response.CodeAnswerNotFound, "回答が見つかりません")
+	errUserNotFound        = response.NewError(http.StatusNotFound, response.CodeUserNotFound, "ユーザーが見つかりません")
+	errClientTokenRequired = response.NewError(http.StatusBadRequest, response.CodeClientTokenRequired, clientTokenHeader+" ヘッダーが必要です")
+	errInvalidCursor       = &response.Error{
+		Status:  http.StatusBadRequest,
+		Code:    response.CodeInvalidCursor,
+		Message: data.ErrInvalidCursor.Error(),
+		Details: []response.Detail{{Field: "cursor", Code: response.CodeInvalidValue, Message: data.ErrInvalidCursor.Error()}},
+	}
+)
+
+// conflictErrors はストアが返す重複のエラーとエラーコードの対応
+var conflictErrors = map[error]response.Code{
+	data.ErrAlreadyLiked:    response.CodeAlreadyLiked,
+	data.ErrNotLiked:        response.CodeNotLiked,
+	data.ErrAlreadyReported: response.CodeAlreadyReported,
+	data.ErrUsernameTaken:   response.CodeUsernameTaken,
+	data.ErrVersionConflict: response.CodeVersionConflict,
+}
+
+// conflictError はストアが返す重複のエラーを409エラーにする
+func conflictError(err error) *response.Error {
+	return response.NewError(http.StatusConflict, conflictErrors[err], err.Error())
+}
+
+// queryError はクエリパラメータ name が不正なことを表す400エラーを返す
+func queryError(name, message string) *response.Error {
+	return response.Invalid(response.CodeInvalidQuery, response.Detail{Field: name, Code: response.CodeInvalidValue, Message: message})
+}
+
+// fieldError はリクエストボディの項目 field が不正なことを表す400エラーを返す
+func fieldError(field string, code response.Code, message string) *response.Error {
+	return response.Invalid(response.CodeValidationFailed, response.Detail{Field: field, Code: code, Message: message})
+}
+
+// NotFound はどのルートにも一致しないリクエストに404エラーを返す
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	sendErrorResponse(w, r, http.StatusNotFound, response.CodeNotFound, "エンドポイントが見つかりません")
+}
+
+// MethodNotAllowed はルートが対応していないメソッドのリクエストに405エラーを返す
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	sendErrorResponse(w, r, http.StatusMethodNotAllowed, response.CodeMethodNotAllowed, "このメソッドには対応していません")
+}
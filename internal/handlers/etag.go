@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// etag はお題・回答のバージョンを ETag の値にする
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag はレスポンスに ETag ヘッダーを付ける
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// matchETag は If-Match・If-None-Match の値（カンマ区切り、または *）に tag が含まれるか判定する
+// weak なら W/ の付いた値も同じものとみなす（If-None-Match の弱い比較）
+func matchETag(header, tag string, weak bool) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" {
+			return true
+		}
+		if weak {
+			v = strings.TrimPrefix(v, "W/")
+		}
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified は If-None-Match が現在のバージョンに一致すれば304を返して true を返す
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !matchETag(header, etag(version), true) {
+		return false
+	}
+	setETag(w, version)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// checkIfMatch は If-Match が現在のバージョンに一致しなければ412を返して false を返す
+// If-Match が無ければ確認しない（更新の直前にストアがバージョンを確認する）
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int64, message string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchETag(header, etag(version), false) {
+		return true
+	}
+	// クライアントが取得し直さなくても済むよう、現在の ETag を返す
+	setETag(w, version)
+	sendError(w, r, response.NewError(http.StatusPreconditionFailed, response.CodePrecondition, message))
+	return false
+}
+
+// ifMatchVersion は If-Match があれば確認済みの version を、無ければ0を返す
+// ストアの削除に渡し、確認してから削除するまでに他の操作で更新されていれば削除しないようにする
+func ifMatchVersion(r *http.Request, version int64) int64 {
+	if r.Header.Get("If-Match") == "" {
+		return 0
+	}
+	return version
+}
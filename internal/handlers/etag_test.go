@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// TestConditionalGet は If-None-Match が現在の ETag に一致すれば304を返すことを確認する
+func TestConditionalGet(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	answer := s.createAnswer(theme.ID, "回答")
+
+	tests := []struct {
+		name        string
+		path        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"お題・条件なし", "/api/themes/" + theme.ID, "", http.StatusOK},
+		{"お題・一致", "/api/themes/" + theme.ID, `"1"`, http.StatusNotModified},
+		{"お題・弱いETag", "/api/themes/" + theme.ID, `W/"1"`, http.StatusNotModified},
+		{"お題・複数のうち1つが一致", "/api/themes/" + theme.ID, `"5", "1"`, http.StatusNotModified},
+		{"お題・古い", "/api/themes/" + theme.ID, `"0"`, http.StatusOK},
+		{"回答・一致", "/api/themes/" + theme.ID + "/answers/" + answer.ID, `"1"`, http.StatusNotModified},
+		{"回答・*", "/api/themes/" + theme.ID + "/answers/" + answer.ID, `*`, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		var headers []string
+		if tt.ifNoneMatch != "" {
+			headers = []string{"If-None-Match", tt.ifNoneMatch}
+		}
+		rec := s.do(http.MethodGet, tt.path, "", headers...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if got := rec.Header().Get("ETag"); got != `"1"` {
+			t.Errorf("%s: ETag = %s, want \"1\"", tt.name, got)
+		}
+		if tt.want == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: 304 に本文があります: %s", tt.name, rec.Body.String())
+		}
+	}
+}
+
+// TestIfMatch は If-Match が古ければ412を返して変更しないことを確認する
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		ifMatch  string
+		want     int
+		wantETag string
+	}{
+		{"お題の更新・一致", http.MethodPut, "/api/themes/{theme}", `{"title":"更新","active":true}`, `"1"`, http.StatusOK, `"2"`},
+		{"お題の更新・古い", http.MethodPut, "/api/themes/{theme}", `{"title":"更新","active":true}`, `"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"お題の部分更新・古い", http.MethodPatch, "/api/themes/{theme}", `{"title":"更新"}`, `"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"お題の削除・古い", http.MethodDelete, "/api/themes/{theme}", "", `"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"お題の削除・一致", http.MethodDelete, "/api/themes/{theme}", "", `"1"`, http.StatusNoContent, ""},
+		{"回答の更新・古い", http.MethodPut, "/api/themes/{theme}/answers/{answer}", `{"content":"更新"}`, `"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"回答の更新・*", http.MethodPut, "/api/themes/{theme}/answers/{answer}", `{"content":"更新"}`, `*`, http.StatusOK, `"2"`},
+		{"回答の削除・古い", http.MethodDelete, "/api/themes/{theme}/answers/{answer}", "", `"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"回答の非表示・古い", http.MethodPost, "/api/themes/{theme}/answers/{answer}/moderation", `{"action":"hide"}`, `"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"回答の非表示・一致", http.MethodPost, "/api/themes/{theme}/answers/{answer}/moderation", `{"action":"hide"}`, `"1"`, http.StatusOK, `"2"`},
+		{"モデレーションでの削除・古い", http.MethodPost, "/api/themes/{theme}/answers/{answer}/moderation", `{"action":"delete"}`, `"0"`, http.StatusPreconditionFailed, `"1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := s.login("admin", data.RoleAdmin)
+			theme := s.createTheme("お題")
+			answer := s.createAnswer(theme.ID, "回答")
+			path := strings.NewReplacer("{theme}", theme.ID, "{answer}", answer.ID).Replace(tt.path)
+
+			rec := s.do(tt.method, path, tt.body, "Authorization", admin, "If-Match", tt.ifMatch)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", got, tt.wantETag)
+			}
+			if tt.want == http.StatusPreconditionFailed {
+				if env := decodeEnvelope(t, rec, nil); env.Error == nil || env.Error.Code != response.CodePrecondition {
+					t.Errorf("error = %+v, want PRECONDITION_FAILED", env.Error)
+				}
+				// 変更されていないこと
+				if stored, err := s.store.GetAnswer(answer.ID, theme.ID); err != nil || stored.Version != 1 {
+					t.Errorf("回答が変更されています: %+v, %v", stored, err)
+				}
+				if stored, err := s.store.GetTheme(theme.ID); err != nil || stored.Version != 1 {
+					t.Errorf("お題が変更されています: %+v, %v", stored, err)
+				}
+			}
+		})
+	}
+}
+
+// racingStore は読み込みの直後に一度だけ interleave を呼び、
+// ハンドラーが If-Match を確認してからストアに書き込むまでの間に他の操作が入った状態を再現する
+type racingStore struct {
+	data.DataStore
+	once       sync.Once
+	interleave func()
+}
+
+func (s *racingStore) GetTheme(id string) (*data.Theme, error) {
+	theme, err := s.DataStore.GetTheme(id)
+	s.once.Do(s.interleave)
+	return theme, err
+}
+
+func (s *racingStore) GetAnswer(id string, themeID string) (*data.Answer, error) {
+	answer, err := s.DataStore.GetAnswer(id, themeID)
+	s.once.Do(s.interleave)
+	return answer, err
+}
+
+// TestDeleteRacesUpdate は If-Match を確認した後に更新されたお題・回答を削除しないことを確認する
+func TestDeleteRacesUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		// method は削除のリクエスト、update は確認の直後に割り込む更新
+		method string
+		update func(store data.DataStore, theme *data.Theme, answer *data.Answer) error
+	}{
+		{"お題", "/api/themes/{theme}", "", http.MethodDelete, func(store data.DataStore, theme *data.Theme, answer *data.Answer) error {
+			updated := *theme
+			updated.Title = "割り込み"
+			return store.UpdateTheme(&updated)
+		}},
+		{"回答", "/api/themes/{theme}/answers/{answer}", "", http.MethodDelete, func(store data.DataStore, theme *data.Theme, answer *data.Answer) error {
+			_, err := store.LikeAnswer(answer.ID, theme.ID, "anon:other")
+			return err
+		}},
+		{"モデレーション", "/api/themes/{theme}/answers/{answer}/moderation", `{"action":"delete"}`, http.MethodPost, func(store data.DataStore, theme *data.Theme, answer *data.Answer) error {
+			_, err := store.LikeAnswer(answer.ID, theme.ID, "anon:other")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := data.NewInMemoryStore()
+			store := &racingStore{DataStore: memory}
+			s := newTestServerWith(t, store)
+			admin := s.login("admin", data.RoleAdmin)
+			theme := s.createTheme("お題")
+			answer := s.createAnswer(theme.ID, "回答")
+			path := strings.NewReplacer("{theme}", theme.ID, "{answer}", answer.ID).Replace(tt.path)
+			store.interleave = func() {
+				if err := tt.update(memory, theme, answer); err != nil {
+					t.Errorf("割り込む更新に失敗しました: %v", err)
+				}
+			}
+
+			rec := s.do(tt.method, path, tt.body, "Authorization", admin, "If-Match", `"1"`)
+			if rec.Code != http.StatusConflict {
+				t.Fatalf("status = %d, want 409\n%s", rec.Code, rec.Body.String())
+			}
+			if env := decodeEnvelope(t, rec, nil); env.Error == nil || env.Error.Code != response.CodeVersionConflict {
+				t.Errorf("error = %+v, want VERSION_CONFLICT", env.Error)
+			}
+			if _, err := memory.GetAnswer(answer.ID, theme.ID); err != nil {
+				t.Errorf("更新された回答が削除されています: %v", err)
+			}
+		})
+	}
+}
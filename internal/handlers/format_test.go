@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/moderation"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// TestResponseFormat は X-Response-Format を送らなければ以前の形式で返し、envelope を送れば共通の形式で返すことを確認する
+func TestResponseFormat(t *testing.T) {
+	s := newModeratedServer(t, moderation.ActionReject, "ばか")
+	theme := s.createTheme("お題")
+	answer := s.createAnswer(theme.ID, "回答")
+	admin := s.login("admin", data.RoleAdmin)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		format string // 空なら既定の形式
+		want   string // レスポンスの最上位のキー（並びは問わない）
+	}{
+		{"回答の取得は本文のまま", http.MethodGet, "/api/themes/" + theme.ID + "/answers/" + answer.ID, "", "", "id"},
+		{"回答の一覧は配列のまま", http.MethodGet, "/api/themes/" + theme.ID + "/answers", "", "", "[]"},
+		{"お題の一覧は以前から共通の形式", http.MethodGet, "/api/themes", "", "", "success"},
+		{"エラーは error にメッセージ", http.MethodGet, "/api/themes/none", "", "", "error"},
+		{"NGワードは violations", http.MethodPost, "/api/themes/" + theme.ID + "/answers", `{"content":"ばか"}`, "", "violations"},
+		{"ヘッダーで以前の形式を選べる", http.MethodGet, "/api/themes/" + theme.ID + "/answers/" + answer.ID, "", "legacy", "id"},
+		{"ヘッダーで共通の形式を選べる", http.MethodGet, "/api/themes/" + theme.ID + "/answers/" + answer.ID, "", "envelope", "data"},
+		{"共通の形式のエラーはコード付き", http.MethodGet, "/api/themes/none", "", "envelope", "error.code"},
+	}
+	for _, tt := range tests {
+		// do が送る X-Response-Format を空で上書きすると、ヘッダーが無いときと同じく既定の形式になる
+		rec := s.do(tt.method, tt.path, tt.body, response.FormatHeader, tt.format, "Authorization", admin, clientTokenHeader, "client-1")
+
+		var body interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v\n%s", tt.name, err, rec.Body.String())
+		}
+		var ok bool
+		switch v := body.(type) {
+		case []interface{}:
+			ok = tt.want == "[]"
+		case map[string]interface{}:
+			if tt.want == "error.code" {
+				e, _ := v["error"].(map[string]interface{})
+				ok = e["code"] == string(response.CodeThemeNotFound)
+			} else if tt.want == "error" {
+				_, ok = v["error"].(string)
+			} else {
+				_, ok = v[tt.want]
+			}
+			if _, wrapped := v["success"]; wrapped && tt.want == "id" {
+				ok = false
+			}
+		}
+		if !ok {
+			t.Errorf("%s: want %s\n%s", tt.name, tt.want, rec.Body.String())
+		}
+	}
+}
@@ -1,71 +1,232 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/auth"
 	"github.com/nicest414/ogiri-server/internal/data"
-
-	"crypto/rand"
-	"encoding/hex"
+	"github.com/nicest414/ogiri-server/internal/httputil"
+	"github.com/nicest414/ogiri-server/internal/logging"
+	"github.com/nicest414/ogiri-server/internal/moderation"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"github.com/nicest414/ogiri-server/internal/validation"
 )
 
 // Handler はAPIハンドラーを管理する構造体
 type Handler struct {
-	store data.DataStore
+	store   data.DataStore
+	hub     *realtime.Hub
+	filter  *moderation.Filter // nil ならNGワードを検査しない
+	proxies httputil.Proxies   // X-Forwarded-Proto を信用するリバースプロキシ
 }
 
 // NewHandler は新しいHandlerインスタンスを返す
-func NewHandler(store data.DataStore) *Handler {
-	return &Handler{store: store}
+func NewHandler(store data.DataStore, hub *realtime.Hub, filter *moderation.Filter, proxies httputil.Proxies) *Handler {
+	return &Handler{store: store, hub: hub, filter: filter, proxies: proxies}
 }
 
-// 一意のIDを生成する
-func generateID() string {
-	bytes := make([]byte, 8) // 16文字のIDになる
-	if _, err := rand.Read(bytes); err != nil {
-		// エラーが発生した場合はタイムスタンプを使用
-		return fmt.Sprintf("%d", time.Now().UnixNano())
-	}
-	return hex.EncodeToString(bytes)
+// sendError はエラーレスポンスを送信するヘルパー関数
+// レスポンスの形式（共通の形式か互換モードか）はリクエストごとに response パッケージが決める
+func sendError(w http.ResponseWriter, r *http.Request, e *response.Error) {
+	response.WriteError(w, r, e)
 }
 
 // エラーレスポンスを送信するヘルパー関数
-func sendErrorResponse(w http.ResponseWriter, code int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(map[string]string{"error": message})
+// code には機械で判別できるエラーコード、message には利用者向けのメッセージを指定する
+func sendErrorResponse(w http.ResponseWriter, r *http.Request, status int, code response.Code, message string) {
+	sendError(w, r, response.NewError(status, code, message))
+}
+
+// sendServerError は原因のエラーをリクエストIDとともにログに残してから500エラーを返す
+// クライアントには原因を見せず、message だけを返す
+func sendServerError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	logging.FromContext(r.Context()).Error(message, "error", err)
+	sendErrorResponse(w, r, http.StatusInternalServerError, response.CodeInternal, message)
+}
+
+// setCreator はログイン中のユーザーを作成者として設定する
+// リクエストボディの created_by は信用せず、匿名の場合は空にする
+func setCreator(r *http.Request, createdBy *string, userID *string) {
+	*createdBy, *userID = "", ""
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		*createdBy, *userID = user.Username, user.ID
+	}
+}
+
+// authorize はログイン中のユーザーが操作を行えるか確認し、行えない場合はエラーレスポンスを送る
+// 未ログインなら401、権限が無ければ403を返す。ownerID には対象の作成者のユーザーID（無ければ空）を渡す
+func authorize(w http.ResponseWriter, r *http.Request, perm auth.Permission, ownerID string) bool {
+	user := auth.UserFromContext(r.Context())
+	if auth.Can(user, perm, ownerID) {
+		return true
+	}
+	if user == nil {
+		sendError(w, r, errLoginRequired)
+		return false
+	}
+	sendError(w, r, errForbidden)
+	return false
 }
 
-// JSONレスポンスを送信するヘルパー関数
-func sendJSONResponse(w http.ResponseWriter, code int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	if data != nil {
-		json.NewEncoder(w).Encode(data)
+// clientTokenHeader は匿名の投票者を識別するためにクライアントが送るヘッダー
+const clientTokenHeader = "X-Client-Token"
+
+// voterID はいいねの重複判定に使う投票者IDを返す（識別できない場合は空文字列）
+// ログイン中はユーザー、そうでなければクライアントトークンで識別する
+func voterID(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return "user:" + user.ID
+	}
+	if token := r.Header.Get(clientTokenHeader); token != "" {
+		return "anon:" + token
 	}
+	return ""
+}
+
+// withLikedByMe はリクエストした投票者のいいね状態を付けた回答のコピーを返す
+// ストアが返すポインタは共有されている場合があるため、元の値は変更しない
+func (h *Handler) withLikedByMe(r *http.Request, answer *data.Answer) (*data.Answer, error) {
+	result := *answer
+	if voter := voterID(r); voter != "" {
+		liked, err := h.store.HasLiked(answer.ID, voter)
+		if err != nil {
+			return nil, err
+		}
+		result.LikedByMe = liked
+	}
+	return &result, nil
+}
+
+// 一覧取得の件数の既定値（cursor だけを指定した場合）と上限
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// parseListOptions はクエリパラメータから一覧取得の条件を組み立てる
+// sortKeys には並び替えに使えるキーを指定する
+// limit も cursor も指定しなければ、ページングを導入する前のクライアントのために件数を制限しない（opts.Limit は0）
+func parseListOptions(r *http.Request, sortKeys ...string) (data.ListOptions, *response.Error) {
+	q := r.URL.Query()
+	opts := data.ListOptions{
+		Cursor:    q.Get("cursor"),
+		CreatedBy: q.Get("created_by"),
+	}
+	if opts.Cursor != "" {
+		opts.Limit = defaultListLimit
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return opts, queryError("limit", fmt.Sprintf("limit は1〜%dの整数で指定してください", maxListLimit))
+		}
+		opts.Limit = limit
+	}
+
+	if v := q.Get("sort"); v != "" {
+		valid := false
+		for _, key := range sortKeys {
+			if v == key {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return opts, queryError("sort", fmt.Sprintf("sort には %s のいずれかを指定してください", strings.Join(sortKeys, ", ")))
+		}
+		opts.SortBy = v
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, queryError("order", "order には asc または desc を指定してください")
+	}
+
+	if v := q.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, queryError("active", "active には true または false を指定してください")
+		}
+		opts.Active = &active
+	}
+
+	for name, dst := range map[string]*time.Time{
+		"created_before": &opts.CreatedBefore,
+		"created_after":  &opts.CreatedAfter,
+	} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return opts, queryError(name, fmt.Sprintf("%s はRFC3339形式（例: 2024-01-02T15:04:05+09:00）で指定してください", name))
+			}
+			*dst = t
+		}
+	}
+
+	return opts, nil
+}
+
+// canView は確認待ち・非表示の回答をリクエストしたユーザーが閲覧できるか判定する
+// 公開中のものは誰でも、それ以外は作成者本人と moderator・admin のみ閲覧できる
+func canView(r *http.Request, state data.ModerationState, ownerID string) bool {
+	if state == data.ModerationNone {
+		return true
+	}
+	return isOwner(r, ownerID) || auth.Can(auth.UserFromContext(r.Context()), auth.PermReviewContent, "")
+}
+
+// canViewTheme は審査待ち・却下のお題をリクエストしたユーザーが閲覧できるか判定する
+// 承認済みのものは誰でも、それ以外は投稿者本人と admin のみ閲覧できる
+func canViewTheme(r *http.Request, theme *data.Theme) bool {
+	if theme.Approved() {
+		return true
+	}
+	return isOwner(r, theme.UserID) || auth.Can(auth.UserFromContext(r.Context()), auth.PermApproveTheme, "")
+}
+
+// isOwner はログイン中のユーザーが作成者本人か判定する
+func isOwner(r *http.Request, ownerID string) bool {
+	user := auth.UserFromContext(r.Context())
+	return user != nil && ownerID != "" && user.ID == ownerID
 }
 
 // ---------- お題関連のハンドラー ----------
 
 // ListThemes は全てのお題をリストアップ
 func (h *Handler) ListThemes(w http.ResponseWriter, r *http.Request) {
-	themes, err := h.store.ListThemes()
+	opts, qerr := parseListOptions(r, data.SortByCreatedAt, data.SortByUpdatedAt)
+	if qerr != nil {
+		sendError(w, r, qerr)
+		return
+	}
+	opts.Status = data.ThemeStatus(r.URL.Query().Get("status"))
+	if opts.Status != "" && !opts.Status.Valid() {
+		sendError(w, r, queryError("status", "status には upcoming, open, closed のいずれかを指定してください"))
+		return
+	}
+
+	themes, nextCursor, err := h.store.ListThemes(opts)
+	if err == data.ErrInvalidCursor {
+		sendError(w, r, errInvalidCursor)
+		return
+	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の取得に失敗しました")
+		sendServerError(w, r, "お題の取得に失敗しました", err)
 		return
 	}
 	
-	// 統一されたレスポンス形式
-	response := map[string]interface{}{
-		"success": true,
-		"message": "お題一覧の取得に成功しました",
-		"data":    themes,
-	}
-	sendJSONResponse(w, http.StatusOK, response)
+	// お題一覧は以前から共通の形式で返していたため、互換モードでも形式を変えない
+	response.WriteEnvelopePage(w, r, "お題一覧の取得に成功しました", themes, nextCursor)
 }
 
 // GetTheme は特定のお題を取得
@@ -74,101 +235,197 @@ func (h *Handler) GetTheme(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	theme, err := h.store.GetTheme(id)
-	if err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "お題が見つかりません")
+	if err == data.ErrNotFound || (err == nil && !canViewTheme(r, theme)) {
+		sendError(w, r, errThemeNotFound)
 		return
 	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の取得に失敗しました")
+		sendServerError(w, r, "お題の取得に失敗しました", err)
+		return
+	}
+	if notModified(w, r, theme.Version) {
 		return
 	}
-	sendJSONResponse(w, http.StatusOK, theme)
+	setETag(w, theme.Version)
+	response.WriteData(w, r, http.StatusOK, "お題の取得に成功しました", theme)
 }
 
 // CreateTheme は新しいお題を作成
+// admin が作成したお題はそのまま承認済みになり、それ以外の投稿は審査待ちになる
 func (h *Handler) CreateTheme(w http.ResponseWriter, r *http.Request) {
-	var theme data.Theme
-	if err := json.NewDecoder(r.Body).Decode(&theme); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "無効なリクエスト形式です")
+	if !authorize(w, r, auth.PermSubmitTheme, "") {
 		return
 	}
 
-	// バリデーション
-	if theme.Title == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "タイトルは必須です")
+	var in themeInput
+	errs, ok := decodeBody(w, r, &in)
+	if !ok {
 		return
 	}
+	theme := in.theme()
 
-	// IDと時間の設定はJSONStoreで行うため、ここでは設定しない
+	// バリデーション（問題は全てまとめて返す）
+	errs.Merge(validation.Theme(&theme))
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
+	}
+
+	flagged, ok := h.moderate(w, r,
+		moderation.Field{Name: "title", Text: theme.Title},
+		moderation.Field{Name: "description", Text: theme.Description})
+	if !ok {
+		return
+	}
+
+	// 審査状態はリクエストボディの値を使わない
+	theme.SubmissionStatus = data.SubmissionPending
+	theme.RejectionReason = ""
+	if auth.Can(auth.UserFromContext(r.Context()), auth.PermCreateTheme, "") && !flagged {
+		theme.SubmissionStatus = data.SubmissionApproved
+	}
+
+	// 審査待ちのお題は承認されるまで受付しない
+	// 承認済みなら指定された active を使い、省略時は受付期間から決める（受付開始前のお題はスケジューラーが開始日時に有効化する）
+	switch {
+	case !theme.Approved():
+		theme.Active = false
+	case in.Active != nil:
+		theme.Active = *in.Active
+	default:
+		theme.Active = theme.InWindow(time.Now())
+	}
+
+	// IDと時間の設定はストアで行うため、ここでは設定しない
+	setCreator(r, &theme.CreatedBy, &theme.UserID)
 
 	if err := h.store.CreateTheme(&theme); err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の作成に失敗しました")
+		sendServerError(w, r, "お題の作成に失敗しました", err)
 		return
 	}
 
 	// 成功レスポンス構造を修正
-	response := map[string]interface{}{
-		"success": true,
-		"message": "お題が正常に作成されました",
-		"data":    theme,
+	message := "お題が正常に作成されました"
+	if !theme.Approved() {
+		message = "お題を受け付けました。審査の後に公開されます"
 	}
-	sendJSONResponse(w, http.StatusCreated, response)
+	setETag(w, theme.Version)
+	response.Write(w, http.StatusCreated, message, theme)
 }
 
 // UpdateTheme はお題を更新
 func (h *Handler) UpdateTheme(w http.ResponseWriter, r *http.Request) {
+	var in themeInput
+	h.updateTheme(w, r, &in)
+}
+
+// PatchTheme はお題を部分更新（JSON Merge Patch、RFC 7396）
+func (h *Handler) PatchTheme(w http.ResponseWriter, r *http.Request) {
+	var p themePatch
+	h.updateTheme(w, r, &p)
+}
+
+// updateTheme はリクエストボディを change に読み込み、お題に反映して保存する
+func (h *Handler) updateTheme(w http.ResponseWriter, r *http.Request, change themeChange) {
+	if !authorize(w, r, auth.PermUpdateTheme, "") {
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	var updatedTheme data.Theme
-	if err := json.NewDecoder(r.Body).Decode(&updatedTheme); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "無効なリクエスト形式です")
+	errs, ok := decodeBody(w, r, change)
+	if !ok {
 		return
 	}
 
 	// 現在のお題を取得
 	currentTheme, err := h.store.GetTheme(id)
 	if err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "お題が見つかりません")
+		sendError(w, r, errThemeNotFound)
 		return
 	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の取得に失敗しました")
+		sendServerError(w, r, "お題の取得に失敗しました", err)
+		return
+	}
+	// ストアが返すポインタは共有されている場合があるため、コピーを更新する（検査で拒否した変更を残さない）
+	theme := *currentTheme
+	currentTheme = &theme
+	if !checkIfMatch(w, r, currentTheme.Version, "お題は他の操作で更新されています。取得し直してから再度お試しください") {
 		return
 	}
 
 	// 更新されたフィールドを適用
-	if updatedTheme.Title != "" {
-		currentTheme.Title = updatedTheme.Title
+	change.apply(currentTheme, &errs)
+	errs.Merge(validation.Theme(currentTheme))
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
 	}
-	if updatedTheme.Description != "" {
-		currentTheme.Description = updatedTheme.Description
+	flagged, ok := h.moderate(w, r,
+		moderation.Field{Name: "title", Text: currentTheme.Title},
+		moderation.Field{Name: "description", Text: currentTheme.Description})
+	if !ok {
+		return
+	}
+	if flagged {
+		// NGワードを含むお題は審査待ちに戻して非公開にする
+		currentTheme.SubmissionStatus = data.SubmissionPending
+		currentTheme.Active = false
 	}
-	currentTheme.Active = updatedTheme.Active
 	currentTheme.UpdatedAt = time.Now()
 
-	if err := h.store.UpdateTheme(currentTheme); err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の更新に失敗しました")
+	if err := h.store.UpdateTheme(currentTheme); err == data.ErrVersionConflict {
+		sendError(w, r, conflictError(err))
+		return
+	} else if err != nil {
+		sendServerError(w, r, "お題の更新に失敗しました", err)
 		return
 	}
 
-	sendJSONResponse(w, http.StatusOK, currentTheme)
+	setETag(w, currentTheme.Version)
+	response.WriteData(w, r, http.StatusOK, "お題を更新しました", currentTheme)
 }
 
 // DeleteTheme はお題を削除
 func (h *Handler) DeleteTheme(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermDeleteTheme, "") {
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if err := h.store.DeleteTheme(id); err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "お題が見つかりません")
+	var version int64
+	if r.Header.Get("If-Match") != "" {
+		theme, err := h.store.GetTheme(id)
+		if err == data.ErrNotFound {
+			sendError(w, r, errThemeNotFound)
+			return
+		}
+		if err != nil {
+			sendServerError(w, r, "お題の取得に失敗しました", err)
+			return
+		}
+		if !checkIfMatch(w, r, theme.Version, "お題は他の操作で更新されています。取得し直してから再度お試しください") {
+			return
+		}
+		version = theme.Version
+	}
+
+	if err := h.store.DeleteTheme(id, version); err == data.ErrNotFound {
+		sendError(w, r, errThemeNotFound)
+		return
+	} else if err == data.ErrVersionConflict {
+		sendError(w, r, conflictError(err))
 		return
 	} else if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の削除に失敗しました")
+		sendServerError(w, r, "お題の削除に失敗しました", err)
 		return
 	}
 
-	sendJSONResponse(w, http.StatusNoContent, nil)
+	response.NoContent(w)
 }
 
 // ---------- 回答関連のハンドラー ----------
@@ -179,22 +436,48 @@ func (h *Handler) ListAnswers(w http.ResponseWriter, r *http.Request) {
 	themeID := vars["themeID"]
 
 	// テーマの存在確認
-	_, err := h.store.GetTheme(themeID)
-	if err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "お題が見つかりません")
+	theme, err := h.store.GetTheme(themeID)
+	if err == data.ErrNotFound || (err == nil && !canViewTheme(r, theme)) {
+		sendError(w, r, errThemeNotFound)
 		return
 	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の取得に失敗しました")
+		sendServerError(w, r, "お題の取得に失敗しました", err)
 		return
 	}
 
-	answers, err := h.store.ListAnswers(themeID)
+	opts, qerr := parseListOptions(r, data.SortByCreatedAt, data.SortByUpdatedAt, data.SortByLikes)
+	if qerr != nil {
+		sendError(w, r, qerr)
+		return
+	}
+	if !parseModeration(w, r, &opts) {
+		return
+	}
+
+	answers, nextCursor, err := h.store.ListAnswers(themeID, opts)
+	if err == data.ErrInvalidCursor {
+		sendError(w, r, errInvalidCursor)
+		return
+	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "回答の取得に失敗しました")
+		sendServerError(w, r, "回答の取得に失敗しました", err)
+		return
+	}
+	for i, answer := range answers {
+		if answers[i], err = h.withLikedByMe(r, answer); err != nil {
+			sendServerError(w, r, "回答の取得に失敗しました", err)
+			return
+		}
+	}
+
+	// limit・cursor を指定しない以前のクライアントには、全件を以前と同じ形式で返す
+	if opts.Limit == 0 {
+		response.WriteData(w, r, http.StatusOK, "回答一覧の取得に成功しました", answers)
 		return
 	}
-	sendJSONResponse(w, http.StatusOK, answers)
+	// ページング情報を返すため、お題一覧と同じレスポンス形式にする
+	response.WritePage(w, r, "回答一覧の取得に成功しました", answers, nextCursor)
 }
 
 // GetAnswer は特定の回答を取得
@@ -204,105 +487,170 @@ func (h *Handler) GetAnswer(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	answer, err := h.store.GetAnswer(id, themeID)
-	if err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "回答が見つかりません")
+	if err == data.ErrNotFound || (err == nil && !canView(r, answer.Moderation, answer.UserID)) {
+		sendError(w, r, errAnswerNotFound)
+		return
+	}
+	// 投票者のいいねが変わるとバージョンも変わるため、liked_by_me を付ける前に比較してよい
+	if err == nil && notModified(w, r, answer.Version) {
 		return
 	}
+	if err == nil {
+		answer, err = h.withLikedByMe(r, answer)
+	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "回答の取得に失敗しました")
+		sendServerError(w, r, "回答の取得に失敗しました", err)
 		return
 	}
-	sendJSONResponse(w, http.StatusOK, answer)
+	setETag(w, answer.Version)
+	response.WriteData(w, r, http.StatusOK, "回答の取得に成功しました", answer)
 }
 
 // SubmitAnswer は新しい回答を投稿
 func (h *Handler) SubmitAnswer(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermSubmitAnswer, "") {
+		return
+	}
+
 	vars := mux.Vars(r)
 	themeID := vars["themeID"]
 
 	// テーマの存在確認
 	theme, err := h.store.GetTheme(themeID)
-	if err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "お題が見つかりません")
+	if err == data.ErrNotFound || (err == nil && !canViewTheme(r, theme)) {
+		sendError(w, r, errThemeNotFound)
 		return
 	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "お題の取得に失敗しました")
+		sendServerError(w, r, "お題の取得に失敗しました", err)
 		return
 	}
 
-	// 非アクティブなテーマには回答できない
+	// 受付期間外・非アクティブなテーマには回答できない
+	// スケジューラーの反映を待たずに受付期間で判定する
+	now := time.Now()
+	if theme.OpensAt != nil && now.Before(*theme.OpensAt) {
+		sendError(w, r, response.Errorf(http.StatusBadRequest, response.CodeThemeNotOpen, "このお題はまだ受付を開始していません（受付開始: %s）", theme.OpensAt.Format(time.RFC3339)))
+		return
+	}
+	if theme.ClosesAt != nil && !now.Before(*theme.ClosesAt) {
+		sendError(w, r, response.Errorf(http.StatusBadRequest, response.CodeThemeClosed, "このお題の受付は終了しました（受付終了: %s）", theme.ClosesAt.Format(time.RFC3339)))
+		return
+	}
 	if !theme.Active {
-		sendErrorResponse(w, http.StatusBadRequest, "このお題は現在受付を停止しています")
+		sendErrorResponse(w, r, http.StatusBadRequest, response.CodeThemeInactive, "このお題は現在受付を停止しています")
+		return
+	}
+	if !theme.Approved() {
+		sendErrorResponse(w, r, http.StatusBadRequest, response.CodeThemeNotApproved, "このお題は審査中のため回答できません")
 		return
 	}
 
-	var answer data.Answer
-	if err := json.NewDecoder(r.Body).Decode(&answer); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "無効なリクエスト形式です")
+	var in answerInput
+	errs, ok := decodeBody(w, r, &in)
+	if !ok {
 		return
 	}
+	answer := data.Answer{Content: in.Content}
 
 	// バリデーション
-	if answer.Content == "" {
-		sendErrorResponse(w, http.StatusBadRequest, "回答内容は必須です")
+	errs.Merge(validation.Answer(&answer))
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
 		return
 	}
+	flagged, ok := h.moderate(w, r, moderation.Field{Name: "content", Text: answer.Content})
+	if !ok {
+		return
+	}
+	answer.Moderation = data.ModerationNone
+	if flagged {
+		answer.Moderation = data.ModerationReview
+	}
 
-	// IDと時間の設定
-	now := time.Now()
-	answer.ID = generateID()
+	// IDと時間の設定はストアで行うため、ここでは設定しない
 	answer.ThemeID = themeID
-	answer.CreatedAt = now
-	answer.UpdatedAt = now
 	answer.Likes = 0
+	setCreator(r, &answer.CreatedBy, &answer.UserID)
 
 	if err := h.store.CreateAnswer(&answer); err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "回答の投稿に失敗しました")
+		sendServerError(w, r, "回答の投稿に失敗しました", err)
 		return
 	}
 
-	sendJSONResponse(w, http.StatusCreated, answer)
+	setETag(w, answer.Version)
+	response.WriteData(w, r, http.StatusCreated, "回答を投稿しました", answer)
 }
 
 // UpdateAnswer は回答を更新
 func (h *Handler) UpdateAnswer(w http.ResponseWriter, r *http.Request) {
+	var in answerInput
+	h.updateAnswer(w, r, &in)
+}
+
+// PatchAnswer は回答を部分更新（JSON Merge Patch、RFC 7396）
+func (h *Handler) PatchAnswer(w http.ResponseWriter, r *http.Request) {
+	var p answerPatch
+	h.updateAnswer(w, r, &p)
+}
+
+// updateAnswer はリクエストボディを change に読み込み、回答に反映して保存する
+func (h *Handler) updateAnswer(w http.ResponseWriter, r *http.Request, change answerChange) {
 	vars := mux.Vars(r)
 	themeID := vars["themeID"]
 	id := vars["id"]
 
-	var updatedAnswer data.Answer
-	if err := json.NewDecoder(r.Body).Decode(&updatedAnswer); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "無効なリクエスト形式です")
+	errs, ok := decodeBody(w, r, change)
+	if !ok {
 		return
 	}
 
 	// 現在の回答を取得
 	currentAnswer, err := h.store.GetAnswer(id, themeID)
 	if err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "回答が見つかりません")
+		sendError(w, r, errAnswerNotFound)
 		return
 	}
 	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "回答の取得に失敗しました")
+		sendServerError(w, r, "回答の取得に失敗しました", err)
+		return
+	}
+	if !authorize(w, r, auth.PermUpdateAnswer, currentAnswer.UserID) {
+		return
+	}
+	// ストアが返すポインタは共有されている場合があるため、コピーを更新する（検査で拒否した変更を残さない）
+	answer := *currentAnswer
+	currentAnswer = &answer
+	if !checkIfMatch(w, r, currentAnswer.Version, "回答は他の操作で更新されています。取得し直してから再度お試しください") {
 		return
 	}
 
 	// 更新されたフィールドを適用
-	if updatedAnswer.Content != "" {
-		currentAnswer.Content = updatedAnswer.Content
+	change.apply(currentAnswer, &errs)
+	errs.Merge(validation.Answer(currentAnswer))
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
 	}
-	if updatedAnswer.Likes > 0 {
-		currentAnswer.Likes = updatedAnswer.Likes
+	flagged, ok := h.moderate(w, r, moderation.Field{Name: "content", Text: currentAnswer.Content})
+	if !ok {
+		return
+	}
+	if flagged {
+		currentAnswer.Moderation = data.ModerationReview
 	}
 	currentAnswer.UpdatedAt = time.Now()
 
-	if err := h.store.UpdateAnswer(currentAnswer); err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "回答の更新に失敗しました")
+	if err := h.store.UpdateAnswer(currentAnswer); err == data.ErrVersionConflict {
+		sendError(w, r, conflictError(err))
+		return
+	} else if err != nil {
+		sendServerError(w, r, "回答の更新に失敗しました", err)
 		return
 	}
 
-	sendJSONResponse(w, http.StatusOK, currentAnswer)
+	setETag(w, currentAnswer.Version)
+	response.WriteData(w, r, http.StatusOK, "回答を更新しました", currentAnswer)
 }
 
 // DeleteAnswer は回答を削除
@@ -311,13 +659,97 @@ func (h *Handler) DeleteAnswer(w http.ResponseWriter, r *http.Request) {
 	themeID := vars["themeID"]
 	id := vars["id"]
 
-	if err := h.store.DeleteAnswer(id, themeID); err == data.ErrNotFound {
-		sendErrorResponse(w, http.StatusNotFound, "回答が見つかりません")
+	answer, err := h.store.GetAnswer(id, themeID)
+	if err == data.ErrNotFound {
+		sendError(w, r, errAnswerNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "回答の取得に失敗しました", err)
+		return
+	}
+	if !authorize(w, r, auth.PermDeleteAnswer, answer.UserID) {
+		return
+	}
+	if !checkIfMatch(w, r, answer.Version, "回答は他の操作で更新されています。取得し直してから再度お試しください") {
+		return
+	}
+
+	if err := h.store.DeleteAnswer(id, themeID, ifMatchVersion(r, answer.Version)); err == data.ErrNotFound {
+		sendError(w, r, errAnswerNotFound)
+		return
+	} else if err == data.ErrVersionConflict {
+		sendError(w, r, conflictError(err))
 		return
 	} else if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, "回答の削除に失敗しました")
+		sendServerError(w, r, "回答の削除に失敗しました", err)
+		return
+	}
+
+	response.NoContent(w)
+}
+
+// ---------- いいね関連のハンドラー ----------
+
+// LikeAnswer は回答にいいねする（同じ投票者は1回まで）
+func (h *Handler) LikeAnswer(w http.ResponseWriter, r *http.Request) {
+	h.changeLike(w, r, true)
+}
+
+// UnlikeAnswer は回答へのいいねを取り消す
+func (h *Handler) UnlikeAnswer(w http.ResponseWriter, r *http.Request) {
+	h.changeLike(w, r, false)
+}
+
+func (h *Handler) changeLike(w http.ResponseWriter, r *http.Request, like bool) {
+	if !authorize(w, r, auth.PermLikeAnswer, "") {
 		return
 	}
 
-	sendJSONResponse(w, http.StatusNoContent, nil)
+	vars := mux.Vars(r)
+	themeID := vars["themeID"]
+	id := vars["id"]
+
+	voter := voterID(r)
+	if voter == "" {
+		sendError(w, r, errClientTokenRequired)
+		return
+	}
+
+	// 閲覧できない（非表示・確認待ちの）回答にはいいねできない
+	answer, err := h.store.GetAnswer(id, themeID)
+	if err == data.ErrNotFound || (err == nil && !canView(r, answer.Moderation, answer.UserID)) {
+		sendError(w, r, errAnswerNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "いいねの更新に失敗しました", err)
+		return
+	}
+
+	if like {
+		answer, err = h.store.LikeAnswer(id, themeID, voter)
+	} else {
+		answer, err = h.store.UnlikeAnswer(id, themeID, voter)
+	}
+	switch err {
+	case nil:
+	case data.ErrNotFound:
+		sendError(w, r, errAnswerNotFound)
+		return
+	case data.ErrAlreadyLiked, data.ErrNotLiked:
+		sendError(w, r, conflictError(err))
+		return
+	default:
+		sendServerError(w, r, "いいねの更新に失敗しました", err)
+		return
+	}
+
+	answer.LikedByMe = like
+	message := "いいねしました"
+	if !like {
+		message = "いいねを取り消しました"
+	}
+	setETag(w, answer.Version)
+	response.WriteData(w, r, http.StatusOK, message, answer)
 }
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/nicest414/ogiri-server/internal/buildinfo"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// 死活監視・状態確認のエンドポイントはロードバランサーから呼ばれるため、
+// /api/ のルーター（CORS・認証・レート制限）を通さずに登録する
+// 監視ツールが読みやすいよう、レスポンスは共通の形式で包まずにそのまま返す
+
+// Healthz はプロセスが応答できるかを返す（liveness）
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	response.JSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz はリクエストを処理できる状態かを返す（readiness）
+// データストアからお題を読み出せることと、ストアに書き込めること（JSONStore はデータファイル）を確認する
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"store_read": "ok", "store_write": "ok"}
+	ready := true
+	if _, _, err := h.store.ListThemes(data.ListOptions{Limit: 1}); err != nil {
+		checks["store_read"] = err.Error()
+		ready = false
+	}
+	if err := h.store.Ping(); err != nil {
+		checks["store_write"] = err.Error()
+		ready = false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !ready {
+		response.JSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "unavailable", "checks": checks})
+		return
+	}
+	response.JSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "checks": checks})
+}
+
+// Version はビルドのバージョン・コミットと起動時刻を返す
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/buildinfo"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+)
+
+// brokenStore は読み出しに失敗するデータストア
+type brokenStore struct {
+	data.DataStore
+}
+
+func (brokenStore) ListThemes(data.ListOptions) ([]*data.Theme, string, error) {
+	return nil, "", errors.New("読み出せません")
+}
+
+// TestHealth は死活監視・状態確認のエンドポイントが、ストアの状態に応じたステータスを返すことを確認する
+func TestHealth(t *testing.T) {
+	closed, err := data.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closed.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		store      data.DataStore
+		path       string
+		want       int
+		wantChecks map[string]bool // 項目ごとに ok かどうか
+	}{
+		{"healthz はストアを見ない", closed, "/healthz", http.StatusOK, nil},
+		{"readyz", data.NewInMemoryStore(), "/readyz", http.StatusOK, map[string]bool{"store_read": true, "store_write": true}},
+		{"readyz は読み出せなければ失敗", brokenStore{data.NewInMemoryStore()}, "/readyz", http.StatusServiceUnavailable, map[string]bool{"store_read": false, "store_write": true}},
+		{"readyz は閉じたストアに書き込めなければ失敗", closed, "/readyz", http.StatusServiceUnavailable, map[string]bool{"store_read": true, "store_write": false}},
+	}
+	for _, tt := range tests {
+		ops := NewHandler(tt.store, realtime.NewHub(), nil, nil).OpsRoutes(http.NotFoundHandler())
+		rec := httptest.NewRecorder()
+		ops.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+			continue
+		}
+		var body struct {
+			Status string            `json:"status"`
+			Checks map[string]string `json:"checks"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for check, ok := range tt.wantChecks {
+			if (body.Checks[check] == "ok") != ok {
+				t.Errorf("%s: %s = %q", tt.name, check, body.Checks[check])
+			}
+		}
+	}
+}
+
+// TestVersion は埋め込んだビルドの情報を返すことを確認する
+func TestVersion(t *testing.T) {
+	version, commit := buildinfo.Version, buildinfo.Commit
+	defer func() { buildinfo.Version, buildinfo.Commit = version, commit }()
+	buildinfo.Version, buildinfo.Commit = "v1.2.3", "abc123"
+
+	rec := httptest.NewRecorder()
+	NewHandler(data.NewInMemoryStore(), realtime.NewHub(), nil, nil).OpsRoutes(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var info buildinfo.Info
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "v1.2.3" || info.Commit != "abc123" || info.StartedAt.IsZero() || info.GoVersion == "" {
+		t.Errorf("version = %+v", info)
+	}
+}
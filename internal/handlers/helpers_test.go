@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// testServer はテスト用にメモリ内のストアでAPIを組み立てたもの
+type testServer struct {
+	t       *testing.T
+	store   data.DataStore
+	hub     *realtime.Hub
+	handler http.Handler
+}
+
+// newTestServer はメモリ内のストアを使い、認証のミドルウェアを通したAPIを返す
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWith(t, data.NewInMemoryStore())
+}
+
+// newTestServerWith は store を使ってAPIを組み立てる
+// store はハブへイベントを流すように包むので、直接の変更も配信される
+func newTestServerWith(t *testing.T, store data.DataStore) *testServer {
+	t.Helper()
+	hub := realtime.NewHub()
+	published := realtime.NewPublishingStore(store, hub)
+	api := NewHandler(published, hub, nil, nil).Routes()
+	Use(api, auth.Middleware(published))
+	return &testServer{t: t, store: published, hub: hub, handler: api}
+}
+
+// do はリクエストを送ってレスポンスを返す
+// headers には "名前", "値" の順にヘッダーを並べる
+// レスポンスは共通の形式で受け取る（以前の形式を確かめるときは X-Response-Format: legacy を指定する）
+func (s *testServer) do(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(response.FormatHeader, string(response.FormatEnvelope))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// createTheme は受付中のお題をストアに直接作成する
+func (s *testServer) createTheme(title string) *data.Theme {
+	s.t.Helper()
+	theme := &data.Theme{Title: title, Active: true}
+	if err := s.store.CreateTheme(theme); err != nil {
+		s.t.Fatalf("お題を作成できません: %v", err)
+	}
+	return theme
+}
+
+// createAnswer は回答をストアに直接作成する（IDと作成日時はストアが振る）
+func (s *testServer) createAnswer(themeID, content string) *data.Answer {
+	s.t.Helper()
+	answer := &data.Answer{ThemeID: themeID, Content: content}
+	if err := s.store.CreateAnswer(answer); err != nil {
+		s.t.Fatalf("回答を作成できません: %v", err)
+	}
+	return answer
+}
+
+// testEnvelope はテストで読み取る一覧のレスポンス
+type testEnvelope struct {
+	Success    bool            `json:"success"`
+	Data       json.RawMessage `json:"data"`
+	NextCursor *string         `json:"next_cursor"`
+	Error      *response.Error `json:"error"`
+}
+
+// decodeEnvelope はレスポンスを読み込み、data を v に読み込む（v が nil なら読み込まない）
+func decodeEnvelope(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) testEnvelope {
+	t.Helper()
+	var env testEnvelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("レスポンスを読み込めません: %v\n%s", err, rec.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(env.Data, v); err != nil {
+			t.Fatalf("data を読み込めません: %v\n%s", err, rec.Body.String())
+		}
+	}
+	return env
+}
+
+// login は role のユーザーを作成してセッションを開始し、Authorization ヘッダーの値を返す
+func (s *testServer) login(username string, role data.Role) string {
+	s.t.Helper()
+	user := &data.User{Username: username, PasswordHash: "-", Role: role}
+	if err := s.store.CreateUser(user); err != nil {
+		s.t.Fatalf("ユーザーを作成できません: %v", err)
+	}
+	token, session, err := auth.NewSession(user.ID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if err := s.store.CreateSession(session); err != nil {
+		s.t.Fatalf("セッションを作成できません: %v", err)
+	}
+	return "Bearer " + token
+}
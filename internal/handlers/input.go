@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"github.com/nicest414/ogiri-server/internal/validation"
+)
+
+// maxBodyBytes はJSONのリクエストボディの最大バイト数（インポートするファイルは除く）
+const maxBodyBytes = 64 << 10
+
+// themeInput はお題の作成・更新で受け付ける項目
+type themeInput struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Active      *bool      `json:"active"` // 省略したかどうかを区別するため、ポインタで受け取る
+	OpensAt     *time.Time `json:"opens_at"`
+	ClosesAt    *time.Time `json:"closes_at"`
+	CreatedBy   string     `json:"created_by"` // 以前のクライアントが送るため受け付けるが、使わない（setCreator を参照）
+}
+
+// theme は入力からお題を組み立てる（active を省略した場合は false）
+func (in *themeInput) theme() data.Theme {
+	return data.Theme{
+		Title:       in.Title,
+		Description: in.Description,
+		Active:      in.Active != nil && *in.Active,
+		OpensAt:     in.OpensAt,
+		ClosesAt:    in.ClosesAt,
+	}
+}
+
+// themeChange はお題の更新（PUT・PATCH）のリクエストボディ
+type themeChange interface {
+	// apply は変更をお題に反映する。値として受け付けられない項目は errs に追加する
+	apply(theme *data.Theme, errs *validation.Errors)
+}
+
+// apply は PUT の変更を反映する
+// 空の項目と省略した日時は変更せず、active は常に指定した値（省略すると false）にする
+func (in *themeInput) apply(theme *data.Theme, errs *validation.Errors) {
+	if in.Title != "" {
+		theme.Title = in.Title
+	}
+	if in.Description != "" {
+		theme.Description = in.Description
+	}
+	theme.Active = in.Active != nil && *in.Active
+	if in.OpensAt != nil {
+		theme.OpensAt = in.OpensAt
+	}
+	if in.ClosesAt != nil {
+		theme.ClosesAt = in.ClosesAt
+	}
+}
+
+// themePatch はお題の部分更新（JSON Merge Patch）で受け付ける項目
+type themePatch struct {
+	Title       validation.Optional[string]    `json:"title"`
+	Description validation.Optional[string]    `json:"description"`
+	Active      validation.Optional[bool]      `json:"active"`
+	OpensAt     validation.Optional[time.Time] `json:"opens_at"`
+	ClosesAt    validation.Optional[time.Time] `json:"closes_at"`
+	CreatedBy   validation.Optional[string]    `json:"created_by"` // themeInput と同じく受け付けるが、使わない
+}
+
+// apply は RFC 7396 に従って変更を反映する
+// 省略した項目は変更せず、null の項目は消し（title を消すと必須の検査で拒否される）、false も値として反映する
+func (p *themePatch) apply(theme *data.Theme, errs *validation.Errors) {
+	if p.Title.Set {
+		theme.Title = p.Title.Value
+	}
+	if p.Description.Set {
+		theme.Description = p.Description.Value
+	}
+	if p.Active.Set {
+		if p.Active.Null {
+			errs.Add("active", response.CodeInvalidValue, "active は null にできません。true または false を指定してください")
+		} else {
+			theme.Active = p.Active.Value
+		}
+	}
+	if p.OpensAt.Set {
+		theme.OpensAt = p.OpensAt.Ptr()
+	}
+	if p.ClosesAt.Set {
+		theme.ClosesAt = p.ClosesAt.Ptr()
+	}
+}
+
+// answerInput は回答の投稿・更新で受け付ける項目
+type answerInput struct {
+	Content   string `json:"content"`
+	CreatedBy string `json:"created_by"` // 以前のクライアントが送るため受け付けるが、使わない（setCreator を参照）
+}
+
+// answerChange は回答の更新（PUT・PATCH）のリクエストボディ
+type answerChange interface {
+	// apply は変更を回答に反映する。値として受け付けられない項目は errs に追加する
+	apply(answer *data.Answer, errs *validation.Errors)
+}
+
+// apply は PUT の変更を反映する（空の項目は変更しない）
+// いいね数は /likes エンドポイントでのみ変更できる
+func (in *answerInput) apply(answer *data.Answer, errs *validation.Errors) {
+	if in.Content != "" {
+		answer.Content = in.Content
+	}
+}
+
+// answerPatch は回答の部分更新（JSON Merge Patch）で受け付ける項目
+type answerPatch struct {
+	Content   validation.Optional[string] `json:"content"`
+	CreatedBy validation.Optional[string] `json:"created_by"` // answerInput と同じく受け付けるが、使わない
+}
+
+// apply は RFC 7396 に従って変更を反映する（content を null にすると必須の検査で拒否される）
+func (p *answerPatch) apply(answer *data.Answer, errs *validation.Errors) {
+	if p.Content.Set {
+		answer.Content = p.Content.Value
+	}
+}
+
+// decodeBody はリクエストボディを v に読み込む（JSONのボディは全てこれで読み込む）
+// ボディは maxBodyBytes までに制限し、受け付けていない項目や型の合わない項目は errs に入れて返すので、
+// 呼び出し元で値の検査結果とまとめて返す
+// ボディが大きすぎる・JSONとして読めない場合はエラーレスポンスを送って ok = false を返す
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) (errs validation.Errors, ok bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		sendError(w, r, errBodyTooLarge)
+		return nil, false
+	}
+	if err != nil {
+		sendError(w, r, errInvalidJSON)
+		return nil, false
+	}
+
+	errs, err = validation.Decode(body, v)
+	if err != nil {
+		sendError(w, r, errInvalidJSON)
+		return nil, false
+	}
+	return errs, true
+}
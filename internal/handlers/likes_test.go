@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// TestLikeAnswer はいいね・取り消しが投票者ごとに1回だけ効くことを確認する
+func TestLikeAnswer(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	answer := s.createAnswer(theme.ID, "回答")
+	path := "/api/themes/" + theme.ID + "/answers/" + answer.ID + "/likes"
+
+	steps := []struct {
+		name      string
+		method    string
+		path      string
+		headers   []string
+		wantCode  int
+		wantLikes int
+	}{
+		{"投票者を識別できない", http.MethodPost, path, nil, http.StatusBadRequest, 0},
+		{"匿名でいいね", http.MethodPost, path, []string{"X-Client-Token", "t1"}, http.StatusOK, 1},
+		{"同じトークンでもう一度", http.MethodPost, path, []string{"X-Client-Token", "t1"}, http.StatusConflict, 1},
+		{"別のトークン", http.MethodPost, path, []string{"X-Client-Token", "t2"}, http.StatusOK, 2},
+		{"取り消し", http.MethodDelete, path, []string{"X-Client-Token", "t1"}, http.StatusOK, 1},
+		{"もう一度取り消し", http.MethodDelete, path, []string{"X-Client-Token", "t1"}, http.StatusConflict, 1},
+		{"取り消した後にもう一度いいね", http.MethodPost, path, []string{"X-Client-Token", "t1"}, http.StatusOK, 2},
+		{"存在しない回答", http.MethodPost, "/api/themes/" + theme.ID + "/answers/none/likes", []string{"X-Client-Token", "t3"}, http.StatusNotFound, 2},
+		{"別のお題の回答", http.MethodPost, "/api/themes/other/answers/" + answer.ID + "/likes", []string{"X-Client-Token", "t3"}, http.StatusNotFound, 2},
+	}
+	for _, step := range steps {
+		rec := s.do(step.method, step.path, "", step.headers...)
+		if rec.Code != step.wantCode {
+			t.Fatalf("%s: status = %d, want %d\n%s", step.name, rec.Code, step.wantCode, rec.Body.String())
+		}
+		stored, err := s.store.GetAnswer(answer.ID, theme.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Likes != step.wantLikes {
+			t.Errorf("%s: likes = %d, want %d", step.name, stored.Likes, step.wantLikes)
+		}
+	}
+}
+
+// TestLikeResponse はいいねのレスポンスと一覧に liked_by_me が入り、ストアの回答には残らないことを確認する
+func TestLikeResponse(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	created := s.createAnswer(theme.ID, "回答")
+
+	rec := s.do(http.MethodPost, "/api/themes/"+theme.ID+"/answers/"+created.ID+"/likes", "", "X-Client-Token", "t1")
+	var answer data.Answer
+	decodeEnvelope(t, rec, &answer)
+	if !answer.LikedByMe || answer.Likes != 1 {
+		t.Errorf("answer = {liked_by_me: %v, likes: %d}, want {true, 1}", answer.LikedByMe, answer.Likes)
+	}
+
+	tests := []struct {
+		name    string
+		headers []string
+		want    bool
+	}{
+		{"いいねした投票者", []string{"X-Client-Token", "t1"}, true},
+		{"別の投票者", []string{"X-Client-Token", "t2"}, false},
+		{"識別できない", nil, false},
+	}
+	for _, tt := range tests {
+		var answers []data.Answer
+		rec := s.do(http.MethodGet, "/api/themes/"+theme.ID+"/answers", "", tt.headers...)
+		if decodeEnvelope(t, rec, &answers); len(answers) != 1 {
+			t.Fatalf("%s: 一覧の件数 = %d\n%s", tt.name, len(answers), rec.Body.String())
+		}
+		if answers[0].LikedByMe != tt.want {
+			t.Errorf("%s: liked_by_me = %v, want %v", tt.name, answers[0].LikedByMe, tt.want)
+		}
+	}
+
+	// レスポンスの liked_by_me がストアの回答に残らないこと
+	stored, _ := s.store.GetAnswer(created.ID, theme.ID)
+	if stored.LikedByMe {
+		t.Error("ストアの回答の liked_by_me が変更されています")
+	}
+}
+
+// TestConcurrentLikes はいいねと一覧の取得を並行に行っても競合せず、いいね数が合うことを確認する
+// go test -race で実行すると、ストアが共有している回答を書き換えていないかも確認できる
+func TestConcurrentLikes(t *testing.T) {
+	stores := map[string]func(t *testing.T) data.DataStore{
+		"memory": func(t *testing.T) data.DataStore { return data.NewInMemoryStore() },
+		"json": func(t *testing.T) data.DataStore {
+			store, err := data.NewJSONStore(filepath.Join(t.TempDir(), "data.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServerWith(t, newStore(t))
+			theme := s.createTheme("お題")
+			answer := s.createAnswer(theme.ID, "回答")
+			likes := "/api/themes/" + theme.ID + "/answers/" + answer.ID + "/likes"
+
+			const voters = 20
+			var wg sync.WaitGroup
+			for i := 0; i < voters; i++ {
+				wg.Add(2)
+				go func(i int) {
+					defer wg.Done()
+					token := fmt.Sprintf("voter-%d", i)
+					s.do(http.MethodPost, likes, "", "X-Client-Token", token)
+					if i%2 == 0 {
+						s.do(http.MethodDelete, likes, "", "X-Client-Token", token)
+						s.do(http.MethodPost, likes, "", "X-Client-Token", token)
+					}
+				}(i)
+				go func() {
+					defer wg.Done()
+					s.do(http.MethodGet, "/api/themes/"+theme.ID+"/answers", "")
+				}()
+			}
+			wg.Wait()
+
+			stored, err := s.store.GetAnswer(answer.ID, theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Likes != voters {
+				t.Errorf("likes = %d, want %d", stored.Likes, voters)
+			}
+		})
+	}
+}
+
+// TestLikeHiddenAnswer は閲覧できない回答には、いいねも取り消しもできないことを確認する
+func TestLikeHiddenAnswer(t *testing.T) {
+	tests := []struct {
+		name   string
+		state  data.ModerationState
+		role   data.Role // 空なら匿名
+		method string
+		want   int
+	}{
+		{"非表示の回答に匿名でいいね", data.ModerationHidden, "", http.MethodPost, http.StatusNotFound},
+		{"非表示の回答のいいねを取り消し", data.ModerationHidden, "", http.MethodDelete, http.StatusNotFound},
+		{"確認待ちの回答にplayerがいいね", data.ModerationReview, data.RolePlayer, http.MethodPost, http.StatusNotFound},
+		{"非表示の回答にmoderatorがいいね", data.ModerationHidden, data.RoleModerator, http.MethodPost, http.StatusOK},
+		{"公開中の回答に匿名でいいね", data.ModerationNone, "", http.MethodPost, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			theme := s.createTheme("お題")
+			answer := &data.Answer{ThemeID: theme.ID, Content: "回答", Moderation: tt.state}
+			if err := s.store.CreateAnswer(answer); err != nil {
+				t.Fatal(err)
+			}
+			headers := []string{"X-Client-Token", "t1"}
+			if tt.role != "" {
+				headers = []string{"Authorization", s.login("user", tt.role)}
+			}
+
+			rec := s.do(tt.method, "/api/themes/"+theme.ID+"/answers/"+answer.ID+"/likes", "", headers...)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			stored, err := s.store.GetAnswer(answer.ID, theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == http.StatusNotFound && stored.Likes != 0 {
+				t.Errorf("likes = %d, want 0", stored.Likes)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// answerIDs は回答の一覧からIDを取り出す
+func answerIDs(answers []data.Answer) []string {
+	ids := make([]string, len(answers))
+	for i, answer := range answers {
+		ids[i] = answer.ID
+	}
+	return ids
+}
+
+// TestListAnswersPaging はカーソルで続きを取得すると、全ての回答を重複なく順に取得できることを確認する
+func TestListAnswersPaging(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	var want []string
+	for i := 1; i <= 25; i++ {
+		want = append(want, s.createAnswer(theme.ID, "回答").ID)
+	}
+
+	tests := []struct {
+		name     string
+		first    string // 1ページ目のクエリ
+		next     string // 2ページ目以降のクエリ（cursor を加えて送る）
+		want     []string
+		pageSize int
+	}{
+		{"昇順", "limit=7", "limit=7", want, 7},
+		{"降順", "limit=10&order=desc", "limit=10&order=desc", reverse(want), 10},
+		{"cursor だけなら20件ずつ", "limit=20", "", want, defaultListLimit},
+	}
+	for _, tt := range tests {
+		var got []string
+		query := tt.first
+		for {
+			rec := s.do(http.MethodGet, "/api/themes/"+theme.ID+"/answers?"+query, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s: status = %d\n%s", tt.name, rec.Code, rec.Body.String())
+			}
+			var answers []data.Answer
+			env := decodeEnvelope(t, rec, &answers)
+			if env.NextCursor == nil {
+				t.Fatalf("%s: next_cursor がありません", tt.name)
+			}
+			if len(answers) > tt.pageSize {
+				t.Errorf("%s: %d件, want %d件以下", tt.name, len(answers), tt.pageSize)
+			}
+			got = append(got, answerIDs(answers)...)
+			if *env.NextCursor == "" {
+				break
+			}
+			q, _ := url.ParseQuery(tt.next)
+			q.Set("cursor", *env.NextCursor)
+			query = q.Encode()
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func reverse(s []string) []string {
+	r := make([]string, len(s))
+	for i, v := range s {
+		r[len(s)-1-i] = v
+	}
+	return r
+}
+
+// TestListAnswersUnpaged は limit も cursor も指定しなければ、以前の形式では全件を配列のまま返すことを確認する
+func TestListAnswersUnpaged(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	for i := 1; i <= defaultListLimit+5; i++ {
+		s.createAnswer(theme.ID, "回答")
+	}
+
+	rec := s.do(http.MethodGet, "/api/themes/"+theme.ID+"/answers", "", response.FormatHeader, string(response.FormatLegacy))
+	var answers []data.Answer
+	if err := json.Unmarshal(rec.Body.Bytes(), &answers); err != nil {
+		t.Fatalf("配列として読めません: %v\n%s", err, rec.Body.String())
+	}
+	if len(answers) != defaultListLimit+5 {
+		t.Errorf("%d件, want %d件", len(answers), defaultListLimit+5)
+	}
+}
+
+// TestListThemesUnpaged は limit も cursor も指定しなければ、お題も全件を返すことを確認する
+func TestListThemesUnpaged(t *testing.T) {
+	s := newTestServer(t)
+	for i := 1; i <= defaultListLimit+5; i++ {
+		s.createTheme(fmt.Sprintf("お題%d", i))
+	}
+
+	var themes []data.Theme
+	env := decodeEnvelope(t, s.do(http.MethodGet, "/api/themes", ""), &themes)
+	if len(themes) != defaultListLimit+5 {
+		t.Errorf("%d件, want %d件", len(themes), defaultListLimit+5)
+	}
+	if env.NextCursor == nil || *env.NextCursor != "" {
+		t.Errorf("next_cursor = %v, want 空文字列", env.NextCursor)
+	}
+}
+
+// TestListSortAndFilter は並び替えと絞り込みの条件を確認する
+func TestListSortAndFilter(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	var ids []string
+	for _, likes := range []int{2, 0, 3} {
+		answer := s.createAnswer(theme.ID, "回答")
+		for v := 0; v < likes; v++ {
+			if _, err := s.store.LikeAnswer(answer.ID, theme.ID, fmt.Sprintf("anon:%d", v)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids = append(ids, answer.ID)
+	}
+	closed := s.createTheme("締め切ったお題")
+	closed.Active = false
+	if err := s.store.UpdateTheme(closed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"作成日時の昇順", "/api/themes/" + theme.ID + "/answers?limit=10", ids},
+		{"いいねの多い順", "/api/themes/" + theme.ID + "/answers?sort=likes&order=desc&limit=10", []string{ids[2], ids[0], ids[1]}},
+		{"いいねの少ない順で2件", "/api/themes/" + theme.ID + "/answers?sort=likes&limit=2", []string{ids[1], ids[0]}},
+		{"受付中のお題", "/api/themes?active=true", []string{theme.ID}},
+		{"締め切ったお題", "/api/themes?active=false", []string{closed.ID}},
+	}
+	for _, tt := range tests {
+		var items []struct {
+			ID string `json:"id"`
+		}
+		decodeEnvelope(t, s.do(http.MethodGet, tt.path, ""), &items)
+		got := make([]string, len(items))
+		for i, item := range items {
+			got[i] = item.ID
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestListQueryErrors は不正なクエリパラメータが400エラーになることを確認する
+func TestListQueryErrors(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+
+	tests := []struct {
+		name string
+		path string
+		code response.Code
+	}{
+		{"limit が0", "/api/themes?limit=0", response.CodeInvalidQuery},
+		{"limit が上限を超える", "/api/themes?limit=101", response.CodeInvalidQuery},
+		{"limit が数値でない", "/api/themes/" + theme.ID + "/answers?limit=abc", response.CodeInvalidQuery},
+		{"お題は likes で並び替えできない", "/api/themes?sort=likes", response.CodeInvalidQuery},
+		{"order が不正", "/api/themes?order=up", response.CodeInvalidQuery},
+		{"日時の形式が不正", "/api/themes?created_after=yesterday", response.CodeInvalidQuery},
+		{"active が不正", "/api/themes?active=maybe", response.CodeInvalidQuery},
+		{"cursor が不正", "/api/themes/" + theme.ID + "/answers?cursor=%21%21", response.CodeInvalidCursor},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodGet, tt.path, "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.name, rec.Code)
+			continue
+		}
+		if env := decodeEnvelope(t, rec, nil); env.Error == nil || env.Error.Code != tt.code || env.Error.Message == "" || len(env.Error.Details) == 0 {
+			t.Errorf("%s: error = %+v, want code %s", tt.name, env.Error, tt.code)
+		}
+	}
+}
+
+// TestListLegacyFormat は互換モードの一覧が以前の形で返り、続きのページをヘッダーで知らせることを確認する
+func TestListLegacyFormat(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	s.createTheme("お題2")
+	for i := 1; i <= 3; i++ {
+		s.createAnswer(theme.ID, fmt.Sprintf("回答%d", i))
+	}
+	admin := s.login("admin", data.RoleAdmin)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantBare bool // 配列をそのまま返す（false なら共通の形式）
+		wantNext bool
+	}{
+		{"回答・ページング", "/api/themes/" + theme.ID + "/answers?limit=2", true, true},
+		{"回答・最後のページ", "/api/themes/" + theme.ID + "/answers?limit=5", true, false},
+		{"投稿されたお題", "/api/admin/themes?limit=1&submission_status=all", true, true},
+		{"お題は以前から共通の形式", "/api/themes?limit=1", false, true},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodGet, tt.path, "", "X-Response-Format", "legacy", "Authorization", admin)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d\n%s", tt.name, rec.Code, rec.Body.String())
+		}
+		var items []json.RawMessage
+		isBare := json.Unmarshal(rec.Body.Bytes(), &items) == nil
+		if isBare != tt.wantBare {
+			t.Errorf("%s: 配列のまま = %v, want %v\n%s", tt.name, isBare, tt.wantBare, rec.Body.String())
+		}
+		next := rec.Header().Get("X-Next-Cursor")
+		if (next != "") != tt.wantNext {
+			t.Errorf("%s: X-Next-Cursor = %q, want 続きあり = %v", tt.name, next, tt.wantNext)
+		}
+		if tt.wantNext && rec.Header().Get("Link") == "" {
+			t.Errorf("%s: Link ヘッダーがありません", tt.name)
+		}
+	}
+}
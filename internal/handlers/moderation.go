@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/moderation"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// moderate は入力にNGワードが含まれていないか検査する
+// 拒否する設定なら422エラーを返して ok = false を返す
+// 確認待ちにする設定なら flagged = true を返すので、呼び出し元で一般のユーザーに表示しない状態にする
+func (h *Handler) moderate(w http.ResponseWriter, r *http.Request, fields ...moderation.Field) (flagged bool, ok bool) {
+	violations := h.filter.Check(fields...)
+	if len(violations) == 0 {
+		return false, true
+	}
+	if h.filter.Action() == moderation.ActionReview {
+		return true, true
+	}
+
+	e := response.NewError(http.StatusUnprocessableEntity, response.CodeInappropriate, "不適切な表現が含まれています")
+	for _, v := range violations {
+		e.Details = append(e.Details, response.Detail{
+			Field:   v.Field,
+			Code:    response.CodeNGWord,
+			Message: fmt.Sprintf("%s に使えない言葉が含まれています: %s", v.Field, strings.Join(v.Words, ", ")),
+		})
+	}
+	// 互換モードでは以前と同じく violations で返す
+	e.Legacy = map[string]interface{}{"violations": violations}
+	sendError(w, r, e)
+	return false, false
+}
+
+// parseModeration は一覧取得の moderation クエリを読み取る
+// 確認待ちのものを閲覧できるのは moderator と admin のみ
+func parseModeration(w http.ResponseWriter, r *http.Request, opts *data.ListOptions) bool {
+	v := r.URL.Query().Get("moderation")
+	if v == "" {
+		return true
+	}
+	if !authorize(w, r, auth.PermReviewContent, "") {
+		return false
+	}
+	if v == "all" {
+		opts.Moderation = data.AllModerationStates
+		return true
+	}
+
+	for _, s := range strings.Split(v, ",") {
+		state := data.ModerationState(s)
+		if s == "public" {
+			state = data.ModerationNone
+		}
+		if !state.Valid() {
+			sendError(w, r, queryError("moderation", "moderation には public, review, hidden, all のいずれかを指定してください"))
+			return false
+		}
+		opts.Moderation = append(opts.Moderation, state)
+	}
+	return true
+}
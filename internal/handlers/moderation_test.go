@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/moderation"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// newModeratedServer はNGワードを action で扱うAPIを組み立てる
+func newModeratedServer(t *testing.T, action moderation.Action, words ...string) *testServer {
+	t.Helper()
+	filter, err := moderation.New(moderation.Config{Words: words, Action: action})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := realtime.NewHub()
+	store := realtime.NewPublishingStore(data.NewInMemoryStore(), hub)
+	api := NewHandler(store, hub, filter, nil).Routes()
+	Use(api, auth.Middleware(store))
+	return &testServer{t: t, store: store, hub: hub, handler: api}
+}
+
+// TestModerateAnswer はNGワードを含む回答を、設定に応じて拒否するか確認待ちにすることを確認する
+func TestModerateAnswer(t *testing.T) {
+	tests := []struct {
+		name           string
+		action         moderation.Action
+		content        string
+		want           int
+		wantModeration data.ModerationState
+	}{
+		{"含まない", moderation.ActionReject, "おもしろい回答", http.StatusCreated, data.ModerationNone},
+		{"拒否", moderation.ActionReject, "ﾊﾞ ｶ", http.StatusUnprocessableEntity, ""},
+		{"確認待ち", moderation.ActionReview, "バーカ", http.StatusCreated, data.ModerationReview},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newModeratedServer(t, tt.action, "ばか")
+			theme := s.createTheme("お題")
+			rec := s.do(http.MethodPost, "/api/themes/"+theme.ID+"/answers", `{"content":"`+tt.content+`"}`, clientTokenHeader, "client-1")
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			if rec.Code != http.StatusCreated {
+				env := decodeEnvelope(t, rec, nil)
+				if env.Error == nil || env.Error.Code != response.CodeInappropriate || len(env.Error.Details) != 1 ||
+					env.Error.Details[0].Field != "content" || env.Error.Details[0].Code != response.CodeNGWord {
+					t.Errorf("error = %+v", env.Error)
+				}
+				return
+			}
+			var answer data.Answer
+			decodeEnvelope(t, rec, &answer)
+			if answer.Moderation != tt.wantModeration {
+				t.Errorf("moderation = %q, want %q", answer.Moderation, tt.wantModeration)
+			}
+
+			// 確認待ちの回答は一般のユーザーの一覧に出さない
+			var list []data.Answer
+			decodeEnvelope(t, s.do(http.MethodGet, "/api/themes/"+theme.ID+"/answers", ""), &list)
+			if visible := len(list) == 1; visible != (tt.wantModeration == data.ModerationNone) {
+				t.Errorf("一覧の件数 = %d", len(list))
+			}
+
+			// moderator は確認待ちの回答を絞り込んで閲覧できる
+			if tt.wantModeration == data.ModerationReview {
+				rec = s.do(http.MethodGet, "/api/themes/"+theme.ID+"/answers?moderation=review", "", "Authorization", s.login("mod", data.RoleModerator))
+				decodeEnvelope(t, rec, &list)
+				if len(list) != 1 {
+					t.Errorf("確認待ちの一覧の件数 = %d, want 1", len(list))
+				}
+			}
+		})
+	}
+}
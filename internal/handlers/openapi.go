@@ -0,0 +1,1012 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/buildinfo"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/logging"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"github.com/nicest414/ogiri-server/internal/transfer"
+	"github.com/nicest414/ogiri-server/internal/validation"
+)
+
+// schema はOpenAPIのスキーマなどのオブジェクト（JSONにそのまま書き出す）
+type schema = map[string]interface{}
+
+// access はエンドポイントの認証の扱い
+type access int
+
+const (
+	accessOptional access = iota // 匿名でも使える（ログインしていれば権限や結果が変わる）
+	accessRequired               // ログインが必要
+	accessNone                   // 認証を使わない（死活監視）
+)
+
+// operation はOpenAPIに載せる1つのエンドポイント
+type operation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Access      access
+	Params      []string // components/parameters の名前（パスのパラメータはパスから作る）
+	Body        schema   // リクエストボディ（無ければ nil）
+	BodyType    string   // リクエストボディの形式（空なら application/json）
+	Status      int      // 成功時のステータスコード
+	Response    schema   // 成功時のレスポンスボディ（本文が無ければ nil）
+	ContentType string   // 成功時のレスポンスの形式（空なら application/json）
+	ETag        bool     // 成功時のレスポンスに ETag ヘッダー（お題・回答のバージョン）を付ける
+	Paged       bool     // 成功時のレスポンスに続きのページのヘッダー（X-Next-Cursor・Link）を付ける
+	Errors      []int    // 返し得るエラーのステータスコード（500は全てのエンドポイントに付ける）
+}
+
+// ref はコンポーネントのスキーマへの参照を返す
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+// arrayOf は配列のスキーマを返す
+func arrayOf(item schema) schema {
+	return schema{"type": "array", "items": item}
+}
+
+// envelope は {"success", "message", "data"} 形式のレスポンスのスキーマを返す
+func envelope(data schema) schema {
+	properties := schema{
+		"success": schema{"type": "boolean"},
+		"message": schema{"type": "string"},
+	}
+	required := []string{"success", "message"}
+	if data != nil {
+		properties["data"] = data
+		required = append(required, "data")
+	}
+	return schema{"type": "object", "required": required, "properties": properties}
+}
+
+// page はページングする一覧のレスポンスのスキーマを返す
+func page(item schema) schema {
+	s := envelope(arrayOf(item))
+	s["properties"].(schema)["next_cursor"] = schema{
+		"type":        "string",
+		"description": "続きを取得するときに cursor に指定する値（最後のページなら空文字列）",
+	}
+	s["required"] = append(s["required"].([]string), "next_cursor")
+	return s
+}
+
+// 一覧取得で共通のクエリパラメータ
+var listParams = []string{"limit", "cursor", "order", "created_by", "created_before", "created_after"}
+
+// params は一覧取得で共通のクエリパラメータに extra を加える
+func params(extra ...string) []string {
+	return append(append([]string{}, listParams...), extra...)
+}
+
+// operations はAPIの全てのエンドポイント
+// ルートを追加・変更したらここも更新すること（Routes との差分はテストで検出する）
+var operations = []operation{
+	// ユーザー関連
+	{
+		Method: "POST", Path: "/api/auth/signup", Tag: "users",
+		Summary:     "ユーザー登録",
+		Description: "登録後そのままログインし、セッションのクッキーを設定する",
+		Body:        ref("Credentials"),
+		Status:      http.StatusCreated, Response: envelope(ref("Session")),
+		Errors: []int{400, 409, 413},
+	},
+	{
+		Method: "POST", Path: "/api/auth/login", Tag: "users",
+		Summary: "ログイン",
+		Body:    ref("Credentials"),
+		Status:  http.StatusOK, Response: envelope(ref("Session")),
+		Errors: []int{400, 401, 413},
+	},
+	{
+		Method: "POST", Path: "/api/auth/logout", Tag: "users",
+		Summary: "ログアウト",
+		Status:  http.StatusNoContent,
+	},
+	{
+		Method: "GET", Path: "/api/auth/me", Tag: "users",
+		Summary: "ログイン中のユーザー情報を取得",
+		Access:  accessRequired,
+		Status:  http.StatusOK, Response: envelope(ref("User")),
+		Errors: []int{401, 429},
+	},
+	{
+		Method: "PUT", Path: "/api/users/{id}/role", Tag: "users",
+		Summary: "ユーザーの役割を変更（admin）",
+		Access:  accessRequired,
+		Body:    ref("RoleInput"),
+		Status:  http.StatusOK, Response: envelope(ref("User")),
+		Errors: []int{400, 401, 403, 404, 409, 413},
+	},
+
+	// お題関連
+	{
+		Method: "GET", Path: "/api/themes", Tag: "themes",
+		Summary: "承認済みのお題の一覧を取得",
+		Params:  params("theme_sort", "active", "status"),
+		Status:  http.StatusOK, Response: page(ref("Theme")), Paged: true,
+		Errors: []int{400, 429},
+	},
+	{
+		Method: "POST", Path: "/api/themes", Tag: "themes",
+		Summary:     "お題を作成",
+		Description: "admin が作成したお題はそのまま公開され、それ以外は審査待ち（pending）になる",
+		Body:        ref("ThemeInput"),
+		Status:      http.StatusCreated, Response: envelope(ref("Theme")), ETag: true,
+		Errors: []int{400, 401, 403, 413, 422, 429},
+	},
+	{
+		Method: "GET", Path: "/api/themes/{id}", Tag: "themes",
+		Summary:     "お題を取得",
+		Description: "審査待ち・却下のお題は投稿者本人と admin だけが取得できる",
+		Params:      []string{"If-None-Match"},
+		Status:      http.StatusOK, Response: envelope(ref("Theme")), ETag: true,
+		Errors: []int{404, 429},
+	},
+	{
+		Method: "PUT", Path: "/api/themes/{id}", Tag: "themes",
+		Summary:     "お題を更新（admin）",
+		Description: "省略した（空の）項目は変更しない。active は常に指定した値になる。項目を消す・一部だけ変更するには PATCH を使う",
+		Access:      accessRequired,
+		Params:      []string{"If-Match"},
+		Body:        ref("ThemeUpdate"),
+		Status:      http.StatusOK, Response: envelope(ref("Theme")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409, 412, 413, 422},
+	},
+	{
+		Method: "PATCH", Path: "/api/themes/{id}", Tag: "themes",
+		Summary:     "お題を部分更新（admin）",
+		Description: "JSON Merge Patch（RFC 7396）。省略した項目は変更せず、null の項目は消し、false も反映する。Content-Type は application/json でもよい",
+		Access:      accessRequired,
+		Params:      []string{"If-Match"},
+		Body:        ref("ThemePatch"),
+		BodyType:    mergePatchType,
+		Status:      http.StatusOK, Response: envelope(ref("Theme")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409, 412, 413, 422},
+	},
+	{
+		Method: "DELETE", Path: "/api/themes/{id}", Tag: "themes",
+		Summary: "お題と回答を削除（admin）",
+		Access:  accessRequired,
+		Params:  []string{"If-Match"},
+		Status:  http.StatusNoContent,
+		Errors:  []int{401, 403, 404, 409, 412},
+	},
+
+	// お題の審査
+	{
+		Method: "GET", Path: "/api/admin/themes", Tag: "submissions",
+		Summary: "投稿されたお題を審査状態で絞り込んで取得（admin）",
+		Access:  accessRequired,
+		Params:  params("theme_sort", "submission_status"),
+		Status:  http.StatusOK, Response: page(ref("Theme")), Paged: true,
+		Errors: []int{400, 401, 403, 429},
+	},
+	{
+		Method: "POST", Path: "/api/admin/themes/{id}/approve", Tag: "submissions",
+		Summary: "お題を承認して公開（admin）",
+		Access:  accessRequired,
+		Status:  http.StatusOK, Response: envelope(ref("Theme")), ETag: true,
+		Errors: []int{401, 403, 404, 409},
+	},
+	{
+		Method: "POST", Path: "/api/admin/themes/{id}/reject", Tag: "submissions",
+		Summary: "お題を却下（admin）",
+		Access:  accessRequired,
+		Body:    ref("RejectInput"),
+		Status:  http.StatusOK, Response: envelope(ref("Theme")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409, 413},
+	},
+
+	// 回答関連
+	{
+		Method: "GET", Path: "/api/themes/{themeID}/answers", Tag: "answers",
+		Summary:     "お題の回答の一覧を取得",
+		Description: "X-Client-Token を送ると liked_by_me にいいね済みかどうかが入る",
+		Params:      params("answer_sort", "moderation", "X-Client-Token"),
+		Status:      http.StatusOK, Response: page(ref("Answer")), Paged: true,
+		Errors: []int{400, 401, 403, 404, 429},
+	},
+	{
+		Method: "POST", Path: "/api/themes/{themeID}/answers", Tag: "answers",
+		Summary:     "回答を投稿",
+		Description: "受付期間外・受付停止中・審査中のお題には投稿できない（400）",
+		Body:        ref("AnswerInput"),
+		Status:      http.StatusCreated, Response: envelope(ref("Answer")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 413, 422, 429},
+	},
+	{
+		Method: "GET", Path: "/api/themes/{themeID}/answers/{id}", Tag: "answers",
+		Summary: "回答を取得",
+		Params:  []string{"X-Client-Token", "If-None-Match"},
+		Status:  http.StatusOK, Response: envelope(ref("Answer")), ETag: true,
+		Errors: []int{404, 429},
+	},
+	{
+		Method: "PUT", Path: "/api/themes/{themeID}/answers/{id}", Tag: "answers",
+		Summary: "回答を更新（投稿者本人、admin）",
+		Access:  accessRequired,
+		Params:  []string{"If-Match"},
+		Body:    ref("AnswerUpdate"),
+		Status:  http.StatusOK, Response: envelope(ref("Answer")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409, 412, 413, 422},
+	},
+	{
+		Method: "PATCH", Path: "/api/themes/{themeID}/answers/{id}", Tag: "answers",
+		Summary:     "回答を部分更新（投稿者本人、admin）",
+		Description: "JSON Merge Patch（RFC 7396）。省略した項目は変更しない。Content-Type は application/json でもよい",
+		Access:      accessRequired,
+		Params:      []string{"If-Match"},
+		Body:        ref("AnswerPatch"),
+		BodyType:    mergePatchType,
+		Status:      http.StatusOK, Response: envelope(ref("Answer")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409, 412, 413, 422},
+	},
+	{
+		Method: "DELETE", Path: "/api/themes/{themeID}/answers/{id}", Tag: "answers",
+		Summary: "回答を削除（投稿者本人、moderator、admin）",
+		Access:  accessRequired,
+		Params:  []string{"If-Match"},
+		Status:  http.StatusNoContent,
+		Errors:  []int{401, 403, 404, 409, 412},
+	},
+
+	// いいね関連
+	{
+		Method: "POST", Path: "/api/themes/{themeID}/answers/{id}/likes", Tag: "likes",
+		Summary:     "回答にいいねする",
+		Description: "ログイン中のユーザーまたは X-Client-Token で投票者を識別し、同じ投票者は1回までいいねできる",
+		Params:      []string{"X-Client-Token"},
+		Status:      http.StatusOK, Response: envelope(ref("Answer")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409},
+	},
+	{
+		Method: "DELETE", Path: "/api/themes/{themeID}/answers/{id}/likes", Tag: "likes",
+		Summary: "いいねを取り消す",
+		Params:  []string{"X-Client-Token"},
+		Status:  http.StatusOK, Response: envelope(ref("Answer")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409},
+	},
+
+	// 通報・モデレーション関連
+	{
+		Method: "POST", Path: "/api/themes/{themeID}/answers/{id}/reports", Tag: "moderation",
+		Summary:     "回答を通報",
+		Description: "いいねと同じ方法で通報者を識別し、同じ通報者は1つの回答に1回まで通報できる",
+		Params:      []string{"X-Client-Token"},
+		Body:        ref("ReportInput"),
+		Status:      http.StatusCreated, Response: envelope(nil),
+		Errors: []int{400, 401, 403, 404, 409, 413},
+	},
+	{
+		Method: "POST", Path: "/api/themes/{themeID}/answers/{id}/moderation", Tag: "moderation",
+		Summary:     "通報・確認待ちの回答を処理（moderator、admin）",
+		Description: "approve・hide は更新した回答を返し、delete は本文なしの204を返す",
+		Access:      accessRequired,
+		Params:      []string{"If-Match"},
+		Body:        ref("ModerationInput"),
+		Status:      http.StatusOK, Response: envelope(ref("Answer")), ETag: true,
+		Errors: []int{400, 401, 403, 404, 409, 412, 413},
+	},
+	{
+		Method: "GET", Path: "/api/moderation/queue", Tag: "moderation",
+		Summary: "通報された回答と確認待ちの回答を、通報の多い順に取得（moderator、admin）",
+		Access:  accessRequired,
+		Status:  http.StatusOK, Response: envelope(arrayOf(ref("QueueItem"))),
+		Errors: []int{401, 403, 429},
+	},
+
+	// エクスポート・インポート関連
+	{
+		Method: "GET", Path: "/api/admin/export", Tag: "transfer",
+		Summary:     "お題と回答をファイルに書き出す（admin）",
+		Description: "お題の直後にそのお題の全ての回答（確認待ち・非表示を含む）が続く。絞り込みはお題に対して行う。format=csv なら1行目が項目名の CSV（列は TransferRecord の項目）",
+		Access:      accessRequired,
+		Params:      []string{"format", "export_theme_id", "created_after", "created_before"},
+		Status:      http.StatusOK, Response: ref("TransferRecord"), ContentType: transfer.FormatJSONL.ContentType(),
+		Errors: []int{400, 401, 403, 404, 429},
+	},
+	{
+		Method: "POST", Path: "/api/admin/import", Tag: "transfer",
+		Summary: "エクスポートしたファイルのお題と回答を読み込む（admin）",
+		Description: "全ての行を検査し、問題があれば何も保存せずに400（VALIDATION_FAILED）を返す（details の line に行番号）。" +
+			"回答はファイルの前の行のお題か、既にあるお題を指すこと。ボディは JSON Lines（format=csv なら CSV）で32MiBまで",
+		Access: accessRequired,
+		Params: []string{"format", "ids", "dry_run"},
+		Body:   ref("TransferRecord"), BodyType: transfer.FormatJSONL.ContentType(),
+		Status: http.StatusOK, Response: envelope(ref("ImportResult")),
+		Errors: []int{400, 401, 403, 413},
+	},
+
+	// ランキング関連
+	{
+		Method: "GET", Path: "/api/themes/{themeID}/ranking", Tag: "ranking",
+		Summary: "お題の回答をいいね数の多い順に順位付きで取得",
+		Params:  []string{"top", "X-Client-Token"},
+		Status:  http.StatusOK, Response: envelope(arrayOf(ref("RankedAnswer"))),
+		Errors: []int{400, 404, 429},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard", Tag: "ranking",
+		Summary: "作成者ごとの合計いいね数と優勝回数を取得",
+		Params:  []string{"top"},
+		Status:  http.StatusOK, Response: envelope(arrayOf(ref("LeaderboardEntry"))),
+		Errors: []int{400, 429},
+	},
+
+	// リアルタイム配信
+	{
+		Method: "GET", Path: "/api/themes/{themeID}/ws", Tag: "realtime",
+		Summary:     "お題とその回答の変更を WebSocket で受け取る",
+		Description: "接続すると {\"type\":\"subscribed\"} が届き、以降は Event が1メッセージずつ届く",
+		Status:      http.StatusSwitchingProtocols,
+		Errors:      []int{400, 404, 429},
+	},
+	{
+		Method: "GET", Path: "/api/events", Tag: "realtime",
+		Summary:     "お題と回答の変更を Server-Sent Events で受け取る",
+		Description: "event: にイベントの種類、data: に Event の JSON が入る",
+		Params:      []string{"theme_id", "last_event_id", "Last-Event-ID"},
+		Status:      http.StatusOK, Response: ref("Event"), ContentType: "text/event-stream",
+		Errors: []int{400, 404, 429},
+	},
+
+	// APIの仕様
+	{
+		Method: "GET", Path: "/api/openapi.json", Tag: "meta",
+		Summary: "この OpenAPI 3 ドキュメントを取得",
+		Status:  http.StatusOK, Response: schema{"type": "object"},
+		Errors: []int{429},
+	},
+
+	// 死活監視・状態確認
+	{
+		Method: "GET", Path: "/healthz", Tag: "ops",
+		Summary: "プロセスが応答できるか確認",
+		Access:  accessNone,
+		Status:  http.StatusOK, Response: ref("Health"),
+	},
+	{
+		Method: "GET", Path: "/readyz", Tag: "ops",
+		Summary:     "データストアを読み書きできるか確認",
+		Description: "使えない場合は503を返す",
+		Access:      accessNone,
+		Status:      http.StatusOK, Response: ref("Readiness"),
+		Errors: []int{503},
+	},
+	{
+		Method: "GET", Path: "/version", Tag: "ops",
+		Summary: "ビルドと起動の情報を取得",
+		Access:  accessNone,
+		Status:  http.StatusOK, Response: ref("Version"),
+	},
+	{
+		Method: "GET", Path: "/metrics", Tag: "ops",
+		Summary: "Prometheus のテキスト形式でメトリクスを取得",
+		Access:  accessNone,
+		Status:  http.StatusOK, Response: schema{"type": "string"}, ContentType: "text/plain",
+	},
+}
+
+// pathParams はパスのパラメータの説明（パスのテンプレート中の {名前} から作る）
+func pathParams(path string) []schema {
+	var result []schema
+	for _, m := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		name := m[1]
+		description := "お題のID"
+		switch {
+		case name == "id" && strings.HasPrefix(path, "/api/users/"):
+			description = "ユーザーのID"
+		case name == "id" && strings.Contains(path, "/answers/"):
+			description = "回答のID"
+		}
+		result = append(result, schema{
+			"name": name, "in": "path", "required": true,
+			"description": description,
+			"schema":      schema{"type": "string"},
+		})
+	}
+	return result
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// errorResponses はステータスコードごとのエラーレスポンス（components/responses の名前と説明）
+var errorResponses = map[int]struct {
+	name        string
+	description string
+	schema      string
+}{
+	400: {"BadRequest", "リクエストの形式・値が正しくない", "Error"},
+	401: {"Unauthorized", "ログインが必要", "Error"},
+	403: {"Forbidden", "この操作を行う権限が無い", "Error"},
+	404: {"NotFound", "対象が見つからない（閲覧できないものを含む）", "Error"},
+	409: {"Conflict", "すでにいいね・通報済み、ユーザー名が使われている、最後の管理者を降格しようとした、または読み込んだ後に他の操作で更新された", "Error"},
+	412: {"PreconditionFailed", "If-Match のバージョンが古い（ETag ヘッダーに現在の値）", "Error"},
+	413: {"PayloadTooLarge", "リクエストボディが大きすぎる", "Error"},
+	422: {"UnprocessableEntity", "不適切な表現が含まれている（NGワードの設定が reject の場合、details に項目ごとの内容）", "Error"},
+	429: {"TooManyRequests", "リクエストが多すぎる（Retry-After 秒後に再度送る）", "Error"},
+	500: {"InternalServerError", "サーバー内部のエラー（request_id をログと突き合わせる）", "Error"},
+	503: {"ServiceUnavailable", "データストアを使えない", "Readiness"},
+}
+
+// parameters は components/parameters（クエリ・ヘッダーのパラメータ）
+var parameters = map[string]schema{
+	"limit": {
+		"name": "limit", "in": "query", "description": "1ページの件数",
+		"schema": schema{"type": "integer", "minimum": 1, "maximum": maxListLimit, "default": defaultListLimit},
+	},
+	"cursor": {
+		"name": "cursor", "in": "query", "description": "前のレスポンスの next_cursor を指定すると続きを取得する",
+		"schema": schema{"type": "string"},
+	},
+	"theme_sort": {
+		"name": "sort", "in": "query", "description": "並び替えのキー",
+		"schema": schema{"type": "string", "enum": []string{"created_at", "updated_at"}, "default": "created_at"},
+	},
+	"answer_sort": {
+		"name": "sort", "in": "query", "description": "並び替えのキー",
+		"schema": schema{"type": "string", "enum": []string{"created_at", "updated_at", "likes"}, "default": "created_at"},
+	},
+	"order": {
+		"name": "order", "in": "query", "description": "並び順",
+		"schema": schema{"type": "string", "enum": []string{"asc", "desc"}, "default": "asc"},
+	},
+	"active": {
+		"name": "active", "in": "query", "description": "回答を受け付けているかで絞り込む",
+		"schema": schema{"type": "boolean"},
+	},
+	"created_by": {
+		"name": "created_by", "in": "query", "description": "作成者のユーザー名で絞り込む",
+		"schema": schema{"type": "string"},
+	},
+	"created_before": {
+		"name": "created_before", "in": "query", "description": "この日時より前に作成されたものに絞り込む（RFC3339）",
+		"schema": schema{"type": "string", "format": "date-time"},
+	},
+	"created_after": {
+		"name": "created_after", "in": "query", "description": "この日時より後に作成されたものに絞り込む（RFC3339）",
+		"schema": schema{"type": "string", "format": "date-time"},
+	},
+	"status": {
+		"name": "status", "in": "query", "description": "受付状態で絞り込む",
+		"schema": schema{"type": "string", "enum": []string{"upcoming", "open", "closed"}},
+	},
+	"submission_status": {
+		"name": "submission_status", "in": "query",
+		"description": "審査状態で絞り込む（カンマ区切りで複数指定可、all で全て、省略すると pending）",
+		"schema":      schema{"type": "string", "example": "pending,rejected"},
+	},
+	"moderation": {
+		"name": "moderation", "in": "query",
+		"description": "公開状態で絞り込む（public, review, hidden をカンマ区切り、all で全て。moderator・admin のみ）",
+		"schema":      schema{"type": "string", "example": "review,hidden"},
+	},
+	"top": {
+		"name": "top", "in": "query", "description": "上位何件を返すか（省略すると全件）",
+		"schema": schema{"type": "integer", "minimum": 1},
+	},
+	"theme_id": {
+		"name": "theme_id", "in": "query", "description": "このお題のイベントだけを受け取る（省略すると全てのお題）",
+		"schema": schema{"type": "string"},
+	},
+	"format": {
+		"name": "format", "in": "query", "description": "ファイルの形式",
+		"schema": schema{"type": "string", "enum": transfer.Formats, "default": transfer.FormatJSONL},
+	},
+	"export_theme_id": {
+		"name": "theme_id", "in": "query", "description": "指定したお題だけを書き出す（カンマ区切りで複数指定可）",
+		"schema": schema{"type": "string", "example": "theme_1,theme_2"},
+	},
+	"ids": {
+		"name": "ids", "in": "query",
+		"description": "preserve ならファイルのIDをそのまま使い（既にあるIDはエラー）、regenerate なら新しいIDを振る（回答の theme_id も新しいIDにする）",
+		"schema":      schema{"type": "string", "enum": []string{"preserve", "regenerate"}, "default": "preserve"},
+	},
+	"dry_run": {
+		"name": "dry_run", "in": "query", "description": "true なら検査だけを行い、保存しない",
+		"schema": schema{"type": "boolean", "default": false},
+	},
+	"last_event_id": {
+		"name": "last_event_id", "in": "query", "description": "Last-Event-ID ヘッダーの代わりに指定する",
+		"schema": schema{"type": "integer", "minimum": 0},
+	},
+	"Last-Event-ID": {
+		"name": "Last-Event-ID", "in": "header", "description": "最後に受け取ったイベントのID（それ以降のイベントを再送する）",
+		"schema": schema{"type": "integer", "minimum": 0},
+	},
+	response.FormatHeader: {
+		"name": response.FormatHeader, "in": "header",
+		"description": "レスポンスの形式（省略するとサーバーの設定 response_format に従う）",
+		"schema":      schema{"type": "string", "enum": []response.Format{response.FormatEnvelope, response.FormatLegacy}},
+	},
+	"X-Client-Token": {
+		"name": clientTokenHeader, "in": "header", "description": "匿名の投票者・通報者を識別するトークン",
+		"schema": schema{"type": "string"},
+	},
+	"If-Match": {
+		"name": "If-Match", "in": "header", "description": "取得したときの ETag。現在のバージョンと違えば412を返し、変更しない",
+		"schema": schema{"type": "string"},
+	},
+	"If-None-Match": {
+		"name": "If-None-Match", "in": "header", "description": "取得済みの ETag。現在のバージョンと同じなら本文なしの304を返す",
+		"schema": schema{"type": "string"},
+	},
+}
+
+// timestamp は日時のスキーマ
+var timestamp = schema{"type": "string", "format": "date-time"}
+
+// etagHeader はお題・回答のバージョンを表す ETag レスポンスヘッダー
+var etagHeader = schema{
+	"ETag": schema{"description": "お題・回答のバージョン（例: \"3\"）。If-Match・If-None-Match に使う", "schema": schema{"type": "string"}},
+}
+
+// pageHeaders は続きのページがあるときに付くレスポンスヘッダー（互換モードでは本文に next_cursor が無いため、こちらを使う）
+var pageHeaders = schema{
+	"X-Next-Cursor": schema{"description": "続きを取得するときに cursor に指定する値（最後のページなら付かない）", "schema": schema{"type": "string"}},
+	"Link":          schema{"description": `続きのページのURL（rel="next"）`, "schema": schema{"type": "string"}},
+}
+
+// ignoredCreatedBy は以前のクライアントが送る created_by（受け付けるが、ログイン中のユーザー名を使う）
+// mergePatchType は JSON Merge Patch（RFC 7396）のリクエストボディの形式
+const mergePatchType = "application/merge-patch+json"
+
+var ignoredCreatedBy = schema{"type": "string", "deprecated": true, "description": "無視する（ログイン中のユーザー名が設定される）"}
+
+// readOnly はスキーマのコピーに readOnly を付ける
+func readOnly(s schema) schema {
+	c := schema{"readOnly": true}
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+// schemas は components/schemas
+var schemas = map[string]schema{
+	"Theme": {
+		"type":     "object",
+		"required": []string{"id", "title", "description", "created_at", "updated_at", "version", "created_by", "active", "submission_status"},
+		"properties": schema{
+			"id":                readOnly(schema{"type": "string"}),
+			"title":             schema{"type": "string"},
+			"description":       schema{"type": "string"},
+			"created_at":        readOnly(timestamp),
+			"updated_at":        readOnly(timestamp),
+			"version":           readOnly(schema{"type": "integer", "minimum": 0, "description": "変更のたびに1増える（ETag と同じ値）"}),
+			"created_by":        readOnly(schema{"type": "string", "description": "作成したユーザーの名前（匿名の場合は空）"}),
+			"user_id":           readOnly(schema{"type": "string", "description": "作成したユーザーのID（匿名の場合は省略）"}),
+			"active":            schema{"type": "boolean", "description": "回答を受け付けているか"},
+			"opens_at":          schema{"type": "string", "format": "date-time", "description": "受付開始日時（省略すると作成時から受付）"},
+			"closes_at":         schema{"type": "string", "format": "date-time", "description": "受付終了日時（省略すると無期限）"},
+			"submission_status": readOnly(schema{"type": "string", "enum": []string{"pending", "approved", "rejected"}, "description": "審査状態"}),
+			"rejection_reason":  readOnly(schema{"type": "string", "description": "却下した理由（却下された場合のみ）"}),
+		},
+	},
+	"ThemeInput": {
+		"type":                 "object",
+		"required":             []string{"title"},
+		"additionalProperties": false,
+		"properties": schema{
+			"title":       schema{"type": "string", "minLength": 1, "maxLength": validation.MaxTitleLength, "description": "空白だけ・制御文字を含むものは不可"},
+			"description": schema{"type": "string", "maxLength": validation.MaxDescriptionLength, "description": "改行・タブ以外の制御文字は不可"},
+			"active":      schema{"type": "boolean"},
+			"opens_at":    timestamp,
+			"closes_at":   schema{"type": "string", "format": "date-time", "description": "opens_at より後の日時"},
+			"created_by":  ignoredCreatedBy,
+		},
+	},
+	"ThemeUpdate": {
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": schema{
+			"title":       schema{"type": "string", "maxLength": validation.MaxTitleLength, "description": "空なら変更しない"},
+			"description": schema{"type": "string", "maxLength": validation.MaxDescriptionLength, "description": "空なら変更しない"},
+			"active":      schema{"type": "boolean", "description": "省略すると false になる"},
+			"opens_at":    schema{"type": "string", "format": "date-time", "description": "省略すると変更しない"},
+			"closes_at":   schema{"type": "string", "format": "date-time", "description": "省略すると変更しない"},
+			"created_by":  ignoredCreatedBy,
+		},
+	},
+	"ThemePatch": {
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": schema{
+			"title":       schema{"type": "string", "maxLength": validation.MaxTitleLength, "description": "null にはできない（必須）"},
+			"description": schema{"type": "string", "nullable": true, "maxLength": validation.MaxDescriptionLength, "description": "null なら消す"},
+			"active":      schema{"type": "boolean", "description": "null にはできない"},
+			"opens_at":    schema{"type": "string", "format": "date-time", "nullable": true, "description": "null なら消す（受付開始の制限なし）"},
+			"closes_at":   schema{"type": "string", "format": "date-time", "nullable": true, "description": "null なら消す（受付終了の制限なし）"},
+			"created_by":  ignoredCreatedBy,
+		},
+	},
+	"Answer": {
+		"type":     "object",
+		"required": []string{"id", "theme_id", "content", "created_at", "updated_at", "version", "created_by", "likes", "liked_by_me"},
+		"properties": schema{
+			"id":          readOnly(schema{"type": "string"}),
+			"theme_id":    readOnly(schema{"type": "string"}),
+			"content":     schema{"type": "string"},
+			"created_at":  readOnly(timestamp),
+			"updated_at":  readOnly(timestamp),
+			"version":     readOnly(schema{"type": "integer", "minimum": 0, "description": "変更のたびに1増える（いいねを含む、ETag と同じ値）"}),
+			"created_by":  readOnly(schema{"type": "string", "description": "投稿したユーザーの名前（匿名の場合は空）"}),
+			"user_id":     readOnly(schema{"type": "string", "description": "投稿したユーザーのID（匿名の場合は省略）"}),
+			"likes":       readOnly(schema{"type": "integer", "minimum": 0}),
+			"liked_by_me": readOnly(schema{"type": "boolean", "description": "リクエストした投票者がいいね済みか"}),
+			"moderation":  readOnly(schema{"type": "string", "enum": []string{"review", "hidden"}, "description": "公開状態（省略なら公開中）"}),
+		},
+	},
+	"AnswerInput": {
+		"type":                 "object",
+		"required":             []string{"content"},
+		"additionalProperties": false,
+		"properties": schema{
+			"content":    schema{"type": "string", "minLength": 1, "maxLength": validation.MaxContentLength, "description": "空白だけ・改行とタブ以外の制御文字を含むものは不可"},
+			"created_by": ignoredCreatedBy,
+		},
+	},
+	"AnswerUpdate": {
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": schema{
+			"content":    schema{"type": "string", "maxLength": validation.MaxContentLength, "description": "空なら変更しない"},
+			"created_by": ignoredCreatedBy,
+		},
+	},
+	"AnswerPatch": {
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": schema{
+			"content":    schema{"type": "string", "maxLength": validation.MaxContentLength, "description": "null にはできない（必須）"},
+			"created_by": ignoredCreatedBy,
+		},
+	},
+	"Credentials": {
+		"type":     "object",
+		"required": []string{"username", "password"},
+		"properties": schema{
+			"username": schema{"type": "string", "minLength": minUsernameLength, "maxLength": maxUsernameLength, "description": "空白を含まない（ログイン時は長さを検査しない）"},
+			"password": schema{"type": "string", "format": "password", "minLength": minPasswordLength, "description": "72バイト以内"},
+		},
+	},
+	"User": {
+		"type":     "object",
+		"required": []string{"id", "username", "role", "created_at"},
+		"properties": schema{
+			"id":         schema{"type": "string"},
+			"username":   schema{"type": "string"},
+			"role":       ref("Role"),
+			"created_at": timestamp,
+		},
+	},
+	"Role": {
+		"type": "string",
+		"enum": []string{"player", "moderator", "admin"},
+	},
+	"RoleInput": {
+		"type":       "object",
+		"required":   []string{"role"},
+		"properties": schema{"role": ref("Role")},
+	},
+	"Session": {
+		"type":     "object",
+		"required": []string{"user", "token", "expires_at"},
+		"properties": schema{
+			"user":       ref("User"),
+			"token":      schema{"type": "string", "description": "Authorization: Bearer に指定するトークン（" + auth.CookieName + " クッキーにも保存される）"},
+			"expires_at": timestamp,
+		},
+	},
+	"RejectInput": {
+		"type":     "object",
+		"required": []string{"reason"},
+		"properties": schema{
+			"reason": schema{"type": "string", "minLength": 1, "maxLength": maxRejectionReasonLength},
+		},
+	},
+	"ReportInput": {
+		"type":     "object",
+		"required": []string{"reason"},
+		"properties": schema{
+			"reason":  ref("ReportReason"),
+			"comment": schema{"type": "string", "maxLength": maxReportCommentLength},
+		},
+	},
+	"ReportReason": {
+		"type": "string",
+		"enum": []string{"spam", "offensive", "harassment", "off_topic", "other"},
+	},
+	"ModerationInput": {
+		"type":     "object",
+		"required": []string{"action"},
+		"properties": schema{
+			"action": schema{"type": "string", "enum": []string{moderationApprove, moderationHide, moderationDelete}},
+		},
+	},
+	"QueueItem": {
+		"type":     "object",
+		"required": []string{"answer", "report_count", "reasons"},
+		"properties": schema{
+			"answer":           ref("Answer"),
+			"report_count":     schema{"type": "integer", "minimum": 0},
+			"reasons":          schema{"type": "object", "additionalProperties": schema{"type": "integer"}, "description": "理由ごとの通報数"},
+			"comments":         arrayOf(schema{"type": "string"}),
+			"last_reported_at": timestamp,
+		},
+	},
+	"RankedAnswer": {
+		"allOf": []schema{
+			ref("Answer"),
+			{"type": "object", "required": []string{"rank"}, "properties": schema{"rank": schema{"type": "integer", "minimum": 1}}},
+		},
+	},
+	"LeaderboardEntry": {
+		"type":     "object",
+		"required": []string{"rank", "user_id", "username", "total_likes", "wins", "answers"},
+		"properties": schema{
+			"rank":        schema{"type": "integer", "minimum": 1},
+			"user_id":     schema{"type": "string"},
+			"username":    schema{"type": "string"},
+			"total_likes": schema{"type": "integer"},
+			"wins":        schema{"type": "integer", "description": "受付を終了したお題で1位になった回数"},
+			"answers":     schema{"type": "integer"},
+		},
+	},
+	"TransferRecord": {
+		"type":                 "object",
+		"required":             []string{"type"},
+		"additionalProperties": false,
+		"description":          "エクスポート・インポートするファイルの1行。type に合わない項目は空にする",
+		"properties": schema{
+			"type":              schema{"type": "string", "enum": []transfer.RecordType{transfer.TypeTheme, transfer.TypeAnswer}},
+			"id":                schema{"type": "string", "description": "ids=preserve なら必須"},
+			"theme_id":          schema{"type": "string", "description": "回答のみ（必須）"},
+			"title":             schema{"type": "string", "maxLength": validation.MaxTitleLength, "description": "お題のみ（必須）"},
+			"description":       schema{"type": "string", "maxLength": validation.MaxDescriptionLength, "description": "お題のみ"},
+			"content":           schema{"type": "string", "maxLength": validation.MaxContentLength, "description": "回答のみ（必須）"},
+			"created_at":        schema{"type": "string", "format": "date-time", "description": "省略するとインポートした日時"},
+			"updated_at":        schema{"type": "string", "format": "date-time", "description": "省略すると created_at と同じ"},
+			"created_by":        schema{"type": "string"},
+			"user_id":           schema{"type": "string"},
+			"active":            schema{"type": "boolean", "description": "お題のみ"},
+			"opens_at":          schema{"type": "string", "format": "date-time", "description": "お題のみ"},
+			"closes_at":         schema{"type": "string", "format": "date-time", "description": "お題のみ"},
+			"submission_status": schema{"type": "string", "enum": data.AllSubmissionStatuses, "description": "お題のみ。省略すると approved"},
+			"rejection_reason":  schema{"type": "string", "description": "お題のみ"},
+			"likes":             schema{"type": "integer", "minimum": 0, "description": "回答のみ（誰がいいねしたかは移せない）"},
+			"moderation":        schema{"type": "string", "enum": []data.ModerationState{data.ModerationReview, data.ModerationHidden}, "description": "回答のみ。省略すると公開中"},
+		},
+	},
+	"ImportResult": {
+		"type":     "object",
+		"required": []string{"dry_run", "themes", "answers"},
+		"properties": schema{
+			"dry_run": schema{"type": "boolean"},
+			"themes":  schema{"type": "integer", "description": "保存したお題の数（dry_run なら保存される数）"},
+			"answers": schema{"type": "integer", "description": "保存した回答の数（dry_run なら保存される数）"},
+		},
+	},
+	"Event": {
+		"type":     "object",
+		"required": []string{"id", "type", "theme_id", "time"},
+		"properties": schema{
+			"id": schema{"type": "integer", "description": "連番のイベントID"},
+			"type": schema{"type": "string", "enum": []string{
+				"theme.created", "theme.updated", "theme.deleted",
+				"answer.created", "answer.updated", "answer.deleted", "answer.likes_changed",
+			}},
+			"theme_id":  schema{"type": "string"},
+			"answer_id": schema{"type": "string"},
+			"theme":     ref("Theme"),
+			"answer":    ref("Answer"),
+			"likes":     schema{"type": "integer"},
+			"time":      timestamp,
+		},
+	},
+	"Error": {
+		"type":     "object",
+		"required": []string{"success", "error"},
+		"properties": schema{
+			"success": schema{"type": "boolean", "enum": []bool{false}},
+			"error": schema{
+				"type":     "object",
+				"required": []string{"code", "message"},
+				"properties": schema{
+					"code":        schema{"type": "string", "enum": response.Codes, "description": "機械で判別できるエラーコード（意味は変わらない）"},
+					"message":     schema{"type": "string", "description": "利用者向けのメッセージ"},
+					"details":     arrayOf(ref("ErrorDetail")),
+					"retry_after": schema{"type": "integer", "description": "再度送れるようになるまでの秒数（429のみ）"},
+				},
+			},
+			"request_id": schema{"type": "string", "description": "ログと突き合わせるためのリクエストID（" + logging.RequestIDHeader + " と同じ）"},
+		},
+	},
+	"ErrorDetail": {
+		"type":     "object",
+		"required": []string{"field", "code", "message"},
+		"properties": schema{
+			"line":    schema{"type": "integer", "minimum": 1, "description": "インポートのみ。問題のあった行の番号"},
+			"field":   schema{"type": "string", "description": "問題のあるリクエストボディの項目またはクエリパラメータ"},
+			"code":    schema{"type": "string", "enum": response.DetailCodes},
+			"message": schema{"type": "string"},
+		},
+	},
+	"Health": {
+		"type":       "object",
+		"required":   []string{"status"},
+		"properties": schema{"status": schema{"type": "string", "enum": []string{"ok"}}},
+	},
+	"Readiness": {
+		"type":     "object",
+		"required": []string{"status", "checks"},
+		"properties": schema{
+			"status": schema{"type": "string", "enum": []string{"ok", "unavailable"}},
+			"checks": schema{"type": "object", "additionalProperties": schema{"type": "string"}, "description": "確認項目ごとの結果（ok またはエラーの内容）"},
+		},
+	},
+	"Version": {
+		"type":     "object",
+		"required": []string{"version", "commit", "go_version", "started_at", "uptime"},
+		"properties": schema{
+			"version":    schema{"type": "string"},
+			"commit":     schema{"type": "string"},
+			"build_time": schema{"type": "string"},
+			"go_version": schema{"type": "string"},
+			"started_at": timestamp,
+			"uptime":     schema{"type": "string"},
+		},
+	},
+}
+
+// buildOpenAPI は operations から OpenAPI 3 のドキュメントを組み立てる
+func buildOpenAPI() schema {
+	paths := schema{}
+	for _, op := range operations {
+		item, ok := paths[op.Path].(schema)
+		if !ok {
+			item = schema{}
+			if pp := pathParams(op.Path); len(pp) > 0 {
+				item["parameters"] = pp
+			}
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = op.document()
+	}
+
+	responses := schema{}
+	for _, e := range errorResponses {
+		responses[e.name] = schema{
+			"description": e.description,
+			"content":     schema{"application/json": schema{"schema": ref(e.schema)}},
+		}
+	}
+	responses["TooManyRequests"].(schema)["headers"] = schema{
+		"Retry-After": schema{"description": "再度送れるようになるまでの秒数", "schema": schema{"type": "integer"}},
+	}
+
+	return schema{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title": "大喜利サーバー API",
+			"description": "大喜利のお題・回答・いいね・通報を扱うAPI。このドキュメントは " + response.FormatHeader + ": envelope を送ったときの形式で、" +
+				"/api/ のレスポンスは {\"success\", \"message\", \"data\"} 形式、エラーは error にコード・メッセージ・項目ごとの詳細を入れて返す。" +
+				"ヘッダーを送らなければ以前の形式（一部は本文をそのまま返し、エラーは {\"error\": \"...\"}）で返す（response_format で変更できる）。" +
+				"全てのレスポンスに " + logging.RequestIDHeader + " ヘッダーが付く",
+			"version": buildinfo.Get().Version,
+		},
+		"paths": paths,
+		// ログインは任意（匿名でも使える）。ログインが必要なエンドポイントは個別に上書きする
+		"security": []schema{{}, {"bearerAuth": []string{}}, {"cookieAuth": []string{}}},
+		"components": schema{
+			"schemas":    schemas,
+			"parameters": parameters,
+			"responses":  responses,
+			"securitySchemes": schema{
+				"bearerAuth": schema{"type": "http", "scheme": "bearer", "description": "ログインで返される token"},
+				"cookieAuth": schema{"type": "apiKey", "in": "cookie", "name": auth.CookieName},
+			},
+		},
+	}
+}
+
+// document は1つのエンドポイントの Operation Object を返す
+func (op operation) document() schema {
+	doc := schema{
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+		"operationId": operationID(op.Method, op.Path),
+	}
+	if op.Description != "" {
+		doc["description"] = op.Description
+	}
+	switch op.Access {
+	case accessRequired:
+		doc["security"] = []schema{{"bearerAuth": []string{}}, {"cookieAuth": []string{}}}
+	case accessNone:
+		doc["security"] = []schema{}
+	}
+	names := op.Params
+	if strings.HasPrefix(op.Path, "/api/") {
+		names = append(names[:len(names):len(names)], response.FormatHeader)
+	}
+	if len(names) > 0 {
+		var ps []schema
+		for _, name := range names {
+			ps = append(ps, schema{"$ref": "#/components/parameters/" + name})
+		}
+		doc["parameters"] = ps
+	}
+	if op.Body != nil {
+		bodyType := op.BodyType
+		if bodyType == "" {
+			bodyType = "application/json"
+		}
+		doc["requestBody"] = schema{
+			"required": true,
+			"content":  schema{bodyType: schema{"schema": op.Body}},
+		}
+	}
+
+	success := schema{"description": http.StatusText(op.Status)}
+	if op.ETag {
+		success["headers"] = etagHeader
+	}
+	if op.Paged {
+		success["headers"] = pageHeaders
+	}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success["content"] = schema{contentType: schema{"schema": op.Response}}
+	}
+	responses := schema{strconv.Itoa(op.Status): success}
+	for _, code := range append(op.Errors, http.StatusInternalServerError) {
+		responses[strconv.Itoa(code)] = schema{"$ref": "#/components/responses/" + errorResponses[code].name}
+	}
+	for _, name := range op.Params {
+		if name == "If-None-Match" {
+			responses[strconv.Itoa(http.StatusNotModified)] = schema{"description": "If-None-Match が現在の ETag に一致する（本文なし）", "headers": etagHeader}
+		}
+	}
+	doc["responses"] = responses
+	return doc
+}
+
+// operationID はメソッドとパスから一意な operationId を作る（例: GET /api/themes/{id} → get_themes_id）
+func operationID(method, path string) string {
+	path = strings.TrimPrefix(path, "/api")
+	replacer := strings.NewReplacer("/", "_", "{", "", "}", "", ".", "_", "-", "_")
+	return strings.ToLower(method) + replacer.Replace(path)
+}
+
+// openAPIDocument は組み立てたドキュメントのJSON（初回の要求時に一度だけ作る）
+var openAPIDocument = sync.OnceValues(func() ([]byte, error) {
+	return json.MarshalIndent(buildOpenAPI(), "", "  ")
+})
+
+// OpenAPI はAPIの仕様を OpenAPI 3 のJSONで返す
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := openAPIDocument()
+	if err != nil {
+		sendServerError(w, r, "APIの仕様の生成に失敗しました", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
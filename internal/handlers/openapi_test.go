@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+)
+
+// fetchOpenAPI は /api/openapi.json を取得してデコードする
+func fetchOpenAPI(t *testing.T, api *mux.Router) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json: status = %d, want 200", rec.Code)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("OpenAPIのJSONを読み込めません: %v", err)
+	}
+	return doc
+}
+
+// TestOpenAPICoversRoutes は登録した全てのルートとメソッドが仕様に載っていることを確認する
+// OPTIONS（CORSのプリフライト）と HEAD（GETと同じ応答で本文なし）は対象外
+func TestOpenAPICoversRoutes(t *testing.T) {
+	h := NewHandler(data.NewInMemoryStore(), realtime.NewHub(), nil, nil)
+	api, ops := h.Routes(), h.OpsRoutes(http.NotFoundHandler())
+	paths := fetchOpenAPI(t, api)["paths"].(map[string]interface{})
+
+	registered := make(map[string]bool)
+	for _, router := range []*mux.Router{api, ops} {
+		err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return err
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				t.Errorf("%s: メソッドを指定していないルートは仕様に載せられません", path)
+				return nil
+			}
+			for _, method := range methods {
+				if method == http.MethodOptions || method == http.MethodHead {
+					continue
+				}
+				registered[method+" "+path] = true
+				item, ok := paths[path].(map[string]interface{})
+				if !ok {
+					t.Errorf("%s %s: パスが仕様にありません", method, path)
+					continue
+				}
+				op, ok := item[strings.ToLower(method)].(map[string]interface{})
+				if !ok {
+					t.Errorf("%s %s: メソッドが仕様にありません", method, path)
+					continue
+				}
+				if op["summary"] == "" || op["responses"] == nil {
+					t.Errorf("%s %s: summary と responses が必要です", method, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 削除したルートが仕様に残っていないことも確認する
+	for path, item := range paths {
+		for key := range item.(map[string]interface{}) {
+			if key == "parameters" {
+				continue
+			}
+			if method := strings.ToUpper(key); !registered[method+" "+path] {
+				t.Errorf("%s %s: 仕様にあるが登録されていません", method, path)
+			}
+		}
+	}
+}
+
+// TestOpenAPIRefs は仕様の中の $ref が全て components に存在することを確認する
+func TestOpenAPIRefs(t *testing.T) {
+	api := NewHandler(data.NewInMemoryStore(), realtime.NewHub(), nil, nil).Routes()
+	doc := fetchOpenAPI(t, api)
+	components := doc["components"].(map[string]interface{})
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if target, ok := v["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(target, "#/components/"), "/")
+				section, _ := components[parts[0]].(map[string]interface{})
+				if len(parts) != 2 || section[parts[1]] == nil {
+					t.Errorf("参照先がありません: %s", target)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// TestPatchTheme は省略した項目・null・false を JSON Merge Patch に従って区別することを確認する
+func TestPatchTheme(t *testing.T) {
+	opensAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	closesAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name     string
+		body     string
+		want     int
+		wantCode response.Code
+		check    func(t *testing.T, theme *data.Theme)
+	}{
+		{
+			"省略した項目は変更しない", `{"title":"新しいタイトル"}`, http.StatusOK, "",
+			func(t *testing.T, theme *data.Theme) {
+				if theme.Title != "新しいタイトル" || theme.Description != "説明" || !theme.Active || theme.ClosesAt == nil {
+					t.Errorf("theme = %+v", theme)
+				}
+			},
+		},
+		{
+			"false で受付を停止する", `{"active":false}`, http.StatusOK, "",
+			func(t *testing.T, theme *data.Theme) {
+				if theme.Active || theme.Title != "お題" {
+					t.Errorf("theme = %+v", theme)
+				}
+			},
+		},
+		{
+			"null で説明を消す", `{"description":null}`, http.StatusOK, "",
+			func(t *testing.T, theme *data.Theme) {
+				if theme.Description != "" || theme.Title != "お題" {
+					t.Errorf("theme = %+v", theme)
+				}
+			},
+		},
+		{
+			"空文字で説明を消す", `{"description":""}`, http.StatusOK, "",
+			func(t *testing.T, theme *data.Theme) {
+				if theme.Description != "" {
+					t.Errorf("description = %q", theme.Description)
+				}
+			},
+		},
+		{
+			"null で受付終了日時を消す", `{"closes_at":null}`, http.StatusOK, "",
+			func(t *testing.T, theme *data.Theme) {
+				if theme.ClosesAt != nil || theme.OpensAt == nil || !theme.OpensAt.Equal(opensAt) {
+					t.Errorf("opens_at = %v, closes_at = %v", theme.OpensAt, theme.ClosesAt)
+				}
+			},
+		},
+		{"null でタイトルを消すと必須の検査で拒否する", `{"title":null}`, http.StatusBadRequest, response.CodeValidationFailed, nil},
+		{"active は null にできない", `{"active":null}`, http.StatusBadRequest, response.CodeValidationFailed, nil},
+		{"型が違う", `{"active":"false"}`, http.StatusBadRequest, response.CodeValidationFailed, nil},
+		{"受け付けていない項目", `{"likes":1}`, http.StatusBadRequest, response.CodeValidationFailed, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := s.login("admin", data.RoleAdmin)
+			theme := &data.Theme{Title: "お題", Description: "説明", Active: true, OpensAt: &opensAt, ClosesAt: &closesAt}
+			if err := s.store.CreateTheme(theme); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := s.do(http.MethodPatch, "/api/themes/"+theme.ID, tt.body, "Authorization", admin)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			stored, err := s.store.GetTheme(theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantCode != "" {
+				if env := decodeEnvelope(t, rec, nil); env.Error == nil || env.Error.Code != tt.wantCode {
+					t.Errorf("error = %+v, want %s", env.Error, tt.wantCode)
+				}
+				// 拒否した変更は保存しない
+				if stored.Title != "お題" || stored.Description != "説明" || !stored.Active {
+					t.Errorf("拒否した変更が保存されています: %+v", stored)
+				}
+				return
+			}
+			var got data.Theme
+			decodeEnvelope(t, rec, &got)
+			tt.check(t, &got)
+			tt.check(t, stored)
+		})
+	}
+}
+
+// TestPatchAnswer は回答の部分更新で省略・null を区別することを確認する
+func TestPatchAnswer(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		want        int
+		wantContent string
+	}{
+		{"内容を変更する", `{"content":"新しい回答"}`, http.StatusOK, "新しい回答"},
+		{"省略すると変更しない", `{}`, http.StatusOK, "回答"},
+		{"created_by は受け付けるが使わない", `{"created_by":"なりすまし"}`, http.StatusOK, "回答"},
+		{"null で内容を消すと必須の検査で拒否する", `{"content":null}`, http.StatusBadRequest, "回答"},
+		{"空文字も拒否する", `{"content":""}`, http.StatusBadRequest, "回答"},
+		{"受け付けていない項目", `{"likes":100}`, http.StatusBadRequest, "回答"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := s.login("admin", data.RoleAdmin)
+			theme := s.createTheme("お題")
+			answer := s.createAnswer(theme.ID, "回答")
+
+			rec := s.do(http.MethodPatch, "/api/themes/"+theme.ID+"/answers/"+answer.ID, tt.body, "Authorization", admin)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			stored, err := s.store.GetAnswer(answer.ID, theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Content != tt.wantContent || stored.CreatedBy != "" || stored.Likes != 0 {
+				t.Errorf("stored = %+v", stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/ranking"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// parseTop は上位何件を返すかを top クエリから読み取る（指定が無ければ 0 = 全件）
+func parseTop(r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("top")
+	if v == "" {
+		return 0, true
+	}
+	top, err := strconv.Atoi(v)
+	if err != nil || top < 1 {
+		return 0, false
+	}
+	return top, true
+}
+
+// ThemeRanking はお題の回答をいいね数の順位付きで取得
+func (h *Handler) ThemeRanking(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	themeID := vars["themeID"]
+
+	// テーマの存在確認
+	theme, err := h.store.GetTheme(themeID)
+	if err == data.ErrNotFound || (err == nil && !canViewTheme(r, theme)) {
+		sendError(w, r, errThemeNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "お題の取得に失敗しました", err)
+		return
+	}
+
+	top, ok := parseTop(r)
+	if !ok {
+		sendError(w, r, queryError("top", "top は1以上の整数で指定してください"))
+		return
+	}
+
+	answers, _, err := h.store.ListAnswers(themeID, data.ListOptions{})
+	if err != nil {
+		sendServerError(w, r, "回答の取得に失敗しました", err)
+		return
+	}
+
+	ranked := ranking.RankAnswers(answers)
+	if top > 0 && top < len(ranked) {
+		ranked = ranked[:top]
+	}
+	for i := range ranked {
+		if ranked[i].Answer, err = h.withLikedByMe(r, ranked[i].Answer); err != nil {
+			sendServerError(w, r, "回答の取得に失敗しました", err)
+			return
+		}
+	}
+
+	response.Write(w, http.StatusOK, "ランキングの取得に成功しました", ranked)
+}
+
+// Leaderboard は全てのお題を通した作成者ごとの合計いいね数と優勝回数を取得
+func (h *Handler) Leaderboard(w http.ResponseWriter, r *http.Request) {
+	top, ok := parseTop(r)
+	if !ok {
+		sendError(w, r, queryError("top", "top は1以上の整数で指定してください"))
+		return
+	}
+
+	board, err := ranking.Leaderboard(h.store, time.Now())
+	if err != nil {
+		sendServerError(w, r, "リーダーボードの集計に失敗しました", err)
+		return
+	}
+	if top > 0 && top < len(board) {
+		board = board[:top]
+	}
+
+	response.Write(w, http.StatusOK, "リーダーボードの取得に成功しました", board)
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+)
+
+// WebSocket接続の設定
+const (
+	wsWriteWait  = 10 * time.Second    // 1メッセージの書き込み期限
+	wsPongWait   = 60 * time.Second    // pongを待つ期限
+	wsPingPeriod = wsPongWait * 9 / 10 // pingを送る間隔（wsPongWaitより短くする）
+)
+
+// Server-Sent Eventsの設定
+const (
+	sseHeartbeatPeriod = 15 * time.Second // プロキシに切断されないようにコメント行を送る間隔
+	sseRetry           = 3000             // クライアントが再接続するまでの待ち時間（ミリ秒）
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// APIはCORSで全てのオリジンを許可しているため、WebSocketも同様にする
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// AnswerFeed はお題とその回答の変更をWebSocketで配信する
+// 接続直後に {"type":"subscribed"} を送り、以降はお題の更新・削除と回答の作成・更新・削除・いいね数の変更を送る
+// 受信が追いつかない場合は 1013 (Try Again Later) で切断するので、クライアントは再接続して一覧を取り直す
+func (h *Handler) AnswerFeed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	themeID := vars["themeID"]
+
+	// テーマの存在確認
+	theme, err := h.store.GetTheme(themeID)
+	if err == data.ErrNotFound || (err == nil && !canViewTheme(r, theme)) {
+		sendError(w, r, errThemeNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "お題の取得に失敗しました", err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade がエラーレスポンスを送信済み
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe(themeID)
+	defer h.hub.Unsubscribe(sub)
+
+	// クライアントからのメッセージは読み捨て、pongと切断だけを検知する
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := conn.WriteJSON(map[string]string{"type": "subscribed", "theme_id": themeID}); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case ev, ok := <-sub.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				code, reason := websocket.CloseGoingAway, "サーバーを停止します"
+				if sub.Dropped() {
+					code, reason = websocket.CloseTryAgainLater, "受信が遅れたため切断しました"
+				}
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+				return
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// EventStream はお題と回答の変更をServer-Sent Eventsで配信する
+// theme_id を指定するとそのお題のイベントだけを送る
+// Last-Event-ID ヘッダー（または last_event_id クエリ）を送ると、それ以降のイベントを再送してから配信を続ける
+// 再送できない場合は reset イベントを送るので、クライアントは一覧を取り直す
+func (h *Handler) EventStream(w http.ResponseWriter, r *http.Request) {
+	themeID := r.URL.Query().Get("theme_id")
+	if themeID != "" {
+		theme, err := h.store.GetTheme(themeID)
+		if err == data.ErrNotFound || (err == nil && !canViewTheme(r, theme)) {
+			sendError(w, r, errThemeNotFound)
+			return
+		}
+		if err != nil {
+			sendServerError(w, r, "お題の取得に失敗しました", err)
+			return
+		}
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			sendError(w, r, queryError("last_event_id", "Last-Event-IDが不正です"))
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// 配信は長時間続くため、サーバーの書き込みタイムアウトを無効にする（未対応なら無視）
+	rc.SetWriteDeadline(time.Time{})
+
+	var sub *realtime.Subscriber
+	var missed []realtime.Event
+	resumable := true
+	if lastEventID != "" {
+		sub, missed, resumable = h.hub.SubscribeSince(themeID, lastID)
+	} else {
+		sub = h.hub.Subscribe(themeID)
+	}
+	defer h.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// nginxなどのプロキシにバッファリングさせない
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+	if !resumable {
+		// 取りこぼしがあるため、最新のIDから読み直してもらう
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", h.hub.LastID())
+		missed = nil
+	}
+	for _, ev := range missed {
+		if err := writeSSE(w, ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatPeriod)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C:
+			if !ok {
+				// ハブから切断された。クライアントは Last-Event-ID を付けて再接続する
+				return
+			}
+			if err := writeSSE(w, ev); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeSSE はイベントを1件書き込む
+func writeSSE(w http.ResponseWriter, ev realtime.Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, payload)
+	return err
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent はテストで読み取るServer-Sent Eventsの1件分
+type sseEvent struct {
+	ID    string
+	Event string
+}
+
+// openEventStream は /api/events に接続し、n 件のイベントを読み取るまで待つ関数を返す
+func openEventStream(t *testing.T, s *testServer, query string, lastEventID string) func(n int) []sseEvent {
+	t.Helper()
+	srv := httptest.NewServer(s.handler)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/events"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	return func(n int) []sseEvent {
+		t.Helper()
+		var events []sseEvent
+		var cur sseEvent
+		for len(events) < n && scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				// retry やハートビートだけのブロックは数えない
+				if cur.Event != "" {
+					events = append(events, cur)
+				}
+				cur = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				cur.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				cur.Event = strings.TrimPrefix(line, "event: ")
+			}
+		}
+		if len(events) < n {
+			t.Fatalf("%d 件のイベントしか受信できません: %v", len(events), scanner.Err())
+		}
+		return events
+	}
+}
+
+// TestEventStream は Last-Event-ID 以降のイベントを再送してから配信を続けることを確認する
+func TestEventStream(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")    // id 1
+	s.createAnswer(theme.ID, "回答1") // id 2
+	s.createAnswer(theme.ID, "回答2") // id 3
+	other := s.createTheme("別のお題")  // id 4
+
+	tests := []struct {
+		name        string
+		query       string
+		lastEventID string
+		want        []sseEvent
+	}{
+		{"取りこぼしを再送する", "", "1", []sseEvent{{"2", "answer.created"}, {"3", "answer.created"}, {"4", "theme.created"}}},
+		{"お題で絞り込んで再送する", "?theme_id=" + theme.ID, "1", []sseEvent{{"2", "answer.created"}, {"3", "answer.created"}}},
+		{"未知のIDなら reset を送る", "", "99", []sseEvent{{"4", "reset"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := openEventStream(t, s, tt.query, tt.lastEventID)
+			got := next(len(tt.want))
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("%d 件目 = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	// 再送の後は接続中に起きたイベントを配信する（別のお題のイベントは届かない）
+	next := openEventStream(t, s, "?theme_id="+theme.ID, "3")
+	s.createAnswer(other.ID, "別のお題の回答")       // id 5
+	answer := s.createAnswer(theme.ID, "回答3") // id 6
+	got := next(1)
+	if want := (sseEvent{"6", "answer.created"}); got[0] != want {
+		t.Errorf("配信 = %+v, want %+v (answer %s)", got[0], want, answer.ID)
+	}
+	if last := strconv.FormatUint(s.hub.LastID(), 10); last != "6" {
+		t.Errorf("LastID = %s, want 6", last)
+	}
+}
+
+// TestEventStreamErrors は接続前に検証するパラメーターのエラーを確認する
+func TestEventStreamErrors(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name    string
+		path    string
+		headers []string
+		want    int
+	}{
+		{"存在しないお題", "/api/events?theme_id=missing", nil, http.StatusNotFound},
+		{"数値でない Last-Event-ID", "/api/events", []string{"Last-Event-ID", "abc"}, http.StatusBadRequest},
+		{"数値でない last_event_id", "/api/events?last_event_id=-1", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodGet, tt.path, "", tt.headers...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
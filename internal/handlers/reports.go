@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// maxReportCommentLength は通報に添えるコメントの最大文字数
+const maxReportCommentLength = 500
+
+// reportRequest は通報のリクエストボディ
+type reportRequest struct {
+	Reason  data.ReportReason `json:"reason"`
+	Comment string            `json:"comment"`
+}
+
+// ReportAnswer は回答を通報する（同じ通報者は1つの回答に1回まで）
+func (h *Handler) ReportAnswer(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReportAnswer, "") {
+		return
+	}
+
+	vars := mux.Vars(r)
+	themeID := vars["themeID"]
+	id := vars["id"]
+
+	// 通報者はいいねと同じ方法で識別する
+	reporter := voterID(r)
+	if reporter == "" {
+		sendError(w, r, errClientTokenRequired)
+		return
+	}
+
+	var req reportRequest
+	errs, ok := decodeBody(w, r, &req)
+	if !ok {
+		return
+	}
+	if !errs.Has("reason") && !req.Reason.Valid() {
+		reasons := make([]string, len(data.ReportReasons))
+		for i, reason := range data.ReportReasons {
+			reasons[i] = string(reason)
+		}
+		errs.Add("reason", response.CodeInvalidValue, fmt.Sprintf("reason には %s のいずれかを指定してください", strings.Join(reasons, ", ")))
+	}
+	if !errs.Has("comment") && utf8.RuneCountInString(req.Comment) > maxReportCommentLength {
+		errs.Add("comment", response.CodeTooLong, fmt.Sprintf("comment は%d文字以内で入力してください", maxReportCommentLength))
+	}
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
+	}
+
+	// 閲覧できない回答は通報できない
+	answer, err := h.store.GetAnswer(id, themeID)
+	if err == data.ErrNotFound || (err == nil && !canView(r, answer.Moderation, answer.UserID)) {
+		sendError(w, r, errAnswerNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "回答の取得に失敗しました", err)
+		return
+	}
+
+	report := data.Report{
+		AnswerID:   id,
+		ThemeID:    themeID,
+		ReporterID: reporter,
+		Reason:     req.Reason,
+		Comment:    req.Comment,
+	}
+	switch err := h.store.CreateReport(&report); err {
+	case nil:
+	case data.ErrNotFound:
+		sendError(w, r, errAnswerNotFound)
+		return
+	case data.ErrAlreadyReported:
+		sendError(w, r, conflictError(err))
+		return
+	default:
+		sendServerError(w, r, "通報の保存に失敗しました", err)
+		return
+	}
+
+	response.Write(w, http.StatusCreated, "通報を受け付けました", nil)
+}
+
+// queueItem はモデレーションキューの1件分
+type queueItem struct {
+	Answer         *data.Answer              `json:"answer"`
+	ReportCount    int                       `json:"report_count"`
+	Reasons        map[data.ReportReason]int `json:"reasons"`            // 理由ごとの通報数
+	Comments       []string                  `json:"comments,omitempty"` // 通報に添えられたコメント（古い順）
+	LastReportedAt *time.Time                `json:"last_reported_at,omitempty"`
+}
+
+// ModerationQueue は通報された回答と確認待ちの回答を、通報の多い順に取得
+func (h *Handler) ModerationQueue(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermReviewContent, "") {
+		return
+	}
+
+	items, err := h.moderationQueue()
+	if err != nil {
+		sendServerError(w, r, "モデレーションキューの取得に失敗しました", err)
+		return
+	}
+
+	response.Write(w, http.StatusOK, "モデレーションキューの取得に成功しました", items)
+}
+
+// moderationQueue は通報を回答ごとにまとめ、NGワードで確認待ちになった回答と合わせて返す
+func (h *Handler) moderationQueue() ([]*queueItem, error) {
+	reports, err := h.store.ListReports()
+	if err != nil {
+		return nil, err
+	}
+
+	byAnswer := make(map[string]*queueItem)
+	items := make([]*queueItem, 0)
+	for _, report := range reports {
+		item, exists := byAnswer[report.AnswerID]
+		if !exists {
+			answer, err := h.store.GetAnswer(report.AnswerID, report.ThemeID)
+			if err == data.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			item = &queueItem{Answer: answer, Reasons: make(map[data.ReportReason]int)}
+			byAnswer[report.AnswerID] = item
+			items = append(items, item)
+		}
+		item.ReportCount++
+		item.Reasons[report.Reason]++
+		if report.Comment != "" {
+			item.Comments = append(item.Comments, report.Comment)
+		}
+		reportedAt := report.CreatedAt
+		item.LastReportedAt = &reportedAt
+	}
+
+	// 確認待ちの回答は通報が無くてもキューに入れる
+	themes, _, err := h.store.ListThemes(data.ListOptions{Submission: data.AllSubmissionStatuses})
+	if err != nil {
+		return nil, err
+	}
+	for _, theme := range themes {
+		answers, _, err := h.store.ListAnswers(theme.ID, data.ListOptions{Moderation: []data.ModerationState{data.ModerationReview}})
+		if err != nil {
+			return nil, err
+		}
+		for _, answer := range answers {
+			if _, exists := byAnswer[answer.ID]; !exists {
+				item := &queueItem{Answer: answer, Reasons: make(map[data.ReportReason]int)}
+				byAnswer[answer.ID] = item
+				items = append(items, item)
+			}
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].ReportCount != items[j].ReportCount {
+			return items[i].ReportCount > items[j].ReportCount
+		}
+		return items[i].Answer.CreatedAt.Before(items[j].Answer.CreatedAt)
+	})
+	return items, nil
+}
+
+// 回答に対して moderator が行える操作
+const (
+	moderationApprove = "approve" // 公開して通報を対応済みにする
+	moderationHide    = "hide"    // 非表示にして通報を対応済みにする
+	moderationDelete  = "delete"  // 削除する
+)
+
+// ModerateAnswer は通報・確認待ちの回答を承認・非表示・削除する
+func (h *Handler) ModerateAnswer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	themeID := vars["themeID"]
+	id := vars["id"]
+
+	var req struct {
+		Action string `json:"action"`
+	}
+	errs, ok := decodeBody(w, r, &req)
+	if !ok {
+		return
+	}
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
+	}
+
+	var perm auth.Permission
+	var state data.ModerationState
+	switch req.Action {
+	case moderationApprove:
+		perm, state = auth.PermHideAnswer, data.ModerationNone
+	case moderationHide:
+		perm, state = auth.PermHideAnswer, data.ModerationHidden
+	case moderationDelete:
+		perm = auth.PermDeleteAnswer
+	default:
+		sendError(w, r, fieldError("action", response.CodeInvalidValue, "action には approve, hide, delete のいずれかを指定してください"))
+		return
+	}
+	// 投稿者本人でも自分の回答の承認・非表示はできないため、ownerID は渡さない
+	if !authorize(w, r, perm, "") {
+		return
+	}
+
+	answer, err := h.store.GetAnswer(id, themeID)
+	if err == data.ErrNotFound {
+		sendError(w, r, errAnswerNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "回答の取得に失敗しました", err)
+		return
+	}
+	if !checkIfMatch(w, r, answer.Version, "回答は他の操作で更新されています。取得し直してから再度お試しください") {
+		return
+	}
+
+	if req.Action == moderationDelete {
+		if err := h.store.DeleteAnswer(id, themeID, ifMatchVersion(r, answer.Version)); err == data.ErrNotFound {
+			sendError(w, r, errAnswerNotFound)
+			return
+		} else if err == data.ErrVersionConflict {
+			sendError(w, r, conflictError(err))
+			return
+		} else if err != nil {
+			sendServerError(w, r, "回答の削除に失敗しました", err)
+			return
+		}
+		response.NoContent(w)
+		return
+	}
+
+	// ストアが返すポインタは共有されている場合があるため、コピーを更新する
+	updated := *answer
+	updated.Moderation = state
+	if err := h.store.UpdateAnswer(&updated); err == data.ErrVersionConflict {
+		sendError(w, r, conflictError(err))
+		return
+	} else if err != nil {
+		sendServerError(w, r, "回答の更新に失敗しました", err)
+		return
+	}
+	if err := h.store.DeleteReports(id); err != nil {
+		sendServerError(w, r, "通報の更新に失敗しました", err)
+		return
+	}
+
+	message := "回答を公開しました"
+	if state == data.ModerationHidden {
+		message = "回答を非表示にしました"
+	}
+	setETag(w, updated.Version)
+	response.WriteData(w, r, http.StatusOK, message, &updated)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// queueIDs はモデレーションキューの「回答ID:通報数」を順に返す
+func queueIDs(t *testing.T, s *testServer, moderator string) []string {
+	t.Helper()
+	rec := s.do(http.MethodGet, "/api/moderation/queue", "", "Authorization", moderator)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("キューの取得: status = %d\n%s", rec.Code, rec.Body.String())
+	}
+	var items []queueItem
+	decodeEnvelope(t, rec, &items)
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = fmt.Sprintf("%s:%d", item.Answer.ID, item.ReportCount)
+	}
+	return ids
+}
+
+// TestReportAnswer は通報の受付と、同じ通報者の重複・不正な入力を確認する
+func TestReportAnswer(t *testing.T) {
+	s := newTestServer(t)
+	theme := s.createTheme("お題")
+	answer := s.createAnswer(theme.ID, "回答")
+	hidden := &data.Answer{ThemeID: theme.ID, Content: "非表示の回答", Moderation: data.ModerationHidden}
+	if err := s.store.CreateAnswer(hidden); err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/themes/" + theme.ID + "/answers/" + answer.ID + "/reports"
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		headers []string
+		want    int
+	}{
+		{"通報者を識別できない", path, `{"reason":"spam"}`, nil, http.StatusBadRequest},
+		{"理由が無い", path, `{}`, []string{"X-Client-Token", "t1"}, http.StatusBadRequest},
+		{"未定義の理由", path, `{"reason":"boring"}`, []string{"X-Client-Token", "t1"}, http.StatusBadRequest},
+		{"通報できる", path, `{"reason":"spam","comment":"宣伝です"}`, []string{"X-Client-Token", "t1"}, http.StatusCreated},
+		{"同じ通報者は1回まで", path, `{"reason":"offensive"}`, []string{"X-Client-Token", "t1"}, http.StatusConflict},
+		{"別の通報者", path, `{"reason":"offensive"}`, []string{"X-Client-Token", "t2"}, http.StatusCreated},
+		{"存在しない回答", "/api/themes/" + theme.ID + "/answers/none/reports", `{"reason":"spam"}`, []string{"X-Client-Token", "t1"}, http.StatusNotFound},
+		{"非表示の回答", "/api/themes/" + theme.ID + "/answers/" + hidden.ID + "/reports", `{"reason":"spam"}`, []string{"X-Client-Token", "t1"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodPost, tt.path, tt.body, tt.headers...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	reports, err := s.store.ListReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Errorf("通報の数 = %d, want 2", len(reports))
+	}
+}
+
+// TestModerationQueue は通報された回答の承認・非表示・削除で、公開状態とキューが変わることを確認する
+func TestModerationQueue(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    string
+		want      int
+		wantState data.ModerationState
+		wantGone  bool // 回答が削除されるか
+	}{
+		{"承認すると公開したまま通報を対応済みにする", "approve", http.StatusOK, data.ModerationNone, false},
+		{"非表示にすると一般のユーザーに見えなくなる", "hide", http.StatusOK, data.ModerationHidden, false},
+		{"削除すると回答が無くなる", "delete", http.StatusNoContent, "", true},
+		{"未定義の操作", "ban", http.StatusBadRequest, data.ModerationNone, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			moderator := s.login("mod", data.RoleModerator)
+			theme := s.createTheme("お題")
+			reported := s.createAnswer(theme.ID, "通報された回答")
+			other := s.createAnswer(theme.ID, "もう一つの回答")
+			for _, report := range []struct{ answer, token string }{
+				{reported.ID, "t1"}, {reported.ID, "t2"}, {other.ID, "t1"},
+			} {
+				rec := s.do(http.MethodPost, "/api/themes/"+theme.ID+"/answers/"+report.answer+"/reports", `{"reason":"spam"}`, "X-Client-Token", report.token)
+				if rec.Code != http.StatusCreated {
+					t.Fatalf("通報: status = %d\n%s", rec.Code, rec.Body.String())
+				}
+			}
+
+			// 通報の多い順に並ぶ
+			if got, want := queueIDs(t, s, moderator), []string{reported.ID + ":2", other.ID + ":1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("キュー = %v, want %v", got, want)
+			}
+
+			path := "/api/themes/" + theme.ID + "/answers/" + reported.ID
+			rec := s.do(http.MethodPost, path+"/moderation", `{"action":"`+tt.action+`"}`, "Authorization", moderator)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			if rec.Code == http.StatusBadRequest {
+				return
+			}
+
+			// 対応した回答はキューから外れる
+			if got, want := queueIDs(t, s, moderator), []string{other.ID + ":1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("対応後のキュー = %v, want %v", got, want)
+			}
+
+			stored, err := s.store.GetAnswer(reported.ID, theme.ID)
+			if tt.wantGone {
+				if err != data.ErrNotFound {
+					t.Errorf("削除した回答を取得できました: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Moderation != tt.wantState {
+				t.Errorf("moderation = %q, want %q", stored.Moderation, tt.wantState)
+			}
+
+			// 一般のユーザーには公開中の回答だけが見える
+			wantCode := http.StatusOK
+			if tt.wantState != data.ModerationNone {
+				wantCode = http.StatusNotFound
+			}
+			if rec := s.do(http.MethodGet, path, ""); rec.Code != wantCode {
+				t.Errorf("一般のユーザーの取得: status = %d, want %d", rec.Code, wantCode)
+			}
+			var answers []data.Answer
+			decodeEnvelope(t, s.do(http.MethodGet, "/api/themes/"+theme.ID+"/answers", ""), &answers)
+			if visible := len(answers) == 2; visible != (tt.wantState == data.ModerationNone) {
+				t.Errorf("一覧の件数 = %d", len(answers))
+			}
+		})
+	}
+}
+
+// TestModerationQueueReview はNGワードで確認待ちになった回答が、通報が無くてもキューに入ることを確認する
+func TestModerationQueueReview(t *testing.T) {
+	s := newTestServer(t)
+	moderator := s.login("mod", data.RoleModerator)
+	theme := s.createTheme("お題")
+	review := &data.Answer{ThemeID: theme.ID, Content: "確認待ちの回答", Moderation: data.ModerationReview}
+	if err := s.store.CreateAnswer(review); err != nil {
+		t.Fatal(err)
+	}
+	s.createAnswer(theme.ID, "公開中の回答")
+
+	if got, want := queueIDs(t, s, moderator), []string{review.ID + ":0"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("キュー = %v, want %v", got, want)
+	}
+	if rec := s.do(http.MethodGet, "/api/moderation/queue", "", "Authorization", s.login("player", data.RolePlayer)); rec.Code != http.StatusForbidden {
+		t.Errorf("playerのキューの取得: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// TestRoles は役割ごとに許可する操作を確認する
+// 未ログインで権限が無ければ401、ログイン済みで権限が無ければ403
+// path の {own}, {other}, {someone} は、自分の回答・他人の回答・他のユーザーのIDに置き換える
+func TestRoles(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   data.Role // 空なら未ログイン
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"未ログインはお題を更新できない", "", http.MethodPut, "/api/themes/{theme}", `{"title":"更新","active":false}`, http.StatusUnauthorized},
+		{"playerはお題を更新できない", data.RolePlayer, http.MethodPut, "/api/themes/{theme}", `{"title":"更新","active":false}`, http.StatusForbidden},
+		{"未ログインでもお題を投稿できる（審査待ち）", "", http.MethodPost, "/api/themes", `{"title":"新しいお題"}`, http.StatusCreated},
+		{"playerはお題を投稿できる（審査待ち）", data.RolePlayer, http.MethodPost, "/api/themes", `{"title":"新しいお題"}`, http.StatusCreated},
+		{"playerはお題を承認できない", data.RolePlayer, http.MethodPost, "/api/admin/themes/{theme}/approve", "", http.StatusForbidden},
+		{"moderatorはお題を削除できない", data.RoleModerator, http.MethodDelete, "/api/themes/{theme}", "", http.StatusForbidden},
+		{"adminはお題を作成できる", data.RoleAdmin, http.MethodPost, "/api/themes", `{"title":"新しいお題"}`, http.StatusCreated},
+		{"adminはお題を削除できる", data.RoleAdmin, http.MethodDelete, "/api/themes/{theme}", "", http.StatusNoContent},
+		{"未ログインは回答を投稿できる", "", http.MethodPost, "/api/themes/{theme}/answers", `{"content":"回答"}`, http.StatusCreated},
+		{"playerは自分の回答を編集できる", data.RolePlayer, http.MethodPut, "/api/themes/{theme}/answers/{own}", `{"content":"編集"}`, http.StatusOK},
+		{"playerは他人の回答を編集できない", data.RolePlayer, http.MethodPut, "/api/themes/{theme}/answers/{other}", `{"content":"編集"}`, http.StatusForbidden},
+		{"playerは他人の回答を削除できない", data.RolePlayer, http.MethodDelete, "/api/themes/{theme}/answers/{other}", "", http.StatusForbidden},
+		{"playerは自分の回答を削除できる", data.RolePlayer, http.MethodDelete, "/api/themes/{theme}/answers/{own}", "", http.StatusNoContent},
+		{"playerは回答を非表示にできない", data.RolePlayer, http.MethodPost, "/api/themes/{theme}/answers/{other}/moderation", `{"action":"hide"}`, http.StatusForbidden},
+		{"moderatorは回答を非表示にできる", data.RoleModerator, http.MethodPost, "/api/themes/{theme}/answers/{other}/moderation", `{"action":"hide"}`, http.StatusOK},
+		{"moderatorは他人の回答を削除できる", data.RoleModerator, http.MethodDelete, "/api/themes/{theme}/answers/{other}", "", http.StatusNoContent},
+		{"moderatorは役割を変更できない", data.RoleModerator, http.MethodPut, "/api/users/{someone}/role", `{"role":"admin"}`, http.StatusForbidden},
+		{"adminは役割を変更できる", data.RoleAdmin, http.MethodPut, "/api/users/{someone}/role", `{"role":"moderator"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.login("someone", data.RolePlayer)
+			var headers []string
+			if tt.role != "" {
+				headers = []string{"Authorization", s.login("me", tt.role)}
+			}
+			someone, err := s.store.GetUserByUsername("someone")
+			if err != nil {
+				t.Fatal(err)
+			}
+			theme := s.createTheme("お題")
+			other := s.createAnswer(theme.ID, "他人の回答")
+			// 自分の回答は、ログインしているユーザーの回答として作成する
+			own := &data.Answer{ThemeID: theme.ID, Content: "自分の回答"}
+			if me, err := s.store.GetUserByUsername("me"); err == nil {
+				own.UserID = me.ID
+			}
+			if err := s.store.CreateAnswer(own); err != nil {
+				t.Fatal(err)
+			}
+
+			path := strings.NewReplacer("{theme}", theme.ID, "{own}", own.ID, "{other}", other.ID, "{someone}", someone.ID).Replace(tt.path)
+			rec := s.do(tt.method, path, tt.body, headers...)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestUpdateUserRole は最後の管理者を降格できないことを確認する
+func TestUpdateUserRole(t *testing.T) {
+	s := newTestServer(t)
+	root := s.login("root", data.RoleAdmin)
+	rootUser, err := s.store.GetUserByUsername("root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rolePath := func(id string) string { return "/api/users/" + id + "/role" }
+
+	// 管理者が自分しかいなければ、自分を降格できない
+	rec := s.do(http.MethodPut, rolePath(rootUser.ID), `{"role":"player"}`, "Authorization", root)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("唯一の管理者の降格: status = %d, want %d\n%s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+
+	// 管理者が2人いれば、もう一人を降格できる
+	s.login("second", data.RoleAdmin)
+	second, err := s.store.GetUserByUsername("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		id   string
+		role string
+		want int
+	}{
+		{"管理者が2人なら降格できる", second.ID, "moderator", http.StatusOK},
+		{"最後の管理者は自分でも降格できない", rootUser.ID, "moderator", http.StatusConflict},
+		{"管理者のままなら変更できる", rootUser.ID, "admin", http.StatusOK},
+		{"降格した元管理者は昇格できる", second.ID, "admin", http.StatusOK},
+		{"再び2人なら自分を降格できる", rootUser.ID, "player", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodPut, rolePath(tt.id), `{"role":"`+tt.role+`"}`, "Authorization", root)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+	if admins, err := s.store.CountUsers(data.RoleAdmin); err != nil || admins != 1 {
+		t.Errorf("管理者の数 = %d, %v, want 1", admins, err)
+	}
+}
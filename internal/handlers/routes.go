@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Routes はAPIのルーターを組み立てる
+// 認証・CORSなどのミドルウェアは呼び出し側で適用する
+func (h *Handler) Routes() *mux.Router {
+	r := mux.NewRouter()
+	// ルートが見つからない・メソッドが違う場合も共通の形式のエラーを返す
+	r.NotFoundHandler = http.HandlerFunc(NotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(MethodNotAllowed)
+
+	// ユーザー関連のエンドポイント
+	r.HandleFunc("/api/auth/signup", h.Signup).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/auth/login", h.Login).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/auth/logout", h.Logout).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/auth/me", h.Me).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/users/{id}/role", h.UpdateUserRole).Methods("PUT", "OPTIONS")
+
+	// お題関連のエンドポイント
+	r.HandleFunc("/api/themes", h.ListThemes).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/themes", h.CreateTheme).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/themes/{id}", h.GetTheme).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/themes/{id}", h.UpdateTheme).Methods("PUT", "OPTIONS")
+	r.HandleFunc("/api/themes/{id}", h.PatchTheme).Methods("PATCH", "OPTIONS")
+	r.HandleFunc("/api/themes/{id}", h.DeleteTheme).Methods("DELETE", "OPTIONS")
+
+	// お題の審査関連のエンドポイント
+	r.HandleFunc("/api/admin/themes", h.ListSubmissions).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/admin/themes/{id}/approve", h.ApproveTheme).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/admin/themes/{id}/reject", h.RejectTheme).Methods("POST", "OPTIONS")
+
+	// 回答関連のエンドポイント
+	r.HandleFunc("/api/themes/{themeID}/answers", h.ListAnswers).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/themes/{themeID}/answers", h.SubmitAnswer).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}", h.GetAnswer).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}", h.UpdateAnswer).Methods("PUT", "OPTIONS")
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}", h.PatchAnswer).Methods("PATCH", "OPTIONS")
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}", h.DeleteAnswer).Methods("DELETE", "OPTIONS")
+
+	// いいね関連のエンドポイント
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}/likes", h.LikeAnswer).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}/likes", h.UnlikeAnswer).Methods("DELETE", "OPTIONS")
+
+	// 通報・モデレーション関連のエンドポイント
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}/reports", h.ReportAnswer).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/themes/{themeID}/answers/{id}/moderation", h.ModerateAnswer).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/moderation/queue", h.ModerationQueue).Methods("GET", "OPTIONS")
+
+	// お題・回答のエクスポート・インポートのエンドポイント
+	r.HandleFunc("/api/admin/export", h.ExportData).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/admin/import", h.ImportData).Methods("POST", "OPTIONS")
+
+	// ランキング関連のエンドポイント
+	r.HandleFunc("/api/themes/{themeID}/ranking", h.ThemeRanking).Methods("GET", "OPTIONS")
+	r.HandleFunc("/api/leaderboard", h.Leaderboard).Methods("GET", "OPTIONS")
+
+	// リアルタイム配信のエンドポイント
+	r.HandleFunc("/api/themes/{themeID}/ws", h.AnswerFeed).Methods("GET")
+	r.HandleFunc("/api/events", h.EventStream).Methods("GET")
+
+	// APIの仕様（OpenAPI 3）
+	r.HandleFunc("/api/openapi.json", h.OpenAPI).Methods("GET", "OPTIONS")
+
+	return r
+}
+
+// OpsRoutes は死活監視・状態確認のルーターを組み立てる
+// ロードバランサーや監視から呼ばれるため、/api/ のCORS・認証・レート制限を通さずに登録する
+// metrics には /metrics で返すハンドラーを渡す
+func (h *Handler) OpsRoutes(metrics http.Handler) *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/healthz", h.Healthz).Methods("GET", "HEAD")
+	r.HandleFunc("/readyz", h.Readyz).Methods("GET", "HEAD")
+	r.HandleFunc("/version", h.Version).Methods("GET", "HEAD")
+	r.Handle("/metrics", metrics).Methods("GET")
+	return r
+}
+
+// Use はルーターにミドルウェアを適用する
+// mux の Use はルートが見つからない・メソッドが違う場合のハンドラーには適用されないため、
+// リクエストID・ログ・メトリクスが抜けないよう、それらのハンドラーも同じ順に包む
+func Use(r *mux.Router, middlewares ...mux.MiddlewareFunc) {
+	r.Use(middlewares...)
+	for _, handler := range []*http.Handler{&r.NotFoundHandler, &r.MethodNotAllowedHandler} {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			*handler = middlewares[i](*handler)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/logging"
+	"github.com/nicest414/ogiri-server/internal/realtime"
+)
+
+// TestUseWrapsErrorHandlers はルートが見つからない・メソッドが違う場合にもミドルウェアを通すことを確認する
+func TestUseWrapsErrorHandlers(t *testing.T) {
+	api := NewHandler(data.NewInMemoryStore(), realtime.NewHub(), nil, nil).Routes()
+	var seen []string
+	counter := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	}
+	Use(api, counter, logging.Middleware)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"ルートがある", http.MethodGet, "/api/themes", http.StatusOK},
+		{"ルートが無い", http.MethodGet, "/api/none", http.StatusNotFound},
+		{"メソッドが違う", http.MethodPatch, "/api/themes", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		seen = nil
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set(logging.RequestIDHeader, "req-1")
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if len(seen) != 1 {
+			t.Errorf("%s: ミドルウェアを通った回数 = %d, want 1", tt.name, len(seen))
+		}
+		if got := rec.Header().Get(logging.RequestIDHeader); got != "req-1" {
+			t.Errorf("%s: %s = %q, want req-1", tt.name, logging.RequestIDHeader, got)
+		}
+		if tt.want != http.StatusOK {
+			var body struct {
+				RequestID string `json:"request_id"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.RequestID != "req-1" {
+				t.Errorf("%s: request_id = %q, want req-1\n%s", tt.name, body.RequestID, rec.Body.String())
+			}
+		}
+	}
+}
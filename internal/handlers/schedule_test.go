@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// TestCreateThemeActive は作成時に指定した active を使い、省略時は受付期間から決めることを確認する
+func TestCreateThemeActive(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"省略時は受付中", `{"title":"お題"}`, true},
+		{"受付停止で作成できる", `{"title":"お題","active":false}`, false},
+		{"省略時は開始前なら受付しない", `{"title":"お題","opens_at":"` + future + `"}`, false},
+		{"開始前でも受付中で作成できる", `{"title":"お題","active":true,"opens_at":"` + future + `"}`, true},
+		{"省略時は終了後なら受付しない", `{"title":"お題","closes_at":"` + past + `"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := s.login("admin", data.RoleAdmin)
+
+			rec := s.do(http.MethodPost, "/api/themes", tt.body, "Authorization", admin)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, http.StatusCreated, rec.Body.String())
+			}
+			var theme data.Theme
+			decodeEnvelope(t, rec, &theme)
+			if theme.Active != tt.want {
+				t.Errorf("active = %v, want %v", theme.Active, tt.want)
+			}
+			stored, err := s.store.GetTheme(theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Active != tt.want {
+				t.Errorf("保存された active = %v, want %v", stored.Active, tt.want)
+			}
+		})
+	}
+}
+
+// TestSubmitAnswerWindow は受付期間外の回答を拒否することを確認する
+func TestSubmitAnswerWindow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		opensAt  *time.Time
+		closesAt *time.Time
+		want     int
+	}{
+		{"受付期間内", ptrTime(now.Add(-time.Hour)), ptrTime(now.Add(time.Hour)), http.StatusCreated},
+		{"開始前", ptrTime(now.Add(time.Hour)), nil, http.StatusBadRequest},
+		{"終了後", nil, ptrTime(now.Add(-time.Hour)), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			// Active はスケジューラーの反映前の状態として受付中にしておく
+			theme := &data.Theme{Title: "お題", Active: true, OpensAt: tt.opensAt, ClosesAt: tt.closesAt}
+			if err := s.store.CreateTheme(theme); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := s.do(http.MethodPost, "/api/themes/"+theme.ID+"/answers", `{"content":"回答"}`)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
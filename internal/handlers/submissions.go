@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// maxRejectionReasonLength は却下理由の最大文字数
+const maxRejectionReasonLength = 500
+
+// ListSubmissions は投稿されたお題を審査状態で絞り込んで取得（admin）
+// submission_status を省略すると審査待ちのお題を返す
+func (h *Handler) ListSubmissions(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermApproveTheme, "") {
+		return
+	}
+
+	opts, qerr := parseListOptions(r, data.SortByCreatedAt, data.SortByUpdatedAt)
+	if qerr != nil {
+		sendError(w, r, qerr)
+		return
+	}
+	switch v := r.URL.Query().Get("submission_status"); v {
+	case "":
+		opts.Submission = []data.SubmissionStatus{data.SubmissionPending}
+	case "all":
+		opts.Submission = data.AllSubmissionStatuses
+	default:
+		for _, s := range strings.Split(v, ",") {
+			status := data.SubmissionStatus(s)
+			if !status.Valid() {
+				sendError(w, r, queryError("submission_status", "submission_status には pending, approved, rejected, all のいずれかを指定してください"))
+				return
+			}
+			opts.Submission = append(opts.Submission, status)
+		}
+	}
+
+	themes, nextCursor, err := h.store.ListThemes(opts)
+	if err == data.ErrInvalidCursor {
+		sendError(w, r, errInvalidCursor)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "お題の取得に失敗しました", err)
+		return
+	}
+
+	response.WritePage(w, r, "投稿されたお題の取得に成功しました", themes, nextCursor)
+}
+
+// ApproveTheme は投稿されたお題を承認して公開する（admin）
+func (h *Handler) ApproveTheme(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermApproveTheme, "") {
+		return
+	}
+	h.review(w, r, data.SubmissionApproved, "")
+}
+
+// RejectTheme は投稿されたお題を理由を付けて却下する（admin）
+func (h *Handler) RejectTheme(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermApproveTheme, "") {
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	errs, ok := decodeBody(w, r, &req)
+	if !ok {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if !errs.Has("reason") {
+		if req.Reason == "" {
+			errs.Add("reason", response.CodeRequired, "却下の理由は必須です")
+		} else if utf8.RuneCountInString(req.Reason) > maxRejectionReasonLength {
+			errs.Add("reason", response.CodeTooLong, fmt.Sprintf("却下の理由は%d文字以内で入力してください", maxRejectionReasonLength))
+		}
+	}
+	if verr := errs.Err(); verr != nil {
+		sendError(w, r, verr)
+		return
+	}
+
+	h.review(w, r, data.SubmissionRejected, req.Reason)
+}
+
+// review はお題の審査状態を変更する
+// 承認したお題は受付期間内なら受付を開始し、却下したお題は受付を停止する
+func (h *Handler) review(w http.ResponseWriter, r *http.Request, status data.SubmissionStatus, reason string) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	theme, err := h.store.GetTheme(id)
+	if err == data.ErrNotFound {
+		sendError(w, r, errThemeNotFound)
+		return
+	}
+	if err != nil {
+		sendServerError(w, r, "お題の取得に失敗しました", err)
+		return
+	}
+
+	// ストアが返すポインタは共有されている場合があるため、コピーを更新する
+	updated := *theme
+	now := time.Now()
+	updated.SubmissionStatus = status
+	updated.RejectionReason = reason
+	updated.Active = status == data.SubmissionApproved && updated.InWindow(now)
+	updated.UpdatedAt = now
+
+	if err := h.store.UpdateTheme(&updated); err == data.ErrVersionConflict {
+		sendError(w, r, conflictError(err))
+		return
+	} else if err != nil {
+		sendServerError(w, r, "お題の更新に失敗しました", err)
+		return
+	}
+
+	message := "お題を承認しました"
+	if status == data.SubmissionRejected {
+		message = "お題を却下しました"
+	}
+	setETag(w, updated.Version)
+	response.Write(w, http.StatusOK, message, &updated)
+}
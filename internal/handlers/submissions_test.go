@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/moderation"
+)
+
+// submitTheme は player としてお題を投稿し、作成されたお題を返す
+func submitTheme(t *testing.T, s *testServer, player, title string) *data.Theme {
+	t.Helper()
+	rec := s.do(http.MethodPost, "/api/themes", `{"title":"`+title+`","active":true}`, "Authorization", player)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("お題の投稿: status = %d\n%s", rec.Code, rec.Body.String())
+	}
+	var theme data.Theme
+	decodeEnvelope(t, rec, &theme)
+	return &theme
+}
+
+// themeIDs はお題の一覧を取得してIDを返す
+func themeIDs(t *testing.T, s *testServer, path string, headers ...string) []string {
+	t.Helper()
+	rec := s.do(http.MethodGet, path, "", headers...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status = %d\n%s", path, rec.Code, rec.Body.String())
+	}
+	var themes []data.Theme
+	decodeEnvelope(t, rec, &themes)
+	ids := make([]string, len(themes))
+	for i, theme := range themes {
+		ids[i] = theme.ID
+	}
+	return ids
+}
+
+// TestThemeSubmission は投稿されたお題が審査待ちになり、承認されるまで公開されないことを確認する
+func TestThemeSubmission(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login("admin", data.RoleAdmin)
+	player := s.login("player", data.RolePlayer)
+
+	// active を指定しても審査待ちで受付しない
+	submitted := submitTheme(t, s, player, "投稿されたお題")
+	if submitted.SubmissionStatus != data.SubmissionPending || submitted.Active {
+		t.Fatalf("投稿直後: submission_status = %s, active = %v", submitted.SubmissionStatus, submitted.Active)
+	}
+	rejected := submitTheme(t, s, player, "却下されるお題")
+
+	if got := themeIDs(t, s, "/api/themes"); len(got) != 0 {
+		t.Errorf("承認前の一覧 = %v", got)
+	}
+	if got := themeIDs(t, s, "/api/admin/themes", "Authorization", admin); len(got) != 2 {
+		t.Errorf("審査待ちの一覧 = %v", got)
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"投稿者以外は審査待ちのお題を取得できない", "", http.MethodGet, "/api/themes/" + submitted.ID, "", http.StatusNotFound},
+		{"投稿者は審査待ちのお題を取得できる", player, http.MethodGet, "/api/themes/" + submitted.ID, "", http.StatusOK},
+		{"審査待ちのお題には回答できない", "", http.MethodPost, "/api/themes/" + submitted.ID + "/answers", `{"content":"回答"}`, http.StatusNotFound},
+		{"playerは一覧を取得できない", player, http.MethodGet, "/api/admin/themes", "", http.StatusForbidden},
+		{"playerは承認できない", player, http.MethodPost, "/api/admin/themes/" + submitted.ID + "/approve", "", http.StatusForbidden},
+		{"却下には理由が必要", admin, http.MethodPost, "/api/admin/themes/" + rejected.ID + "/reject", `{"reason":" "}`, http.StatusBadRequest},
+		{"存在しないお題", admin, http.MethodPost, "/api/admin/themes/none/approve", "", http.StatusNotFound},
+		{"adminは承認できる", admin, http.MethodPost, "/api/admin/themes/" + submitted.ID + "/approve", "", http.StatusOK},
+		{"adminは却下できる", admin, http.MethodPost, "/api/admin/themes/" + rejected.ID + "/reject", `{"reason":"重複しています"}`, http.StatusOK},
+		{"承認したお題には回答できる", "", http.MethodPost, "/api/themes/" + submitted.ID + "/answers", `{"content":"回答"}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		var headers []string
+		if tt.user != "" {
+			headers = []string{"Authorization", tt.user}
+		}
+		if rec := s.do(tt.method, tt.path, tt.body, headers...); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d\n%s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	if got := themeIDs(t, s, "/api/themes"); len(got) != 1 || got[0] != submitted.ID {
+		t.Errorf("承認後の一覧 = %v, want [%s]", got, submitted.ID)
+	}
+	if got := themeIDs(t, s, "/api/admin/themes?submission_status=rejected", "Authorization", admin); len(got) != 1 || got[0] != rejected.ID {
+		t.Errorf("却下の一覧 = %v, want [%s]", got, rejected.ID)
+	}
+	stored, err := s.store.GetTheme(rejected.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Active || stored.RejectionReason != "重複しています" {
+		t.Errorf("却下したお題: active = %v, rejection_reason = %q", stored.Active, stored.RejectionReason)
+	}
+}
+
+// TestModerateTheme は admin が作成したお題でも、NGワードを含めば審査待ちにすることを確認する
+func TestModerateTheme(t *testing.T) {
+	s := newModeratedServer(t, moderation.ActionReview, "ばか")
+	admin := s.login("admin", data.RoleAdmin)
+
+	tests := []struct {
+		title      string
+		wantStatus data.SubmissionStatus
+	}{
+		{"普通のお題", data.SubmissionApproved},
+		{"ばかなお題", data.SubmissionPending},
+	}
+	for _, tt := range tests {
+		theme := submitTheme(t, s, admin, tt.title)
+		if theme.SubmissionStatus != tt.wantStatus || theme.Active != (tt.wantStatus == data.SubmissionApproved) {
+			t.Errorf("%s: submission_status = %s, active = %v", tt.title, theme.SubmissionStatus, theme.Active)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/httputil"
+	"github.com/nicest414/ogiri-server/internal/logging"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"github.com/nicest414/ogiri-server/internal/transfer"
+)
+
+// maxImportBytes はインポートするファイルの最大バイト数
+const maxImportBytes = 32 << 20
+
+// parseFormat はクエリパラメータ format を読む（省略すると JSON Lines）
+func parseFormat(r *http.Request) (transfer.Format, *response.Error) {
+	format := transfer.Format(r.URL.Query().Get("format"))
+	if format == "" {
+		return transfer.FormatJSONL, nil
+	}
+	if !format.Valid() {
+		return "", queryError("format", "format には jsonl または csv を指定してください")
+	}
+	return format, nil
+}
+
+// ExportData はお題と回答を JSON Lines または CSV のファイルとして返す（admin）
+// theme_id（カンマ区切り）、created_after・created_before でお題を絞り込める
+func (h *Handler) ExportData(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermTransferData, "") {
+		return
+	}
+
+	format, qerr := parseFormat(r)
+	if qerr != nil {
+		sendError(w, r, qerr)
+		return
+	}
+	q := r.URL.Query()
+	var filter transfer.Filter
+	for _, v := range q["theme_id"] {
+		filter.ThemeIDs = append(filter.ThemeIDs, httputil.SplitList(v)...)
+	}
+	for name, dst := range map[string]*time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				sendError(w, r, queryError(name, fmt.Sprintf("%s はRFC3339形式（例: 2024-01-02T15:04:05+09:00）で指定してください", name)))
+				return
+			}
+			*dst = t
+		}
+	}
+
+	filename := fmt.Sprintf("ogiri-%s.%s", time.Now().Format("20060102-150405"), format)
+	out := &exportWriter{w: w, start: func() {
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+	}}
+	counts, err := transfer.Export(h.store, out, format, filter)
+	switch {
+	case err != nil && out.started:
+		// ステータスコードは送ってしまったので、途中までのファイルを完全なものと誤解されないよう接続を切る
+		logging.FromContext(r.Context()).Error("エクスポートの途中で失敗したため接続を切ります", "error", err, "themes", counts.Themes, "answers", counts.Answers)
+		panic(http.ErrAbortHandler)
+	case errors.Is(err, data.ErrNotFound):
+		sendError(w, r, errThemeNotFound)
+		return
+	case err != nil:
+		sendServerError(w, r, "エクスポートに失敗しました", err)
+		return
+	}
+	// 1件も無い JSON Lines は何も書き込まないので、ここでヘッダーを送る
+	out.begin()
+	logging.FromContext(r.Context()).Info("お題と回答をエクスポートしました", "themes", counts.Themes, "answers", counts.Answers)
+}
+
+// exportWriter は最初に書き込むときにヘッダーを送る
+// バッファに溜めずに書き出しながら、書き出し始める前のエラーは通常のエラーレスポンスで返せるようにする
+type exportWriter struct {
+	w       http.ResponseWriter
+	start   func()
+	started bool
+}
+
+func (e *exportWriter) begin() {
+	if !e.started {
+		e.started = true
+		e.start()
+	}
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	e.begin()
+	return e.w.Write(p)
+}
+
+// ImportData はリクエストボディのファイルのお題と回答を読み込む（admin）
+// 全ての行を検査し、問題があれば何も保存せずに行ごとの内容を返す
+// ids=regenerate ならIDを振り直し、dry_run=true なら検査だけを行う
+func (h *Handler) ImportData(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.PermTransferData, "") {
+		return
+	}
+
+	format, qerr := parseFormat(r)
+	if qerr != nil {
+		sendError(w, r, qerr)
+		return
+	}
+	q := r.URL.Query()
+	opts := transfer.Options{Format: format}
+	switch q.Get("ids") {
+	case "", "preserve":
+	case "regenerate":
+		opts.RegenerateIDs = true
+	default:
+		sendError(w, r, queryError("ids", "ids には preserve または regenerate を指定してください"))
+		return
+	}
+	switch q.Get("dry_run") {
+	case "", "false":
+	case "true":
+		opts.DryRun = true
+	default:
+		sendError(w, r, queryError("dry_run", "dry_run には true または false を指定してください"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		sendErrorResponse(w, r, http.StatusRequestEntityTooLarge, response.CodeBodyTooLarge,
+			fmt.Sprintf("インポートするファイルは%dMiB以内にしてください", maxImportBytes>>20))
+		return
+	}
+	if err != nil {
+		sendError(w, r, errInvalidJSON)
+		return
+	}
+
+	result, err := transfer.Import(h.store, bytes.NewReader(body), opts)
+	if err != nil {
+		sendServerError(w, r, "インポートの保存に失敗しました（何も保存していません）", err)
+		return
+	}
+	if len(result.Errors) > 0 {
+		verr := result.Errors.Err()
+		verr.Message = fmt.Sprintf("ファイルに%d件の問題があるため、何も保存していません", len(result.Errors))
+		sendError(w, r, verr)
+		return
+	}
+
+	message := "インポートしました"
+	if opts.DryRun {
+		message = "問題は見つかりませんでした（dry_run のため保存していません）"
+	}
+	response.WriteData(w, r, http.StatusOK, message, result)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// TestImport はインポートの検査と保存を確認する
+// 既存のお題 theme_1 に回答 answer_1 がある状態で読み込む
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		lines []string
+		want  int
+		// wantErrors は問題の行番号と項目、wantSaved は保存されているはずの回答
+		wantErrors []string
+		wantSaved  []string
+	}{
+		{
+			name:  "dry_run・問題なし",
+			query: "?dry_run=true",
+			lines: []string{
+				`{"type":"theme","id":"t2","title":"お題"}`,
+				`{"type":"answer","id":"a2","theme_id":"t2","content":"回答"}`,
+			},
+			want: http.StatusOK,
+		},
+		{
+			name:  "dry_run・別のお題にある回答のID",
+			query: "?dry_run=true",
+			lines: []string{
+				`{"type":"theme","id":"t2","title":"お題"}`,
+				`{"type":"answer","id":"answer_1","theme_id":"t2","content":"回答"}`,
+			},
+			want:       http.StatusBadRequest,
+			wantErrors: []string{"2:id:DUPLICATE_ID"},
+		},
+		{
+			name: "既にあるお題のID",
+			lines: []string{
+				`{"type":"answer","id":"a2","theme_id":"theme_1","content":"回答"}`,
+				`{"type":"theme","id":"theme_1","title":"お題"}`,
+			},
+			want:       http.StatusBadRequest,
+			wantErrors: []string{"2:id:DUPLICATE_ID"},
+		},
+		{
+			name: "前の行と重なる回答のID",
+			lines: []string{
+				`{"type":"answer","id":"a2","theme_id":"theme_1","content":"回答"}`,
+				`{"type":"answer","id":"a2","theme_id":"theme_1","content":"回答"}`,
+			},
+			want:       http.StatusBadRequest,
+			wantErrors: []string{"2:id:DUPLICATE_ID"},
+		},
+		{
+			name: "保存",
+			lines: []string{
+				`{"type":"theme","id":"t2","title":"お題"}`,
+				`{"type":"answer","id":"a2","theme_id":"t2","content":"回答"}`,
+				`{"type":"answer","id":"a3","theme_id":"theme_1","content":"回答"}`,
+			},
+			want:      http.StatusOK,
+			wantSaved: []string{"t2/a2", "theme_1/a3"},
+		},
+		{
+			name:  "IDを振り直す",
+			query: "?ids=regenerate",
+			lines: []string{
+				`{"type":"theme","id":"theme_1","title":"お題"}`,
+				`{"type":"answer","id":"answer_1","theme_id":"theme_1","content":"回答"}`,
+			},
+			want: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := s.login("admin", data.RoleAdmin)
+			theme := s.createTheme("お題")
+			s.createAnswer(theme.ID, "回答")
+
+			rec := s.do(http.MethodPost, "/api/admin/import"+tt.query, strings.Join(tt.lines, "\n"), "Authorization", admin)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			env := decodeEnvelope(t, rec, nil)
+			var got []string
+			if env.Error != nil {
+				for _, d := range env.Error.Details {
+					got = append(got, fmt.Sprintf("%d:%s:%s", d.Line, d.Field, d.Code))
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantErrors, ",") {
+				t.Errorf("errors = %v, want %v", got, tt.wantErrors)
+			}
+			for _, saved := range tt.wantSaved {
+				ids := strings.SplitN(saved, "/", 2)
+				if _, err := s.store.GetAnswer(ids[1], ids[0]); err != nil {
+					t.Errorf("回答 %s が保存されていません: %v", saved, err)
+				}
+			}
+			if tt.want != http.StatusOK || tt.query == "?dry_run=true" {
+				if _, err := s.store.GetTheme("t2"); err != data.ErrNotFound {
+					t.Errorf("保存しないはずのお題が保存されています")
+				}
+			}
+		})
+	}
+}
+
+// checkedStore は回答のID after を確認した直後に interleave を呼び、
+// インポートの検査から保存までの間に他の操作が入った状態を再現する
+type checkedStore struct {
+	data.DataStore
+	after      string
+	interleave func()
+}
+
+func (s *checkedStore) AnswerExists(id string) (bool, error) {
+	exists, err := s.DataStore.AnswerExists(id)
+	if id == s.after {
+		s.interleave()
+	}
+	return exists, err
+}
+
+// TestImportIsAtomic は保存に失敗したインポートが何も残さないことを確認する
+func TestImportIsAtomic(t *testing.T) {
+	memory := data.NewInMemoryStore()
+	store := &checkedStore{DataStore: memory}
+	s := newTestServerWith(t, store)
+	admin := s.login("admin", data.RoleAdmin)
+	theme := s.createTheme("お題")
+	// 最後の行の検査の後、その行と同じIDの回答が作られる（ストアが最初に振る回答のID）
+	store.after = "answer_1"
+	store.interleave = func() { s.createAnswer(theme.ID, "割り込み") }
+
+	body := strings.Join([]string{
+		`{"type":"answer","id":"a2","theme_id":"` + theme.ID + `","content":"回答"}`,
+		`{"type":"answer","id":"answer_1","theme_id":"` + theme.ID + `","content":"回答"}`,
+	}, "\n")
+	rec := s.do(http.MethodPost, "/api/admin/import", body, "Authorization", admin)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500\n%s", rec.Code, rec.Body.String())
+	}
+	if exists, _ := memory.AnswerExists("a2"); exists {
+		t.Error("失敗したインポートの1件目が保存されています")
+	}
+}
+
+// failingAnswersStore はお題 fail の回答の取得に失敗する
+type failingAnswersStore struct {
+	data.DataStore
+}
+
+func (s *failingAnswersStore) ListAnswers(themeID string, opts data.ListOptions) ([]*data.Answer, string, error) {
+	if themeID == "fail" {
+		return nil, "", errors.New("読み込みに失敗しました")
+	}
+	return s.DataStore.ListAnswers(themeID, opts)
+}
+
+// TestExport はエクスポートを書き出しながら返し、書き出し始めた後に失敗したら接続を切ることを確認する
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		want      int
+		wantAbort bool
+	}{
+		{"JSON Lines", "", http.StatusOK, false},
+		{"CSV", "?format=csv", http.StatusOK, false},
+		{"1件も無い", "?created_after=2999-01-01T00:00:00Z", http.StatusOK, false},
+		{"存在しないお題", "?theme_id=none", http.StatusNotFound, false},
+		// CSV はバッファが一杯になるまで書き込まない
+		{"書き出し始める前の失敗", "?theme_id=fail&format=csv", http.StatusInternalServerError, false},
+		{"書き出し始めた後の失敗", "?theme_id=theme_1,fail", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &failingAnswersStore{DataStore: data.NewInMemoryStore()}
+			s := newTestServerWith(t, store)
+			admin := s.login("admin", data.RoleAdmin)
+			theme := s.createTheme("お題")
+			s.createAnswer(theme.ID, "回答")
+			if err := store.Import(&data.ImportBatch{Themes: []*data.Theme{{ID: "fail", Title: "失敗"}}}); err != nil {
+				t.Fatal(err)
+			}
+
+			var rec *httptest.ResponseRecorder
+			aborted := func() (aborted bool) {
+				defer func() {
+					if p := recover(); p != nil {
+						if p != http.ErrAbortHandler {
+							panic(p)
+						}
+						aborted = true
+					}
+				}()
+				rec = s.do(http.MethodGet, "/api/admin/export"+tt.query, "", "Authorization", admin)
+				return false
+			}()
+			if aborted != tt.wantAbort {
+				t.Fatalf("aborted = %v, want %v", aborted, tt.wantAbort)
+			}
+			if aborted {
+				return
+			}
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			if tt.want == http.StatusOK && !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment;") {
+				t.Errorf("Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
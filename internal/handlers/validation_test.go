@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// TestStrictBody は全てのJSONのリクエストボディを大きさを制限して厳密に読み込み、
+// 項目の問題をまとめて返すことを確認する
+// path の {theme}, {answer}, {admin} は、作成したお題・回答・管理者のIDに置き換える
+func TestStrictBody(t *testing.T) {
+	tooLarge := `{"content":"` + strings.Repeat("a", maxBodyBytes) + `"}`
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+		// wantDetails は "項目:コード" の並び（順不同）
+		wantDetails []string
+	}{
+		{"お題・全ての問題", http.MethodPost, "/api/themes", `{"title":"  ","description":"` + strings.Repeat("あ", 1001) + `","id":"x","opens_at":"昨日"}`, http.StatusBadRequest,
+			[]string{"id:UNKNOWN_FIELD", "opens_at:INVALID_VALUE", "title:BLANK", "description:TOO_LONG"}},
+		{"回答・制御文字", http.MethodPost, "/api/themes/{theme}/answers", `{"content":"a\u0000b","likes":3}`, http.StatusBadRequest,
+			[]string{"likes:UNKNOWN_FIELD", "content:CONTROL_CHARACTER"}},
+		{"回答・大きすぎる", http.MethodPost, "/api/themes/{theme}/answers", tooLarge, http.StatusRequestEntityTooLarge, nil},
+		{"JSONでない", http.MethodPost, "/api/themes/{theme}/answers", `{"content":`, http.StatusBadRequest, nil},
+		{"サインアップ・全ての問題", http.MethodPost, "/api/auth/signup", `{"username":"a b","password":"short","role":"admin"}`, http.StatusBadRequest,
+			[]string{"role:UNKNOWN_FIELD", "username:INVALID_VALUE", "password:TOO_SHORT"}},
+		{"サインアップ・大きすぎる", http.MethodPost, "/api/auth/signup", `{"username":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, nil},
+		{"ログイン・受け付けていない項目", http.MethodPost, "/api/auth/login", `{"username":"admin","password":"password","remember":true}`, http.StatusBadRequest,
+			[]string{"remember:UNKNOWN_FIELD"}},
+		{"ログイン・型が違う", http.MethodPost, "/api/auth/login", `{"username":1,"password":"password"}`, http.StatusBadRequest,
+			[]string{"username:INVALID_VALUE"}},
+		{"役割・値と項目", http.MethodPut, "/api/users/{admin}/role", `{"role":"owner","user":"x"}`, http.StatusBadRequest,
+			[]string{"user:UNKNOWN_FIELD", "role:INVALID_VALUE"}},
+		{"通報・全ての問題", http.MethodPost, "/api/themes/{theme}/answers/{answer}/reports", `{"reason":"boring","comment":"` + strings.Repeat("あ", 501) + `","extra":1}`, http.StatusBadRequest,
+			[]string{"extra:UNKNOWN_FIELD", "reason:INVALID_VALUE", "comment:TOO_LONG"}},
+		{"通報・大きすぎる", http.MethodPost, "/api/themes/{theme}/answers/{answer}/reports", `{"comment":"` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, nil},
+		{"モデレーション・受け付けていない項目", http.MethodPost, "/api/themes/{theme}/answers/{answer}/moderation", `{"action":"hide","reason":"x"}`, http.StatusBadRequest,
+			[]string{"reason:UNKNOWN_FIELD"}},
+		{"却下・理由が無い", http.MethodPost, "/api/admin/themes/{theme}/reject", `{"reason":" ","notify":true}`, http.StatusBadRequest,
+			[]string{"notify:UNKNOWN_FIELD", "reason:REQUIRED"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := s.login("admin", data.RoleAdmin)
+			adminUser, err := s.store.GetUserByUsername("admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			theme := s.createTheme("お題")
+			answer := s.createAnswer(theme.ID, "回答")
+
+			path := strings.NewReplacer("{theme}", theme.ID, "{answer}", answer.ID, "{admin}", adminUser.ID).Replace(tt.path)
+			rec := s.do(tt.method, path, tt.body, "Authorization", admin, "X-Client-Token", "client-1")
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d\n%s", rec.Code, tt.want, rec.Body.String())
+			}
+			env := decodeEnvelope(t, rec, nil)
+			got := make(map[string]bool)
+			if env.Error != nil {
+				for _, d := range env.Error.Details {
+					got[fmt.Sprintf("%s:%s", d.Field, d.Code)] = true
+				}
+			}
+			for _, want := range tt.wantDetails {
+				if !got[want] {
+					t.Errorf("details に %s がありません\n%s", want, rec.Body.String())
+				}
+			}
+			if len(got) != len(tt.wantDetails) {
+				t.Errorf("details の数 = %d, want %d\n%s", len(got), len(tt.wantDetails), rec.Body.String())
+			}
+		})
+	}
+}
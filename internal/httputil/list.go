@@ -0,0 +1,15 @@
+package httputil
+
+import "strings"
+
+// SplitList はカンマ区切りの値を分割する（前後の空白を除き、空の要素は除く）
+// クエリパラメータ・環境変数・フラグのリストに使う
+func SplitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,25 @@
+package httputil
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestSplitList はカンマ区切りの値から空白と空の要素を除くことを確認する
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"1つ", "a", []string{"a"}},
+		{"前後の空白", " a , b ", []string{"a", "b"}},
+		{"空の要素", "a,,b,", []string{"a", "b"}},
+		{"空文字列", "", nil},
+	}
+	for _, tt := range tests {
+		if got := SplitList(tt.value); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: SplitList(%q) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package httputil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Proxies は X-Forwarded-For・X-Forwarded-Proto を信用するリバースプロキシのアドレス
+type Proxies []*net.IPNet
+
+// ParseProxies はカンマ区切りのIPアドレスまたはCIDR（例: 10.0.0.0/8）を読み込む
+func ParseProxies(s string) (Proxies, error) {
+	var proxies Proxies
+	for _, v := range SplitList(s) {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("プロキシのアドレスが不正です: %q", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("プロキシのアドレスが不正です: %q", v)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trusted はアドレスが信用するプロキシか判定する
+func (p Proxies) trusted(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteAddr は接続元のホストと、IPアドレスとして読めればそのアドレスを返す
+func remoteAddr(r *http.Request) (string, net.IP) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host, net.ParseIP(host)
+}
+
+// ClientIP はリクエストしたクライアントのIPアドレスを返す
+// 接続元が信用するプロキシの場合だけ X-Forwarded-For を右から辿り、最初に現れた信用しないアドレスを使う
+// （クライアントが偽装できるのは左側の値だけなので、右側から辿る）
+func (p Proxies) ClientIP(r *http.Request) string {
+	host, ip := remoteAddr(r)
+	if ip == nil || !p.trusted(ip) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// 不正な値より左は信用できないため、直前のプロキシをクライアントとみなす
+			break
+		}
+		ip = hop
+		if !p.trusted(hop) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+// IsHTTPS はクライアントがHTTPSで接続したか判定する
+// 接続元が信用するプロキシの場合だけ、プロキシが付けた X-Forwarded-Proto を使う
+func (p Proxies) IsHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if _, ip := remoteAddr(r); ip == nil || !p.trusted(ip) {
+		return false
+	}
+	// 複数のプロキシを経由した場合は、クライアントに近い最初の値を使う
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
@@ -0,0 +1,81 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIP は信用するプロキシからの X-Forwarded-For だけを使うことを確認する
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"直接の接続", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"信用しない接続元の X-Forwarded-For は無視する", "203.0.113.5:1234", []string{"198.51.100.7"}, "203.0.113.5"},
+		{"信用するプロキシ", "10.0.0.1:1234", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"プロキシを右から辿る", "10.0.0.1:1234", []string{"198.51.100.7, 192.0.2.1, 10.0.0.2"}, "198.51.100.7"},
+		{"左側の偽装は使わない", "10.0.0.1:1234", []string{"1.1.1.1, 198.51.100.7"}, "198.51.100.7"},
+		{"複数のヘッダー", "10.0.0.1:1234", []string{"198.51.100.7", "10.0.0.2"}, "198.51.100.7"},
+		{"不正な値で止める", "10.0.0.1:1234", []string{"198.51.100.7, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"ヘッダーが無い", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"全てプロキシ", "10.0.0.1:1234", []string{"10.0.0.3"}, "10.0.0.3"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		for _, v := range tt.forwarded {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := proxies.ClientIP(req); got != tt.want {
+			t.Errorf("%s: ClientIP() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParseProxies("10.0.0.0/33"); err == nil {
+		t.Error("不正なCIDRを読み込めてしまいました")
+	}
+	if _, err := ParseProxies("example.com"); err == nil {
+		t.Error("不正なアドレスを読み込めてしまいました")
+	}
+}
+
+// TestIsHTTPS は信用するプロキシからの X-Forwarded-Proto だけを使うことを確認する
+func TestIsHTTPS(t *testing.T) {
+	proxies, err := ParseProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		url    string
+		remote string
+		proto  string
+		want   bool
+	}{
+		{"HTTPSで直接の接続", "https://example.com/", "203.0.113.5:1234", "", true},
+		{"HTTPで直接の接続", "http://example.com/", "203.0.113.5:1234", "", false},
+		{"信用しない接続元の X-Forwarded-Proto は無視する", "http://example.com/", "203.0.113.5:1234", "https", false},
+		{"信用するプロキシ", "http://example.com/", "10.0.0.1:1234", "https", true},
+		{"信用するプロキシからのHTTP", "http://example.com/", "10.0.0.1:1234", "http", false},
+		{"複数のプロキシはクライアントに近い値を使う", "http://example.com/", "10.0.0.1:1234", "https, http", true},
+		{"大文字", "http://example.com/", "10.0.0.1:1234", "HTTPS", true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		req.RemoteAddr = tt.remote
+		if tt.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tt.proto)
+		}
+		if got := proxies.IsHTTPS(req); got != tt.want {
+			t.Errorf("%s: IsHTTPS() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
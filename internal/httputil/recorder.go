@@ -0,0 +1,68 @@
+package httputil
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// StatusRecorder はレスポンスのステータスコードと書き込んだバイト数を記録する ResponseWriter
+// WebSocket（Hijack）と Server-Sent Events（Flush）のため、元の ResponseWriter の機能を引き継ぐ
+type StatusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+// NewStatusRecorder は w を包んだ StatusRecorder を返す
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status は送信したステータスコードを返す（WriteHeader を呼ばずに書き込んだ場合は200）
+func (r *StatusRecorder) Status() int {
+	return r.status
+}
+
+// Bytes は書き込んだレスポンスボディのバイト数を返す
+func (r *StatusRecorder) Bytes() int {
+	return r.bytes
+}
+
+func (r *StatusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *StatusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap は http.NewResponseController から元の ResponseWriter を使えるようにする
+func (r *StatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *StatusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *StatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	// WebSocketへの切り替えは 101 Switching Protocols として記録する
+	r.status = http.StatusSwitchingProtocols
+	r.wroteHeader = true
+	return hijacker.Hijack()
+}
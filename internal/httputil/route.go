@@ -0,0 +1,21 @@
+package httputil
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RouteTemplate はリクエストに一致したmuxのルートのテンプレート（例: /api/themes/{id}）を返す
+// ルーターのミドルウェアから呼ぶこと。一致したルートが無ければ空文字列を返す
+func RouteTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return template
+}
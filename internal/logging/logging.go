@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/httputil"
+)
+
+// RequestIDHeader はリクエストIDを受け取り、レスポンスで返すヘッダー
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength はクライアントから受け取るリクエストIDの最大長
+const maxRequestIDLength = 128
+
+// ログの形式
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ParseLevel はログレベルの名前（debug, info, warn, error）を読み込む
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("ログレベルには debug, info, warn, error のいずれかを指定してください: %q", name)
+	}
+	return level, nil
+}
+
+// Setup は既定のロガーを設定する
+// log パッケージの出力も同じ形式で書き出されるようになる
+func Setup(w io.Writer, format string, level slog.Level) {
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if format == FormatText {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+type contextKey struct{}
+
+type requestContext struct {
+	id     string
+	logger *slog.Logger
+}
+
+// FromContext はリクエストIDを付けたロガーを返す（リクエストの外では既定のロガー）
+func FromContext(ctx context.Context) *slog.Logger {
+	if rc, ok := ctx.Value(contextKey{}).(*requestContext); ok {
+		return rc.logger
+	}
+	return slog.Default()
+}
+
+// RequestID はリクエストIDを返す（リクエストの外では空文字列）
+func RequestID(ctx context.Context) string {
+	if rc, ok := ctx.Value(contextKey{}).(*requestContext); ok {
+		return rc.id
+	}
+	return ""
+}
+
+// validRequestID はクライアントから受け取ったリクエストIDをそのまま使えるか判定する
+// ログやヘッダーを壊さないよう、英数字と一部の記号だけを受け付ける
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// Middleware はリクエストIDを割り当て（X-Request-ID が送られていればそれを使い）、レスポンスヘッダーで返す
+// リクエストが終わったら、メソッド、ルート、ステータスコード、処理時間、ユーザーを1行のログに残す
+// ログイン中のユーザーを記録するため、auth.Middleware の後に適用すること
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, &requestContext{id: id, logger: logger}))
+
+		start := time.Now()
+		rec := httputil.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		attrs := []any{
+			"method", r.Method,
+			"route", httputil.RouteTemplate(r),
+			"path", r.URL.Path,
+			"status", rec.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", rec.Bytes(),
+			"remote_addr", r.RemoteAddr,
+		}
+		if user := auth.UserFromContext(r.Context()); user != nil {
+			attrs = append(attrs, "user_id", user.ID, "username", user.Username)
+		}
+		level := slog.LevelInfo
+		if rec.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(r.Context(), level, "リクエスト", attrs...)
+	})
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestParseLevel はログレベルの名前を読み込めることを確認する
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"info", slog.LevelInfo, false},
+		{"WARN", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v", tt.name, got, err)
+		}
+	}
+}
+
+// TestMiddleware はリクエストIDを割り当てて返し、リクエストごとに1行のログを残すことを確認する
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	Setup(&buf, FormatJSON, slog.LevelInfo)
+
+	tests := []struct {
+		name      string
+		requestID string // 送る X-Request-ID
+		status    int
+		reuse     bool // 送ったIDをそのまま使うか
+		wantLevel string
+	}{
+		{"送られたIDを使う", "req-1", http.StatusOK, true, "INFO"},
+		{"IDが無ければ割り当てる", "", http.StatusOK, false, "INFO"},
+		{"使えない文字を含むIDは使わない", "req 1\n", http.StatusOK, false, "INFO"},
+		{"500はエラーとして残す", "req-2", http.StatusInternalServerError, true, "ERROR"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		var inHandler string
+		handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inHandler = RequestID(r.Context())
+			w.WriteHeader(tt.status)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/api/themes", nil)
+		if tt.requestID != "" {
+			req.Header.Set(RequestIDHeader, tt.requestID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if id == "" || id != inHandler || (tt.reuse && id != tt.requestID) || (!tt.reuse && id == tt.requestID) {
+			t.Errorf("%s: レスポンスのID = %q, ハンドラーのID = %q", tt.name, id, inHandler)
+		}
+
+		var entry struct {
+			Level     string `json:"level"`
+			RequestID string `json:"request_id"`
+			Method    string `json:"method"`
+			Path      string `json:"path"`
+			Status    int    `json:"status"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("%s: ログが1行のJSONではありません: %v\n%s", tt.name, err, buf.String())
+		}
+		if entry.Level != tt.wantLevel || entry.RequestID != id || entry.Method != http.MethodGet || entry.Path != "/api/themes" || entry.Status != tt.status {
+			t.Errorf("%s: ログ = %+v", tt.name, entry)
+		}
+	}
+}
+
+// TestFromContext はリクエストの外では既定のロガーと空のIDを返すことを確認する
+func TestFromContext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if FromContext(req.Context()) != slog.Default() || RequestID(req.Context()) != "" {
+		t.Error("リクエストの外で既定のロガー・空のIDになりません")
+	}
+}
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// domainCacheTTL は集計した件数を使い回す時間
+// 集計は全てのお題と回答を読み出すので、スクレイプのたびには行わない
+const domainCacheTTL = 30 * time.Second
+
+// domainCollector はお題・回答・いいね・通報の件数を、domainCacheTTL ごとにデータストアから集計する
+type domainCollector struct {
+	store data.DataStore
+	now   func() time.Time
+
+	themes       *prometheus.Desc
+	activeThemes *prometheus.Desc
+	answers      *prometheus.Desc
+	themeAnswers *prometheus.Desc
+	likes        *prometheus.Desc
+	reports      *prometheus.Desc
+
+	mu       sync.Mutex
+	snapshot *domainSnapshot // 最後に集計した件数（まだ集計していなければ nil）
+}
+
+// domainSnapshot はある時点で集計した件数
+type domainSnapshot struct {
+	at           time.Time
+	themes       map[data.SubmissionStatus]int
+	activeThemes int
+	answers      map[data.ModerationState]int
+	themeAnswers map[string]int // 受付中のお題ごとの回答の数
+	likes        int
+	reports      int
+}
+
+// RegisterDomain はお題・回答などの件数のゲージを登録する
+// 集計のための読み出しを操作のメトリクスに含めないよう、InstrumentStore で包む前のストアを渡すこと
+func (m *Metrics) RegisterDomain(store data.DataStore) {
+	m.Register(newDomainCollector(store))
+}
+
+func newDomainCollector(store data.DataStore) *domainCollector {
+	return &domainCollector{
+		store: store,
+		now:   time.Now,
+		themes: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "themes"),
+			"お題の数（審査状態ごと）", []string{"submission_status"}, nil),
+		activeThemes: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "themes_active"),
+			"回答を受け付けているお題の数", nil, nil),
+		answers: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "answers"),
+			"回答の数（モデレーションの状態ごと）", []string{"moderation"}, nil),
+		// 全てのお題に系列を作ると際限なく増えるので、回答を受け付けているお題だけにする
+		themeAnswers: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "theme_answers"),
+			"回答を受け付けているお題ごとの回答の数（確認待ち・非表示を含む）", []string{"theme_id"}, nil),
+		// 再起動しても減らないよう、カウンターではなく回答のいいね数の合計を数える
+		likes: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "likes"),
+			"全ての回答のいいねの数の合計", nil, nil),
+		reports: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "reports_open"),
+			"未対応の通報の数", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.themes
+	ch <- c.activeThemes
+	ch <- c.answers
+	ch <- c.themeAnswers
+	ch <- c.likes
+	ch <- c.reports
+}
+
+// Collect implements prometheus.Collector
+func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.snapshot == nil || c.now().Sub(c.snapshot.at) >= domainCacheTTL {
+		snapshot, err := c.collect()
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.themes, err)
+			return
+		}
+		c.snapshot = snapshot
+	}
+
+	s := c.snapshot
+	for status, n := range s.themes {
+		ch <- prometheus.MustNewConstMetric(c.themes, prometheus.GaugeValue, float64(n), string(status))
+	}
+	ch <- prometheus.MustNewConstMetric(c.activeThemes, prometheus.GaugeValue, float64(s.activeThemes))
+	for state, n := range s.answers {
+		label := string(state)
+		if state == data.ModerationNone {
+			label = "public" // 回答の一覧の moderation と同じ名前にする
+		}
+		ch <- prometheus.MustNewConstMetric(c.answers, prometheus.GaugeValue, float64(n), label)
+	}
+	for themeID, n := range s.themeAnswers {
+		ch <- prometheus.MustNewConstMetric(c.themeAnswers, prometheus.GaugeValue, float64(n), themeID)
+	}
+	ch <- prometheus.MustNewConstMetric(c.likes, prometheus.GaugeValue, float64(s.likes))
+	ch <- prometheus.MustNewConstMetric(c.reports, prometheus.GaugeValue, float64(s.reports))
+}
+
+// collect はデータストアから件数を集計する
+func (c *domainCollector) collect() (*domainSnapshot, error) {
+	themes, _, err := c.store.ListThemes(data.ListOptions{Submission: data.AllSubmissionStatuses})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &domainSnapshot{
+		at:           c.now(),
+		themes:       make(map[data.SubmissionStatus]int),
+		answers:      make(map[data.ModerationState]int),
+		themeAnswers: make(map[string]int),
+	}
+	for _, status := range data.AllSubmissionStatuses {
+		s.themes[status] = 0
+	}
+	for _, state := range data.AllModerationStates {
+		s.answers[state] = 0
+	}
+	for _, theme := range themes {
+		s.themes[theme.SubmissionStatus]++
+		active := theme.Approved() && theme.Active
+		if active {
+			s.activeThemes++
+		}
+
+		answers, _, err := c.store.ListAnswers(theme.ID, data.ListOptions{Moderation: data.AllModerationStates})
+		if err != nil {
+			return nil, err
+		}
+		for _, answer := range answers {
+			s.answers[answer.Moderation]++
+			s.likes += answer.Likes
+		}
+		if active {
+			s.themeAnswers[theme.ID] = len(answers)
+		}
+	}
+
+	reports, err := c.store.ListReports()
+	if err != nil {
+		return nil, err
+	}
+	s.reports = len(reports)
+	return s, nil
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/httputil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace は全てのメトリクス名の接頭辞
+const namespace = "ogiri"
+
+// Metrics はサーバーのメトリクスをまとめたもの
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	storeDuration *prometheus.HistogramVec
+	storeErrors   *prometheus.CounterVec
+}
+
+// New は新しいMetricsインスタンスを返す
+// Goランタイムとプロセスのメトリクスも合わせて公開する
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "APIへのリクエスト数（ルートのテンプレート、メソッド、ステータスコードごと）",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "APIのリクエストの処理時間（WebSocket・Server-Sent Events は接続時間）",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "データストアの操作の処理時間",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+		}, []string{"operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "store_errors_total",
+			Help:      "データストアの操作で発生したエラーの数（見つからない・重複などの想定内のエラーは除く）",
+		}, []string{"operation"}),
+	}
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpDuration,
+		m.storeDuration,
+		m.storeErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Register は追加のメトリクスを登録する
+func (m *Metrics) Register(c prometheus.Collector) {
+	m.registry.MustRegister(c)
+}
+
+// GaugeFunc は呼び出すたびに値を取得するゲージを登録する
+func (m *Metrics) GaugeFunc(name, help string, value func() float64) {
+	m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, value))
+}
+
+// Handler はPrometheusのテキスト形式でメトリクスを返すハンドラー
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware はmuxのルートごとにリクエスト数と処理時間を記録する
+// ラベルにはパスそのものではなくルートのテンプレート（例: /api/themes/{id}）を使う
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := httputil.RouteTemplate(r)
+		if route == "" {
+			route = "unknown"
+		}
+
+		start := time.Now()
+		rec := httputil.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		code := strconv.Itoa(rec.Status())
+		m.httpRequests.WithLabelValues(route, r.Method, code).Inc()
+		m.httpDuration.WithLabelValues(route, r.Method, code).Observe(time.Since(start).Seconds())
+	})
+}
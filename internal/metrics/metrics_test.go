@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// gauges は登録したメトリクスを取得し、名前ごとに系列の値を返す
+func gauges(t *testing.T, registry *prometheus.Registry) map[string][]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string][]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			values[family.GetName()] = append(values[family.GetName()], metric.GetGauge().GetValue())
+		}
+	}
+	return values
+}
+
+// TestDomainCollector は回答の数の系列が受付中のお題に限られ、集計結果を使い回すことを確認する
+func TestDomainCollector(t *testing.T) {
+	store := data.NewInMemoryStore()
+	closed := time.Now().Add(-time.Hour)
+	var open *data.Theme
+	for _, theme := range []*data.Theme{
+		{Title: "受付中", Active: true},
+		{Title: "受付終了", ClosesAt: &closed},
+		{Title: "審査待ち", Active: true, SubmissionStatus: data.SubmissionPending},
+	} {
+		if err := store.CreateTheme(theme); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateAnswer(&data.Answer{ThemeID: theme.ID, Content: "回答"}); err != nil {
+			t.Fatal(err)
+		}
+		if open == nil {
+			open = theme
+		}
+	}
+
+	now := time.Now()
+	c := newDomainCollector(store)
+	c.now = func() time.Time { return now }
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	tests := []struct {
+		name    string
+		advance time.Duration // 前回の取得からの経過時間
+		answers int           // 取得の前に受付中のお題に追加する回答の数
+		want    float64       // 受付中のお題の回答の数
+	}{
+		{"最初の取得", 0, 0, 1},
+		{"使い回す", domainCacheTTL / 2, 1, 1},
+		{"集計し直す", domainCacheTTL, 0, 2},
+	}
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		for j := 0; j < tt.answers; j++ {
+			if err := store.CreateAnswer(&data.Answer{ThemeID: open.ID, Content: "回答"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got := gauges(t, registry)["ogiri_theme_answers"]
+		if len(got) != 1 {
+			t.Errorf("%s: ogiri_theme_answers の系列 = %v, want 1つ（受付中のお題だけ）", tt.name, got)
+			continue
+		}
+		if got[0] != tt.want {
+			t.Errorf("%s: 回答の数 = %v, want %v", tt.name, got[0], tt.want)
+		}
+	}
+}
+
+// TestLikesGauge はいいねの数を全ての回答のいいね数の合計として数えることを確認する
+func TestLikesGauge(t *testing.T) {
+	store := data.NewInMemoryStore()
+	theme := &data.Theme{Title: "お題", Active: true}
+	if err := store.CreateTheme(theme); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c := newDomainCollector(store)
+	c.now = func() time.Time { return now }
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	var answers []*data.Answer
+	for i := 0; i < 2; i++ {
+		answer := &data.Answer{ThemeID: theme.ID, Content: "回答"}
+		if err := store.CreateAnswer(answer); err != nil {
+			t.Fatal(err)
+		}
+		answers = append(answers, answer)
+	}
+
+	tests := []struct {
+		name  string
+		apply func() error
+		want  float64
+	}{
+		{"いいねなし", func() error { return nil }, 0},
+		{"別々の回答へのいいね", func() error {
+			for i, answer := range answers {
+				if _, err := store.LikeAnswer(answer.ID, theme.ID, fmt.Sprintf("v%d", i)); err != nil {
+					return err
+				}
+			}
+			_, err := store.LikeAnswer(answers[0].ID, theme.ID, "v9")
+			return err
+		}, 3},
+		{"取り消すと減る", func() error {
+			_, err := store.UnlikeAnswer(answers[0].ID, theme.ID, "v9")
+			return err
+		}, 2},
+	}
+	for _, tt := range tests {
+		if err := tt.apply(); err != nil {
+			t.Fatal(err)
+		}
+		// 集計し直させる
+		now = now.Add(domainCacheTTL)
+		if got := gauges(t, registry)["ogiri_likes"]; len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: ogiri_likes = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestMiddleware はリクエストをルートのテンプレートとステータスコードごとに数えることを確認する
+func TestMiddleware(t *testing.T) {
+	m := New()
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/api/themes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	for _, path := range []string{"/api/themes/t1", "/api/themes/t2", "/api/themes/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	tests := []struct {
+		code string
+		want float64
+	}{
+		{"200", 2},
+		{"404", 1},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("/api/themes/{id}", http.MethodGet, tt.code)); got != tt.want {
+			t.Errorf("code %s: リクエスト数 = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+// TestInstrumentStore は想定内のエラーを除いて、データストアの操作のエラーを数えることを確認する
+func TestInstrumentStore(t *testing.T) {
+	m := New()
+	store := m.InstrumentStore(data.NewInMemoryStore())
+
+	if _, err := store.GetTheme("missing"); !errors.Is(err, data.ErrNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	if got := testutil.ToFloat64(m.storeErrors.WithLabelValues("get_theme")); got != 0 {
+		t.Errorf("見つからないエラーを数えました: %v", got)
+	}
+	if got := testutil.CollectAndCount(m.storeDuration); got != 1 {
+		t.Errorf("処理時間の系列の数 = %d, want 1", got)
+	}
+}
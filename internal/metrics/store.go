@@ -0,0 +1,235 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// InstrumentedStore はデータストアの操作ごとに処理時間とエラーを記録するラッパー
+type InstrumentedStore struct {
+	data.DataStore
+	m *Metrics
+}
+
+// InstrumentStore はストアを包んで操作のメトリクスを記録する
+func (m *Metrics) InstrumentStore(store data.DataStore) *InstrumentedStore {
+	return &InstrumentedStore{DataStore: store, m: m}
+}
+
+// expectedErrors は利用者の操作で普通に起こるエラー（ストアの異常として数えない）
+var expectedErrors = []error{
+	data.ErrNotFound,
+	data.ErrAlreadyLiked,
+	data.ErrNotLiked,
+	data.ErrVersionConflict,
+	data.ErrAlreadyReported,
+	data.ErrUsernameTaken,
+	data.ErrInvalidCursor,
+	data.ErrExists,
+}
+
+// observe は操作の処理時間を記録し、想定外のエラーを数える
+func (s *InstrumentedStore) observe(op string, start time.Time, err error) {
+	s.m.storeDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+	for _, expected := range expectedErrors {
+		if err == expected {
+			return
+		}
+	}
+	s.m.storeErrors.WithLabelValues(op).Inc()
+}
+
+// 以下は DataStore の各操作を、処理時間とエラーを記録しながら元のストアに渡す
+
+func (s *InstrumentedStore) GetTheme(id string) (*data.Theme, error) {
+	start := time.Now()
+	theme, err := s.DataStore.GetTheme(id)
+	s.observe("get_theme", start, err)
+	return theme, err
+}
+
+func (s *InstrumentedStore) ListThemes(opts data.ListOptions) ([]*data.Theme, string, error) {
+	start := time.Now()
+	themes, next, err := s.DataStore.ListThemes(opts)
+	s.observe("list_themes", start, err)
+	return themes, next, err
+}
+
+func (s *InstrumentedStore) CreateTheme(theme *data.Theme) error {
+	start := time.Now()
+	err := s.DataStore.CreateTheme(theme)
+	s.observe("create_theme", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) UpdateTheme(theme *data.Theme) error {
+	start := time.Now()
+	err := s.DataStore.UpdateTheme(theme)
+	s.observe("update_theme", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteTheme(id string, version int64) error {
+	start := time.Now()
+	err := s.DataStore.DeleteTheme(id, version)
+	s.observe("delete_theme", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetAnswer(id string, themeID string) (*data.Answer, error) {
+	start := time.Now()
+	answer, err := s.DataStore.GetAnswer(id, themeID)
+	s.observe("get_answer", start, err)
+	return answer, err
+}
+
+func (s *InstrumentedStore) ListAnswers(themeID string, opts data.ListOptions) ([]*data.Answer, string, error) {
+	start := time.Now()
+	answers, next, err := s.DataStore.ListAnswers(themeID, opts)
+	s.observe("list_answers", start, err)
+	return answers, next, err
+}
+
+func (s *InstrumentedStore) CreateAnswer(answer *data.Answer) error {
+	start := time.Now()
+	err := s.DataStore.CreateAnswer(answer)
+	s.observe("create_answer", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) UpdateAnswer(answer *data.Answer) error {
+	start := time.Now()
+	err := s.DataStore.UpdateAnswer(answer)
+	s.observe("update_answer", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteAnswer(id string, themeID string, version int64) error {
+	start := time.Now()
+	err := s.DataStore.DeleteAnswer(id, themeID, version)
+	s.observe("delete_answer", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) LikeAnswer(id string, themeID string, voterID string) (*data.Answer, error) {
+	start := time.Now()
+	answer, err := s.DataStore.LikeAnswer(id, themeID, voterID)
+	s.observe("like_answer", start, err)
+	return answer, err
+}
+
+func (s *InstrumentedStore) UnlikeAnswer(id string, themeID string, voterID string) (*data.Answer, error) {
+	start := time.Now()
+	answer, err := s.DataStore.UnlikeAnswer(id, themeID, voterID)
+	s.observe("unlike_answer", start, err)
+	return answer, err
+}
+
+func (s *InstrumentedStore) HasLiked(id string, voterID string) (bool, error) {
+	start := time.Now()
+	liked, err := s.DataStore.HasLiked(id, voterID)
+	s.observe("has_liked", start, err)
+	return liked, err
+}
+
+func (s *InstrumentedStore) CreateReport(report *data.Report) error {
+	start := time.Now()
+	err := s.DataStore.CreateReport(report)
+	s.observe("create_report", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) ListReports() ([]*data.Report, error) {
+	start := time.Now()
+	reports, err := s.DataStore.ListReports()
+	s.observe("list_reports", start, err)
+	return reports, err
+}
+
+func (s *InstrumentedStore) DeleteReports(answerID string) error {
+	start := time.Now()
+	err := s.DataStore.DeleteReports(answerID)
+	s.observe("delete_reports", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) AnswerExists(id string) (bool, error) {
+	start := time.Now()
+	exists, err := s.DataStore.AnswerExists(id)
+	s.observe("answer_exists", start, err)
+	return exists, err
+}
+
+func (s *InstrumentedStore) Import(batch *data.ImportBatch) error {
+	start := time.Now()
+	err := s.DataStore.Import(batch)
+	s.observe("import", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetUser(id string) (*data.User, error) {
+	start := time.Now()
+	user, err := s.DataStore.GetUser(id)
+	s.observe("get_user", start, err)
+	return user, err
+}
+
+func (s *InstrumentedStore) GetUserByUsername(username string) (*data.User, error) {
+	start := time.Now()
+	user, err := s.DataStore.GetUserByUsername(username)
+	s.observe("get_user_by_username", start, err)
+	return user, err
+}
+
+func (s *InstrumentedStore) CreateUser(user *data.User) error {
+	start := time.Now()
+	err := s.DataStore.CreateUser(user)
+	s.observe("create_user", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) UpdateUser(user *data.User) error {
+	start := time.Now()
+	err := s.DataStore.UpdateUser(user)
+	s.observe("update_user", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateSession(session *data.Session) error {
+	start := time.Now()
+	err := s.DataStore.CreateSession(session)
+	s.observe("create_session", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetSession(tokenHash string) (*data.Session, error) {
+	start := time.Now()
+	session, err := s.DataStore.GetSession(tokenHash)
+	s.observe("get_session", start, err)
+	return session, err
+}
+
+func (s *InstrumentedStore) DeleteSession(tokenHash string) error {
+	start := time.Now()
+	err := s.DataStore.DeleteSession(tokenHash)
+	s.observe("delete_session", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) Ping() error {
+	start := time.Now()
+	err := s.DataStore.Ping()
+	s.observe("ping", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) Close() error {
+	start := time.Now()
+	err := s.DataStore.Close()
+	s.observe("close", start, err)
+	return err
+}
@@ -0,0 +1,169 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Action はNGワードを含む投稿の扱い
+type Action string
+
+const (
+	ActionReject Action = "reject" // 投稿を拒否する
+	ActionReview Action = "review" // 投稿を受け付け、確認待ちにする
+)
+
+// Config はNGワードフィルターの設定
+type Config struct {
+	Words  []string // NGワード（正規化してから照合する）
+	Action Action   // 空の場合は ActionReject
+}
+
+// Field は検査する入力項目
+type Field struct {
+	Name string // レスポンスで返す項目名（JSONのキー）
+	Text string
+}
+
+// Violation は項目に含まれていたNGワード
+type Violation struct {
+	Field string   `json:"field"`
+	Words []string `json:"words"`
+}
+
+type word struct {
+	original   string
+	normalized string
+}
+
+// Filter はNGワードを検出する
+// nil の Filter は何も検出しない
+type Filter struct {
+	words  []word
+	action Action
+}
+
+// New は新しいFilterインスタンスを返す
+func New(cfg Config) (*Filter, error) {
+	action := cfg.Action
+	if action == "" {
+		action = ActionReject
+	}
+	if action != ActionReject && action != ActionReview {
+		return nil, fmt.Errorf("NGワードの扱いには %s か %s を指定してください: %q", ActionReject, ActionReview, action)
+	}
+
+	f := &Filter{action: action}
+	for _, w := range cfg.Words {
+		normalized := Normalize(w)
+		if normalized == "" {
+			continue
+		}
+		f.words = append(f.words, word{original: strings.TrimSpace(w), normalized: normalized})
+	}
+	return f, nil
+}
+
+// LoadWords はNGワードのファイルを読み込む
+// 1行に1語で、空行と # で始まる行は無視する
+func LoadWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// Action はNGワードを含む投稿の扱いを返す
+func (f *Filter) Action() Action {
+	if f == nil {
+		return ActionReject
+	}
+	return f.action
+}
+
+// Len は登録されているNGワードの数を返す
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.words)
+}
+
+// Check は各項目に含まれるNGワードを返す（含まれていなければ nil）
+func (f *Filter) Check(fields ...Field) []Violation {
+	if f == nil || len(f.words) == 0 {
+		return nil
+	}
+
+	var violations []Violation
+	for _, field := range fields {
+		text := Normalize(field.Text)
+		var found []string
+		for _, w := range f.words {
+			if strings.Contains(text, w.normalized) {
+				found = append(found, w.original)
+			}
+		}
+		if len(found) > 0 {
+			violations = append(violations, Violation{Field: field.Name, Words: found})
+		}
+	}
+	return violations
+}
+
+// Normalize は表記ゆれを吸収するために文字列を正規化する
+//   - 全角・半角の英数字とカタカナを統一する（NFKC）
+//   - 英字を小文字にする
+//   - カタカナをひらがなにする
+//   - 空白・記号・句読点・長音符を取り除く（「ば か」「ば.か」「ばーか」のような挿入による回避を防ぐ）
+func Normalize(s string) string {
+	s = norm.NFKC.String(s)
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		r = unicode.ToLower(r)
+		// カタカナ（ァ〜ヶ）はひらがなと同じ並びなので、差分だけずらす
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 'ァ' - 'ぁ'
+		}
+		switch {
+		case r == 'ー':
+			// 長音符は「ばーか」のような引き伸ばしに使われるため取り除く
+		case unicode.IsLetter(r), unicode.IsNumber(r):
+			b.WriteRune(r)
+		case r == '゙', r == '゚':
+			// 分離した濁点・半濁点は直前の文字と合成するため残す
+			b.WriteRune(r)
+		}
+	}
+
+	// 記号を取り除いたことで隣り合った濁点・半濁点を合成し、合成できなかったものは捨てる
+	composed := norm.NFC.String(b.String())
+	return strings.Map(func(r rune) rune {
+		if r == '゙' || r == '゚' {
+			return -1
+		}
+		return r
+	}, composed)
+}
@@ -0,0 +1,95 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestNormalize は表記ゆれが同じ文字列に正規化されることを確認する
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ひらがな", "ばか", "ばか"},
+		{"カタカナ", "バカ", "ばか"},
+		{"半角カタカナ", "ﾊﾞｶ", "ばか"},
+		{"全角英数字", "ＡＢＣ１２３", "abc123"},
+		{"大文字", "NG Word", "ngword"},
+		{"空白の挿入", "ば か", "ばか"},
+		{"全角空白の挿入", "ば　か", "ばか"},
+		{"記号の挿入", "ば.か!", "ばか"},
+		{"長音符の挿入", "ばーか", "ばか"},
+		{"記号で分けた濁点", "は.゙か", "ばか"},
+		{"合成できない濁点", "゙か", "か"},
+		{"空", "  ", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("%s: Normalize(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestCheck はNGワードを含む項目だけを、登録した表記のまま返すことを確認する
+func TestCheck(t *testing.T) {
+	f, err := New(Config{Words: []string{"バカ", "ＮＧ", "  "}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 2 {
+		t.Errorf("Len() = %d, want 2（空のNGワードは登録しない）", f.Len())
+	}
+
+	tests := []struct {
+		name   string
+		fields []Field
+		want   []Violation
+	}{
+		{"含まない", []Field{{"title", "おもしろい"}}, nil},
+		{"ひらがなで含む", []Field{{"title", "ばかだな"}}, []Violation{{"title", []string{"バカ"}}}},
+		{"空白を挟んで含む", []Field{{"title", "ば か"}}, []Violation{{"title", []string{"バカ"}}}},
+		{"半角の英字", []Field{{"title", "ng"}}, []Violation{{"title", []string{"ＮＧ"}}}},
+		{
+			"項目ごとに返す",
+			[]Field{{"title", "ok"}, {"description", "ばーか、NG"}},
+			[]Violation{{"description", []string{"バカ", "ＮＧ"}}},
+		},
+	}
+	for _, tt := range tests {
+		if got := f.Check(tt.fields...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Check() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var none *Filter
+	if got := none.Check(Field{"title", "ばか"}); got != nil {
+		t.Errorf("nil の Filter が検出しました: %v", got)
+	}
+}
+
+// TestNewAction は扱いの既定値と不正な値を確認する
+func TestNewAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  Action
+		want    Action
+		wantErr bool
+	}{
+		{"省略すると拒否", "", ActionReject, false},
+		{"拒否", ActionReject, ActionReject, false},
+		{"確認待ち", ActionReview, ActionReview, false},
+		{"不正な値", "hide", "", true},
+	}
+	for _, tt := range tests {
+		f, err := New(Config{Action: tt.action})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && f.Action() != tt.want {
+			t.Errorf("%s: Action() = %q, want %q", tt.name, f.Action(), tt.want)
+		}
+	}
+}
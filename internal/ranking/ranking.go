@@ -0,0 +1,124 @@
+package ranking
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// RankedAnswer は順位を付けた回答
+type RankedAnswer struct {
+	Rank int `json:"rank"`
+	*data.Answer
+}
+
+// RankAnswers は回答をいいね数の多い順に並べて順位を付ける
+// いいね数が同じ場合は先に投稿された回答を上位にするため、順位は重複しない
+func RankAnswers(answers []*data.Answer) []RankedAnswer {
+	sorted := make([]*data.Answer, len(answers))
+	copy(sorted, answers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Likes != b.Likes {
+			return a.Likes > b.Likes
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	ranked := make([]RankedAnswer, len(sorted))
+	for i, answer := range sorted {
+		ranked[i] = RankedAnswer{Rank: i + 1, Answer: answer}
+	}
+	return ranked
+}
+
+// Winner はお題の1位の回答を返す（回答が無いか、いいねが1つも無い場合は nil）
+func Winner(answers []*data.Answer) *data.Answer {
+	ranked := RankAnswers(answers)
+	if len(ranked) == 0 || ranked[0].Likes == 0 {
+		return nil
+	}
+	return ranked[0].Answer
+}
+
+// Entry は全体リーダーボードの1行
+type Entry struct {
+	Rank       int    `json:"rank"`
+	UserID     string `json:"user_id"`
+	Username   string `json:"username"`
+	TotalLikes int    `json:"total_likes"`
+	Wins       int    `json:"wins"`
+	Answers    int    `json:"answers"`
+}
+
+// Leaderboard は全てのお題の回答を集計して、作成者ごとの合計いいね数と優勝回数を返す
+// 優勝は受付を終了したお題（now の時点で closed）の1位のみを数える
+// 匿名の回答は集計しない。合計いいね数、優勝回数の順に並べ、どちらも同じ場合は同順位にする
+func Leaderboard(store data.DataStore, now time.Time) ([]Entry, error) {
+	themes, _, err := store.ListThemes(data.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*Entry)
+	entry := func(answer *data.Answer) *Entry {
+		e, exists := entries[answer.UserID]
+		if !exists {
+			e = &Entry{UserID: answer.UserID, Username: answer.CreatedBy}
+			entries[answer.UserID] = e
+		}
+		return e
+	}
+
+	for _, theme := range themes {
+		answers, _, err := store.ListAnswers(theme.ID, data.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, answer := range answers {
+			if answer.UserID == "" {
+				continue
+			}
+			e := entry(answer)
+			e.TotalLikes += answer.Likes
+			e.Answers++
+		}
+		if theme.Status(now) != data.ThemeClosed {
+			continue
+		}
+		if winner := Winner(answers); winner != nil && winner.UserID != "" {
+			entry(winner).Wins++
+		}
+	}
+
+	board := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		// ユーザー名は変更されている場合があるため、登録済みなら現在の名前を使う
+		if user, err := store.GetUser(e.UserID); err == nil {
+			e.Username = user.Username
+		}
+		board = append(board, *e)
+	}
+	sort.Slice(board, func(i, j int) bool {
+		a, b := board[i], board[j]
+		if a.TotalLikes != b.TotalLikes {
+			return a.TotalLikes > b.TotalLikes
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Username < b.Username
+	})
+	for i := range board {
+		if i > 0 && board[i].TotalLikes == board[i-1].TotalLikes && board[i].Wins == board[i-1].Wins {
+			board[i].Rank = board[i-1].Rank
+		} else {
+			board[i].Rank = i + 1
+		}
+	}
+	return board, nil
+}
@@ -0,0 +1,130 @@
+package ranking
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// TestRankAnswers はいいね数、投稿日時、IDの順に並べて重複しない順位を付けることを確認する
+func TestRankAnswers(t *testing.T) {
+	base := time.Now()
+	answers := []*data.Answer{
+		{ID: "a", Likes: 1, CreatedAt: base},
+		{ID: "b", Likes: 3, CreatedAt: base.Add(time.Second)},
+		{ID: "d", Likes: 3, CreatedAt: base},
+		{ID: "c", Likes: 3, CreatedAt: base},
+		{ID: "e", Likes: 0, CreatedAt: base.Add(-time.Second)},
+	}
+
+	ranked := RankAnswers(answers)
+	var got []string
+	for i, r := range ranked {
+		if r.Rank != i+1 {
+			t.Errorf("%s の順位 = %d, want %d", r.ID, r.Rank, i+1)
+		}
+		got = append(got, r.ID)
+	}
+	if want := "[c d b a e]"; fmt.Sprint(got) != want {
+		t.Errorf("順番 = %v, want %s", got, want)
+	}
+	// 元のスライスは並べ替えない
+	if answers[0].ID != "a" {
+		t.Error("元のスライスが並べ替えられています")
+	}
+}
+
+// TestWinner は1位の回答を返し、いいねが無ければ優勝なしにすることを確認する
+func TestWinner(t *testing.T) {
+	base := time.Now()
+	tests := []struct {
+		name    string
+		answers []*data.Answer
+		want    string // 空なら優勝なし
+	}{
+		{"回答なし", nil, ""},
+		{"いいねなし", []*data.Answer{{ID: "a"}, {ID: "b"}}, ""},
+		{"いいねが最も多い回答", []*data.Answer{{ID: "a", Likes: 1}, {ID: "b", Likes: 2}}, "b"},
+		{"同数なら先に投稿された回答", []*data.Answer{{ID: "a", Likes: 2, CreatedAt: base.Add(time.Second)}, {ID: "b", Likes: 2, CreatedAt: base}}, "b"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if winner := Winner(tt.answers); winner != nil {
+			got = winner.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s: winner = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestLeaderboard は受付を終了したお題の1位だけを優勝として数え、同じ成績を同順位にすることを確認する
+func TestLeaderboard(t *testing.T) {
+	store := data.NewInMemoryStore()
+	now := time.Now()
+	closesAt := now.Add(time.Hour)
+	closing := &data.Theme{Title: "終了するお題", Active: true, ClosesAt: &closesAt}
+	open := &data.Theme{Title: "受付中のお題", Active: true}
+	for _, theme := range []*data.Theme{closing, open} {
+		if err := store.CreateTheme(theme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users := map[string]*data.User{}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := &data.User{Username: name, PasswordHash: "-"}
+		if err := store.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+		users[name] = user
+	}
+
+	// 回答ごとのいいね数
+	answer := func(theme *data.Theme, author string, likes int) {
+		a := &data.Answer{ThemeID: theme.ID, Content: "回答"}
+		if author != "" {
+			a.UserID = users[author].ID
+			a.CreatedBy = author
+		}
+		if err := store.CreateAnswer(a); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < likes; i++ {
+			if _, err := store.LikeAnswer(a.ID, theme.ID, fmt.Sprintf("voter_%d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	answer(closing, "alice", 3)
+	answer(closing, "bob", 1)
+	answer(closing, "", 2) // 匿名の回答は集計しない
+	answer(open, "bob", 2)
+	answer(open, "carol", 4)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"受付中なら優勝を数えない", now, "[{1 carol 4 0 1} {2 alice 3 0 1} {2 bob 3 0 2}]"},
+		{"終了後は1位の作成者が優勝", now.Add(2 * time.Hour), "[{1 carol 4 0 1} {2 alice 3 1 1} {3 bob 3 0 2}]"},
+	}
+	for _, tt := range tests {
+		board, err := Leaderboard(store, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range board {
+			if e.UserID != users[e.Username].ID {
+				t.Errorf("%s: %s の user_id = %s", tt.name, e.Username, e.UserID)
+			}
+			got = append(got, fmt.Sprintf("{%d %s %d %d %d}", e.Rank, e.Username, e.TotalLikes, e.Wins, e.Answers))
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("%s:\n got %v\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
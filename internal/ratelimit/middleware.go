@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/httputil"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// Middleware はリクエストごとに limiterFor が返すLimiterで回数を制限する
+// limiterFor が nil を返すリクエストは制限しない
+// ログイン中はユーザーごと、それ以外はクライアントのIPアドレスごとに数える
+// auth.Middleware の後に適用すること
+func Middleware(proxies httputil.Proxies, limiterFor func(*http.Request) *Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter := limiterFor(r)
+			if r.Method == http.MethodOptions || limiter == nil || limiter.Rule().Disabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ip:" + proxies.ClientIP(r)
+			if user := auth.UserFromContext(r.Context()); user != nil {
+				key = "user:" + user.ID
+			}
+
+			result := limiter.Allow(key)
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(result.Reset.Seconds())))
+			if !result.Allowed {
+				retryAfter := seconds(result.RetryAfter.Seconds())
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				e := response.Errorf(http.StatusTooManyRequests, response.CodeRateLimited, "リクエストが多すぎます。%d秒後に再度お試しください", retryAfter)
+				e.RetryAfter = retryAfter
+				response.WriteError(w, r, e)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds は秒数を切り上げて整数にする
+func seconds(s float64) int {
+	return int(math.Ceil(s))
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval は使われなくなったバケットを掃除する間隔
+const sweepInterval = time.Minute
+
+// Rule はトークンバケットの設定
+// Burst 回まで連続でリクエストでき、Per ごとに Burst 個のトークンが補充される
+type Rule struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Disabled は制限が無効か判定する
+func (r Rule) Disabled() bool {
+	return r.Burst <= 0 || r.Per <= 0
+}
+
+// String は ParseRule で読み込める形式で返す
+func (r Rule) String() string {
+	if r.Disabled() {
+		return "0"
+	}
+	switch r.Per {
+	case time.Second:
+		return fmt.Sprintf("%d/s", r.Burst)
+	case time.Minute:
+		return fmt.Sprintf("%d/m", r.Burst)
+	case time.Hour:
+		return fmt.Sprintf("%d/h", r.Burst)
+	}
+	return fmt.Sprintf("%d/%s", r.Burst, r.Per)
+}
+
+// ParseRule は "60/m" のような「回数/期間」形式の設定を読み込む
+// 期間には s, m, h または time.ParseDuration の形式（例: 30s）を指定できる。"0" は制限なし
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return Rule{}, nil
+	}
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("レート制限は「回数/期間」の形式（例: 60/m）で指定してください: %q", s)
+	}
+	burst, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || burst < 0 {
+		return Rule{}, fmt.Errorf("レート制限の回数が不正です: %q", s)
+	}
+	var per time.Duration
+	switch period = strings.TrimSpace(period); period {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		per, err = time.ParseDuration(period)
+		if err != nil || per <= 0 {
+			return Rule{}, fmt.Errorf("レート制限の期間が不正です: %q", s)
+		}
+	}
+	return Rule{Burst: burst, Per: per}, nil
+}
+
+// Result は1回のリクエストの判定結果
+type Result struct {
+	Allowed    bool
+	Limit      int           // バケットの容量
+	Remaining  int           // 残りのトークン数
+	RetryAfter time.Duration // 次のトークンが補充されるまでの時間（Allowed の場合は0）
+	Reset      time.Duration // バケットが満杯に戻るまでの時間
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter はキー（ユーザーやIPアドレス）ごとのトークンバケット
+// 無効な Rule で作った Limiter は全てのリクエストを許可する
+type Limiter struct {
+	rule Rule
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter は新しいLimiterインスタンスを返す
+func NewLimiter(rule Rule) *Limiter {
+	return &Limiter{
+		rule:    rule,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Rule は制限の設定を返す
+func (l *Limiter) Rule() Rule {
+	return l.rule
+}
+
+// Allow はキーのトークンを1つ消費できるか判定する
+func (l *Limiter) Allow(key string) Result {
+	if l.rule.Disabled() {
+		return Result{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	capacity := float64(l.rule.Burst)
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+l.refill(now.Sub(b.last)))
+	b.last = now
+
+	result := Result{Limit: l.rule.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(capacity - b.tokens)
+	return result
+}
+
+// refill は経過時間に補充されるトークン数を返す
+func (l *Limiter) refill(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(elapsed) / float64(l.rule.Per) * float64(l.rule.Burst)
+}
+
+// duration はトークンが n 個補充されるまでの時間を返す
+func (l *Limiter) duration(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(n / float64(l.rule.Burst) * float64(l.rule.Per)))
+}
+
+// sweep は満杯まで補充されたバケットを削除して、使われなくなったキーでメモリが増え続けないようにする
+// 満杯のバケットは新しく作り直しても同じ状態になる
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+l.refill(now.Sub(b.last)) >= float64(l.rule.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/auth"
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// fakeClock はテストで進める時計
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestLimiter は clock の時刻で動くLimiterを返す
+func newTestLimiter(rule Rule, clock *fakeClock) *Limiter {
+	l := NewLimiter(rule)
+	l.now = clock.now
+	return l
+}
+
+// TestParseRule は設定の読み込みを確認する
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rule
+		wantErr bool
+	}{
+		{"60/m", Rule{Burst: 60, Per: time.Minute}, false},
+		{" 5 / s ", Rule{Burst: 5, Per: time.Second}, false},
+		{"100/h", Rule{Burst: 100, Per: time.Hour}, false},
+		{"10/30s", Rule{Burst: 10, Per: 30 * time.Second}, false},
+		{"0", Rule{}, false},
+		{"60", Rule{}, true},
+		{"-1/m", Rule{}, true},
+		{"x/m", Rule{}, true},
+		{"10/0s", Rule{}, true},
+		{"10/d", Rule{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRule(%q): err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRule(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if err == nil {
+			if again, _ := ParseRule(got.String()); again != got {
+				t.Errorf("ParseRule(%q).String() = %q を読み直せません", tt.in, got.String())
+			}
+		}
+	}
+}
+
+// TestAllow はトークンの消費と補充を確認する
+func TestAllow(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newTestLimiter(Rule{Burst: 2, Per: 10 * time.Second}, clock)
+
+	tests := []struct {
+		name          string
+		key           string
+		advance       time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{"1回目", "a", 0, true, 1, 0},
+		{"2回目", "a", 0, true, 0, 0},
+		{"容量を超える", "a", 0, false, 0, 5 * time.Second},
+		{"別のキーは数えない", "b", 0, true, 1, 0},
+		{"補充の途中", "a", 4 * time.Second, false, 0, time.Second},
+		{"1つ補充された", "a", time.Second, true, 0, 0},
+		{"長く空いても容量を超えて貯まらない", "a", time.Hour, true, 1, 0},
+	}
+	for _, tt := range tests {
+		clock.advance(tt.advance)
+		got := l.Allow(tt.key)
+		if got.Allowed != tt.wantAllowed || got.Remaining != tt.wantRemaining || got.RetryAfter != tt.wantRetry || got.Limit != 2 {
+			t.Errorf("%s: Allow() = %+v, want allowed=%v remaining=%d retry=%v", tt.name, got, tt.wantAllowed, tt.wantRemaining, tt.wantRetry)
+		}
+	}
+
+	if got := NewLimiter(Rule{}).Allow("a"); !got.Allowed {
+		t.Error("無効な設定のLimiterが拒否しました")
+	}
+}
+
+// TestSweep は満杯に戻ったバケットだけを削除することを確認する
+func TestSweep(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newTestLimiter(Rule{Burst: 1, Per: time.Hour}, clock)
+	l.Allow("old")
+	clock.advance(2 * time.Hour)
+	l.Allow("recent")
+	clock.advance(sweepInterval)
+	l.Allow("other")
+	if _, ok := l.buckets["old"]; ok {
+		t.Error("満杯に戻ったバケットが残っています")
+	}
+	if _, ok := l.buckets["recent"]; !ok {
+		t.Error("補充中のバケットが削除されました")
+	}
+}
+
+// TestMiddleware はユーザー・IPアドレスごとの制限と、429のレスポンスを確認する
+func TestMiddleware(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newTestLimiter(Rule{Burst: 1, Per: time.Minute}, clock)
+	handler := Middleware(nil, func(*http.Request) *Limiter { return l })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	alice := &data.User{ID: "alice"}
+	tests := []struct {
+		name          string
+		method        string
+		remote        string
+		user          *data.User
+		want          int
+		wantRemaining string
+	}{
+		{"1回目", http.MethodGet, "203.0.113.5:1", nil, http.StatusNoContent, "0"},
+		{"同じIPアドレス", http.MethodGet, "203.0.113.5:2", nil, http.StatusTooManyRequests, "0"},
+		{"別のIPアドレス", http.MethodGet, "203.0.113.6:1", nil, http.StatusNoContent, "0"},
+		{"ログイン中はユーザーごと", http.MethodGet, "203.0.113.5:1", alice, http.StatusNoContent, "0"},
+		{"同じユーザー", http.MethodGet, "203.0.113.7:1", alice, http.StatusTooManyRequests, "0"},
+		{"OPTIONS は数えない", http.MethodOptions, "203.0.113.5:1", nil, http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/themes", nil)
+		req.RemoteAddr = tt.remote
+		if tt.user != nil {
+			req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+			continue
+		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("%s: X-RateLimit-Remaining = %q, want %q", tt.name, got, tt.wantRemaining)
+		}
+		if tt.want != http.StatusTooManyRequests {
+			continue
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "1" {
+			t.Errorf("%s: X-RateLimit-Limit = %q", tt.name, got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Reset"); got != "60" {
+			t.Errorf("%s: X-RateLimit-Reset = %q", tt.name, got)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "60" {
+			t.Errorf("%s: Retry-After = %q", tt.name, got)
+		}
+		var body struct {
+			Error      string `json:"error"`
+			RetryAfter int    `json:"retry_after"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v\n%s", tt.name, err, rec.Body.String())
+		}
+		if body.Error == "" || body.RetryAfter != 60 {
+			t.Errorf("%s: body = %s", tt.name, rec.Body.String())
+		}
+	}
+}
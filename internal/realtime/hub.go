@@ -0,0 +1,205 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// イベントの種類
+const (
+	EventThemeCreated  = "theme.created"
+	EventThemeUpdated  = "theme.updated"
+	EventThemeDeleted  = "theme.deleted"
+	EventAnswerCreated = "answer.created"
+	EventAnswerUpdated = "answer.updated"
+	EventAnswerDeleted = "answer.deleted"
+	EventLikesChanged  = "answer.likes_changed"
+)
+
+// subscriberBuffer は購読者ごとに溜めておけるイベント数
+// これを超えて受信が追いつかない購読者は切断する
+const subscriberBuffer = 64
+
+// replayBufferSize は再接続時の再送用に保持しておく直近のイベント数
+const replayBufferSize = 256
+
+// Event は購読者に配信する変更通知
+// ID はハブが配信順に振る連番（サーバーの再起動で1から振り直す）
+type Event struct {
+	ID       uint64       `json:"id"`
+	Type     string       `json:"type"`
+	ThemeID  string       `json:"theme_id"`
+	AnswerID string       `json:"answer_id,omitempty"`
+	Theme    *data.Theme  `json:"theme,omitempty"`
+	Answer   *data.Answer `json:"answer,omitempty"`
+	Likes    *int         `json:"likes,omitempty"`
+	Time     time.Time    `json:"time"`
+}
+
+// Subscriber は1つの接続に対応する購読
+// C はハブが閉じた場合（受信の遅延やサーバー停止）にcloseされる
+type Subscriber struct {
+	C       <-chan Event
+	ch      chan Event
+	themeID string
+	dropped bool
+}
+
+// Dropped は受信が追いつかずにハブから切断されたか判定する
+// C がcloseされた後に呼ぶこと
+func (s *Subscriber) Dropped() bool {
+	return s.dropped
+}
+
+// Hub はお題ごとの購読者にイベントを配信するpub/sub
+type Hub struct {
+	mu      sync.Mutex
+	topics  map[string]map[*Subscriber]struct{} // お題ID（空文字列は全てのお題） -> 購読者
+	lastID  uint64                              // 最後に振ったイベントID
+	history []Event                             // 直近のイベント（古い順、最大 replayBufferSize 件）
+	closed  bool
+}
+
+// NewHub は新しいHubインスタンスを返す
+func NewHub() *Hub {
+	return &Hub{topics: make(map[string]map[*Subscriber]struct{})}
+}
+
+// Subscribe はお題のイベントを購読する（themeID が空なら全てのお題）
+func (h *Hub) Subscribe(themeID string) *Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.add(themeID)
+}
+
+// SubscribeSince はイベントID lastID より後のイベントから購読する
+// 取りこぼしたイベントを古い順に返し、購読開始後のイベントは C に届く
+// lastID 以降のイベントが既に破棄されている（またはIDが未知の）場合、ok は false になる
+func (h *Hub) SubscribeSince(themeID string, lastID uint64) (sub *Subscriber, missed []Event, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ok = lastID <= h.lastID
+	if len(h.history) > 0 && lastID+1 < h.history[0].ID {
+		ok = false
+	}
+	for _, ev := range h.history {
+		if ev.ID > lastID && matches(themeID, ev) {
+			missed = append(missed, ev)
+		}
+	}
+	return h.add(themeID), missed, ok
+}
+
+// LastID は最後に配信したイベントのIDを返す
+func (h *Hub) LastID() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.lastID
+}
+
+// add は購読者を登録する（h.muを取得して呼ぶこと）
+func (h *Hub) add(themeID string) *Subscriber {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscriber{C: ch, ch: ch, themeID: themeID}
+
+	if h.closed {
+		close(ch)
+		return sub
+	}
+	if h.topics[themeID] == nil {
+		h.topics[themeID] = make(map[*Subscriber]struct{})
+	}
+	h.topics[themeID][sub] = struct{}{}
+	return sub
+}
+
+// matches はイベントが themeID の購読対象か判定する（themeID が空なら全て）
+func matches(themeID string, ev Event) bool {
+	return themeID == "" || themeID == ev.ThemeID
+}
+
+// Unsubscribe は購読を解除する（既に解除済みでもよい）
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(sub)
+}
+
+// remove は購読者を取り除いてチャネルを閉じる（h.muを取得して呼ぶこと）
+func (h *Hub) remove(sub *Subscriber) {
+	subs, exists := h.topics[sub.themeID]
+	if !exists {
+		return
+	}
+	if _, exists := subs[sub]; !exists {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.topics, sub.themeID)
+	}
+	close(sub.ch)
+}
+
+// Publish はイベントにIDを振り、該当するお題の購読者と全体の購読者に配信する
+// 配信はブロックせず、バッファが一杯の購読者は切断する
+func (h *Hub) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	ev.ID = h.lastID
+	h.history = append(h.history, ev)
+	if len(h.history) > replayBufferSize {
+		h.history = h.history[len(h.history)-replayBufferSize:]
+	}
+
+	for _, topic := range []string{ev.ThemeID, ""} {
+		for sub := range h.topics[topic] {
+			select {
+			case sub.ch <- ev:
+			default:
+				sub.dropped = true
+				h.remove(sub)
+			}
+		}
+		if ev.ThemeID == "" {
+			break
+		}
+	}
+}
+
+// Count は現在の購読者数を返す
+func (h *Hub) Count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for _, subs := range h.topics {
+		n += len(subs)
+	}
+	return n
+}
+
+// Close は全ての購読を終了し、以降の購読を受け付けない
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.topics {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+	h.closed = true
+}
@@ -0,0 +1,195 @@
+package realtime
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// receive はチャネルに溜まっているイベントを全て取り出す
+func receive(sub *Subscriber) (events []Event, closed bool) {
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				return events, true
+			}
+			events = append(events, ev)
+		default:
+			return events, false
+		}
+	}
+}
+
+// TestPublish はお題の購読者と全体の購読者にだけ配信することを確認する
+func TestPublish(t *testing.T) {
+	hub := NewHub()
+	theme1 := hub.Subscribe("theme_1")
+	theme2 := hub.Subscribe("theme_2")
+	all := hub.Subscribe("")
+
+	hub.Publish(Event{Type: EventAnswerCreated, ThemeID: "theme_1", AnswerID: "answer_1"})
+
+	tests := []struct {
+		name string
+		sub  *Subscriber
+		want int
+	}{
+		{"同じお題の購読者", theme1, 1},
+		{"別のお題の購読者", theme2, 0},
+		{"全体の購読者", all, 1},
+	}
+	for _, tt := range tests {
+		events, closed := receive(tt.sub)
+		if len(events) != tt.want || closed {
+			t.Errorf("%s: %d 件 (closed=%v), want %d 件", tt.name, len(events), closed, tt.want)
+		}
+		for _, ev := range events {
+			if ev.Time.IsZero() {
+				t.Errorf("%s: イベントの時刻が設定されていません", tt.name)
+			}
+		}
+	}
+}
+
+// TestPublishDropsSlowSubscriber はバッファが一杯の購読者だけを切断することを確認する
+func TestPublishDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Subscribe("theme_1")
+	fast := hub.Subscribe("theme_1")
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.Publish(Event{Type: EventAnswerCreated, ThemeID: "theme_1"})
+		if i < subscriberBuffer {
+			receive(fast)
+		}
+	}
+
+	events, closed := receive(slow)
+	if len(events) != subscriberBuffer || !closed {
+		t.Fatalf("遅い購読者: %d 件 (closed=%v), want %d 件で切断", len(events), closed, subscriberBuffer)
+	}
+	if !slow.Dropped() {
+		t.Error("遅い購読者が Dropped になっていません")
+	}
+	if events, closed := receive(fast); len(events) != 1 || closed || fast.Dropped() {
+		t.Errorf("追いついている購読者: %d 件 (closed=%v, dropped=%v), want 1 件", len(events), closed, fast.Dropped())
+	}
+	if n := hub.Count(); n != 1 {
+		t.Errorf("購読者数 = %d, want 1", n)
+	}
+
+	// 切断済みの購読者の解除は何もしない
+	hub.Unsubscribe(slow)
+}
+
+// TestClose はハブを閉じると全ての購読が終了し、切断扱いにはならないことを確認する
+func TestClose(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe("theme_1")
+	hub.Close()
+
+	if _, closed := receive(sub); !closed || sub.Dropped() {
+		t.Errorf("closed=%v dropped=%v, want closed で切断扱いではない", closed, sub.Dropped())
+	}
+	if _, closed := receive(hub.Subscribe("theme_1")); !closed {
+		t.Error("閉じたハブの購読がすぐに終了しません")
+	}
+}
+
+// TestPublishingStore はストアの変更が成功したときだけイベントを配信することを確認する
+func TestPublishingStore(t *testing.T) {
+	hub := NewHub()
+	store := NewPublishingStore(data.NewInMemoryStore(), hub)
+	theme := &data.Theme{Title: "お題", Active: true}
+	if err := store.CreateTheme(theme); err != nil {
+		t.Fatal(err)
+	}
+	sub := hub.Subscribe(theme.ID)
+
+	answer := &data.Answer{ThemeID: theme.ID, Content: "回答"}
+	if err := store.CreateAnswer(answer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LikeAnswer(answer.ID, theme.ID, "voter"); err != nil {
+		t.Fatal(err)
+	}
+	// 失敗した変更は配信しない
+	if err := store.DeleteAnswer("missing", theme.ID, 0); err == nil {
+		t.Fatal("存在しない回答を削除できました")
+	}
+	if err := store.DeleteAnswer(answer.ID, theme.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := receive(sub)
+	want := []string{EventAnswerCreated, EventLikesChanged, EventAnswerDeleted}
+	if len(events) != len(want) {
+		t.Fatalf("%d 件のイベント, want %d 件: %+v", len(events), len(want), events)
+	}
+	for i, ev := range events {
+		if ev.Type != want[i] || ev.AnswerID != answer.ID {
+			t.Errorf("%d 件目 = %s %s, want %s %s", i, ev.Type, ev.AnswerID, want[i], answer.ID)
+		}
+	}
+	if likes := events[1].Likes; likes == nil || *likes != 1 {
+		t.Errorf("likes = %v, want 1", likes)
+	}
+}
+
+// TestSubscribeSince は指定したID以降の取りこぼしを返し、再送できない場合は ok が false になることを確認する
+func TestSubscribeSince(t *testing.T) {
+	hub := NewHub()
+	for _, themeID := range []string{"theme_1", "theme_1", "theme_2", "theme_1"} {
+		hub.Publish(Event{Type: EventAnswerCreated, ThemeID: themeID}) // id 1〜4
+	}
+
+	tests := []struct {
+		name    string
+		themeID string
+		lastID  uint64
+		want    []uint64
+		wantOK  bool
+	}{
+		{"最初から", "", 0, []uint64{1, 2, 3, 4}, true},
+		{"途中から", "", 2, []uint64{3, 4}, true},
+		{"お題で絞り込む", "theme_1", 1, []uint64{2, 4}, true},
+		{"取りこぼし無し", "", 4, nil, true},
+		{"未知のID", "", 5, nil, false},
+	}
+	for _, tt := range tests {
+		sub, missed, ok := hub.SubscribeSince(tt.themeID, tt.lastID)
+		hub.Unsubscribe(sub)
+		var got []uint64
+		for _, ev := range missed {
+			got = append(got, ev.ID)
+		}
+		if ok != tt.wantOK || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: %v ok=%v, want %v ok=%v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestSubscribeSinceOverflow は保持件数を超えて破棄されたイベントは再送できないことを確認する
+func TestSubscribeSinceOverflow(t *testing.T) {
+	hub := NewHub()
+	for i := 0; i < replayBufferSize+2; i++ {
+		hub.Publish(Event{Type: EventAnswerCreated, ThemeID: "theme_1"})
+	}
+
+	// id 1, 2 は破棄済み
+	if _, _, ok := hub.SubscribeSince("", 1); ok {
+		t.Error("破棄されたイベントから再送できることになっています")
+	}
+	sub, missed, ok := hub.SubscribeSince("", 2)
+	if !ok || len(missed) != replayBufferSize || missed[0].ID != 3 {
+		t.Errorf("保持している最古のイベントから: %d 件 ok=%v", len(missed), ok)
+	}
+
+	// 購読開始後のイベントは C に届く
+	hub.Publish(Event{Type: EventAnswerCreated, ThemeID: "theme_1"})
+	if events, _ := receive(sub); len(events) != 1 || events[0].ID != hub.LastID() {
+		t.Errorf("購読開始後のイベント: %+v", events)
+	}
+}
@@ -0,0 +1,124 @@
+package realtime
+
+import "github.com/nicest414/ogiri-server/internal/data"
+
+// PublishingStore はお題・回答の変更が成功したときにハブへイベントを配信するDataStore
+// 元のストアをそのまま包むので、どのバックエンドでも使える
+// 承認されていないお題と、確認待ち・非表示の回答は一般のユーザーに見せないため配信しない
+type PublishingStore struct {
+	data.DataStore
+	hub *Hub
+}
+
+// NewPublishingStore は新しいPublishingStoreインスタンスを返す
+func NewPublishingStore(store data.DataStore, hub *Hub) *PublishingStore {
+	return &PublishingStore{DataStore: store, hub: hub}
+}
+
+// snapshotTheme は配信用にお題をコピーする
+func snapshotTheme(theme *data.Theme) *data.Theme {
+	copied := *theme
+	return &copied
+}
+
+// CreateTheme はお題を作成して theme.created を配信する
+func (s *PublishingStore) CreateTheme(theme *data.Theme) error {
+	if err := s.DataStore.CreateTheme(theme); err != nil {
+		return err
+	}
+	if !theme.Approved() {
+		return nil
+	}
+	s.hub.Publish(Event{Type: EventThemeCreated, ThemeID: theme.ID, Theme: snapshotTheme(theme)})
+	return nil
+}
+
+// UpdateTheme はお題を更新して theme.updated を配信する
+func (s *PublishingStore) UpdateTheme(theme *data.Theme) error {
+	if err := s.DataStore.UpdateTheme(theme); err != nil {
+		return err
+	}
+	if !theme.Approved() {
+		// 審査待ち・却下になったお題は一般のユーザーからは削除されたのと同じに見える
+		s.hub.Publish(Event{Type: EventThemeDeleted, ThemeID: theme.ID})
+		return nil
+	}
+	s.hub.Publish(Event{Type: EventThemeUpdated, ThemeID: theme.ID, Theme: snapshotTheme(theme)})
+	return nil
+}
+
+// DeleteTheme はお題を削除して theme.deleted を配信する（お題の回答も削除済みとして扱う）
+func (s *PublishingStore) DeleteTheme(id string, version int64) error {
+	if err := s.DataStore.DeleteTheme(id, version); err != nil {
+		return err
+	}
+	s.hub.Publish(Event{Type: EventThemeDeleted, ThemeID: id})
+	return nil
+}
+
+// snapshot は配信用に回答をコピーする（呼び出し元での変更が配信内容に影響しないように）
+func snapshot(answer *data.Answer) *data.Answer {
+	copied := *answer
+	copied.LikedByMe = false
+	return &copied
+}
+
+// CreateAnswer は回答を作成して answer.created を配信する
+func (s *PublishingStore) CreateAnswer(answer *data.Answer) error {
+	if err := s.DataStore.CreateAnswer(answer); err != nil {
+		return err
+	}
+	if answer.Moderation != data.ModerationNone {
+		return nil
+	}
+	s.hub.Publish(Event{Type: EventAnswerCreated, ThemeID: answer.ThemeID, AnswerID: answer.ID, Answer: snapshot(answer)})
+	return nil
+}
+
+// UpdateAnswer は回答を更新して answer.updated を配信する
+func (s *PublishingStore) UpdateAnswer(answer *data.Answer) error {
+	if err := s.DataStore.UpdateAnswer(answer); err != nil {
+		return err
+	}
+	if answer.Moderation != data.ModerationNone {
+		// 確認待ち・非表示になった回答は一般のユーザーからは削除されたのと同じに見える
+		s.hub.Publish(Event{Type: EventAnswerDeleted, ThemeID: answer.ThemeID, AnswerID: answer.ID})
+		return nil
+	}
+	s.hub.Publish(Event{Type: EventAnswerUpdated, ThemeID: answer.ThemeID, AnswerID: answer.ID, Answer: snapshot(answer)})
+	return nil
+}
+
+// DeleteAnswer は回答を削除して answer.deleted を配信する
+func (s *PublishingStore) DeleteAnswer(id string, themeID string, version int64) error {
+	if err := s.DataStore.DeleteAnswer(id, themeID, version); err != nil {
+		return err
+	}
+	s.hub.Publish(Event{Type: EventAnswerDeleted, ThemeID: themeID, AnswerID: id})
+	return nil
+}
+
+// LikeAnswer はいいねを記録して answer.likes_changed を配信する
+func (s *PublishingStore) LikeAnswer(id string, themeID string, voterID string) (*data.Answer, error) {
+	answer, err := s.DataStore.LikeAnswer(id, themeID, voterID)
+	if err != nil {
+		return nil, err
+	}
+	s.publishLikes(answer)
+	return answer, nil
+}
+
+// UnlikeAnswer はいいねを取り消して answer.likes_changed を配信する
+func (s *PublishingStore) UnlikeAnswer(id string, themeID string, voterID string) (*data.Answer, error) {
+	answer, err := s.DataStore.UnlikeAnswer(id, themeID, voterID)
+	if err != nil {
+		return nil, err
+	}
+	s.publishLikes(answer)
+	return answer, nil
+}
+
+func (s *PublishingStore) publishLikes(answer *data.Answer) {
+	likes := answer.Likes
+	s.hub.Publish(Event{Type: EventLikesChanged, ThemeID: answer.ThemeID, AnswerID: answer.ID, Likes: &likes})
+}
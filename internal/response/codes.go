@@ -0,0 +1,79 @@
+package response
+
+// Code は機械で判別できるエラーコード
+// 一度公開したコードの意味は変えないこと（メッセージは変わることがある）
+type Code string
+
+// リクエスト全般のエラー
+const (
+	CodeInvalidJSON      Code = "INVALID_JSON"        // ボディがJSONとして読めない
+	CodeBodyTooLarge     Code = "BODY_TOO_LARGE"      // ボディが大きすぎる
+	CodeValidationFailed Code = "VALIDATION_FAILED"   // 入力の項目が不正（details に項目ごとの内容）
+	CodeInvalidQuery     Code = "INVALID_QUERY"       // クエリパラメータが不正（details に項目ごとの内容）
+	CodeInvalidCursor    Code = "INVALID_CURSOR"      // cursor が不正
+	CodeUnauthorized     Code = "UNAUTHORIZED"        // ログインが必要
+	CodeForbidden        Code = "FORBIDDEN"           // 権限が無い
+	CodeNotFound         Code = "NOT_FOUND"           // ルートが存在しない
+	CodeMethodNotAllowed Code = "METHOD_NOT_ALLOWED"  // ルートがこのメソッドに対応していない
+	CodePrecondition     Code = "PRECONDITION_FAILED" // If-Match のバージョンが古い
+	CodeVersionConflict  Code = "VERSION_CONFLICT"    // 読み込んだ後に他の操作で更新された
+	CodeRateLimited      Code = "RATE_LIMITED"        // リクエストが多すぎる
+	CodeInternal         Code = "INTERNAL_ERROR"      // サーバー内部のエラー
+)
+
+// ユーザー関連のエラー
+const (
+	CodeInvalidCredentials Code = "INVALID_CREDENTIALS" // ユーザー名またはパスワードが違う
+	CodeUsernameTaken      Code = "USERNAME_TAKEN"
+	CodeUserNotFound       Code = "USER_NOT_FOUND"
+	CodeLastAdmin          Code = "LAST_ADMIN" // 最後の管理者は降格できない
+)
+
+// お題関連のエラー
+const (
+	CodeThemeNotFound    Code = "THEME_NOT_FOUND"
+	CodeThemeNotOpen     Code = "THEME_NOT_OPEN"     // 受付開始前
+	CodeThemeClosed      Code = "THEME_CLOSED"       // 受付終了
+	CodeThemeInactive    Code = "THEME_INACTIVE"     // 受付停止中
+	CodeThemeNotApproved Code = "THEME_NOT_APPROVED" // 審査中
+)
+
+// 回答・いいね・通報関連のエラー
+const (
+	CodeAnswerNotFound      Code = "ANSWER_NOT_FOUND"
+	CodeClientTokenRequired Code = "CLIENT_TOKEN_REQUIRED" // 匿名の投票者・通報者を識別できない
+	CodeAlreadyLiked        Code = "ALREADY_LIKED"
+	CodeNotLiked            Code = "NOT_LIKED"
+	CodeAlreadyReported     Code = "ALREADY_REPORTED"
+	CodeInappropriate       Code = "INAPPROPRIATE_CONTENT" // NGワードを含む
+)
+
+// 入力の項目ごとのエラー（Detail.Code）
+const (
+	CodeRequired      Code = "REQUIRED"       // 必須の項目が無い
+	CodeInvalidValue  Code = "INVALID_VALUE"  // 値が不正
+	CodeInvalidWindow Code = "INVALID_WINDOW" // closes_at が opens_at より前
+	CodeTooShort      Code = "TOO_SHORT"
+	CodeTooLong       Code = "TOO_LONG"
+	CodeNGWord        Code = "NG_WORD"           // NGワードを含む
+	CodeBlank         Code = "BLANK"             // 空白だけ
+	CodeControlChar   Code = "CONTROL_CHARACTER" // 使えない制御文字を含む
+	CodeUnknownField  Code = "UNKNOWN_FIELD"     // 受け付けていない項目
+	CodeDuplicateID   Code = "DUPLICATE_ID"      // インポートするIDが既にある（ストアまたはファイルの前の行）
+)
+
+// Codes は公開している全てのエラーコード（ドキュメント用）
+var Codes = []Code{
+	CodeInvalidJSON, CodeBodyTooLarge, CodeValidationFailed, CodeInvalidQuery, CodeInvalidCursor,
+	CodeUnauthorized, CodeForbidden, CodeNotFound, CodeMethodNotAllowed,
+	CodePrecondition, CodeVersionConflict, CodeRateLimited, CodeInternal,
+	CodeInvalidCredentials, CodeUsernameTaken, CodeUserNotFound, CodeLastAdmin,
+	CodeThemeNotFound, CodeThemeNotOpen, CodeThemeClosed, CodeThemeInactive, CodeThemeNotApproved,
+	CodeAnswerNotFound, CodeClientTokenRequired, CodeAlreadyLiked, CodeNotLiked, CodeAlreadyReported, CodeInappropriate,
+}
+
+// DetailCodes は入力の項目ごとのエラーコード（ドキュメント用）
+var DetailCodes = []Code{
+	CodeRequired, CodeInvalidValue, CodeInvalidWindow, CodeTooShort, CodeTooLong, CodeNGWord,
+	CodeBlank, CodeControlChar, CodeUnknownField, CodeDuplicateID,
+}
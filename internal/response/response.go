@@ -0,0 +1,191 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/nicest414/ogiri-server/internal/logging"
+)
+
+// Format はレスポンスの形式
+type Format string
+
+const (
+	// FormatEnvelope は全てのレスポンスを {"success", "message", "data"} で包み、
+	// エラーはコード・メッセージ・項目ごとの詳細・リクエストIDを返す
+	FormatEnvelope Format = "envelope"
+	// FormatLegacy は以前の形式（一部のエンドポイントは本文をそのまま返し、エラーは {"error": "..."}）
+	FormatLegacy Format = "legacy"
+)
+
+// FormatHeader はリクエストごとにレスポンスの形式を選ぶヘッダー（legacy または envelope）
+const FormatHeader = "X-Response-Format"
+
+// Valid は形式が定義されたものか判定する
+func (f Format) Valid() bool {
+	return f == FormatEnvelope || f == FormatLegacy
+}
+
+var defaultFormat atomic.Value
+
+// 既存のクライアントを壊さないよう、既定は以前の形式にする
+func init() {
+	defaultFormat.Store(FormatLegacy)
+}
+
+// SetDefaultFormat は X-Response-Format ヘッダーが無いリクエストに使う形式を設定する
+func SetDefaultFormat(f Format) {
+	defaultFormat.Store(f)
+}
+
+// FormatOf はリクエストに返すレスポンスの形式を返す
+func FormatOf(r *http.Request) Format {
+	if f := Format(strings.ToLower(r.Header.Get(FormatHeader))); f.Valid() {
+		return f
+	}
+	return defaultFormat.Load().(Format)
+}
+
+// Envelope はAPIのレスポンスの共通の形式
+type Envelope struct {
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	NextCursor *string     `json:"next_cursor,omitempty"` // ページングする一覧のみ（最後のページなら空文字列）
+	Error      *Error      `json:"error,omitempty"`
+	RequestID  string      `json:"request_id,omitempty"` // エラーのみ
+}
+
+// Detail は入力の項目ごとのエラー
+type Detail struct {
+	Line    int    `json:"line,omitempty"` // インポートのみ。問題のあった行の番号（1から）
+	Field   string `json:"field"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error はエラーレスポンスの内容
+type Error struct {
+	Status     int      `json:"-"`
+	Code       Code     `json:"code"`
+	Message    string   `json:"message"`
+	Details    []Detail `json:"details,omitempty"`
+	RetryAfter int      `json:"retry_after,omitempty"` // 再度リクエストできるまでの秒数（429のみ）
+
+	// Legacy は以前の形式のレスポンスにだけ加える項目
+	Legacy map[string]interface{} `json:"-"`
+}
+
+// NewError は新しいエラーを返す
+func NewError(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Errorf はメッセージを書式化したエラーを返す
+func Errorf(status int, code Code, format string, args ...interface{}) *Error {
+	return NewError(status, code, fmt.Sprintf(format, args...))
+}
+
+// Invalid は入力の項目が不正なことを表す400エラーを返す
+// メッセージは最初の詳細のものを使う
+func Invalid(code Code, details ...Detail) *Error {
+	e := NewError(http.StatusBadRequest, code, "入力内容に誤りがあります")
+	if len(details) > 0 {
+		e.Message = details[0].Message
+	}
+	e.Details = details
+	return e
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// JSON は v をそのままJSONで書き出す（v が nil なら本文なし）
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v != nil {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// Write は成功のレスポンスを共通の形式で書き出す（互換モードでも同じ形式）
+func Write(w http.ResponseWriter, status int, message string, data interface{}) {
+	JSON(w, status, &Envelope{Success: true, Message: message, Data: data})
+}
+
+// NextCursorHeader は続きのページを取得するときに cursor に指定する値を返すヘッダー（最後のページなら付けない）
+const NextCursorHeader = "X-Next-Cursor"
+
+// setNextPage は続きのページがあれば X-Next-Cursor と Link（rel="next"）ヘッダーを付ける
+// 互換モードでは本文に next_cursor を入れられないため、ヘッダーで続きを知らせる
+func setNextPage(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	next := *r.URL
+	q := next.Query()
+	q.Set("cursor", nextCursor)
+	next.RawQuery = q.Encode()
+	w.Header().Set(NextCursorHeader, nextCursor)
+	w.Header().Set("Link", "<"+next.RequestURI()+`>; rel="next"`)
+}
+
+// WritePage はページングする一覧を共通の形式で書き出す
+// 互換モードでは WriteData と同じく data だけをそのまま返し、続きのページは X-Next-Cursor・Link ヘッダーで知らせる
+func WritePage(w http.ResponseWriter, r *http.Request, message string, data interface{}, nextCursor string) {
+	setNextPage(w, r, nextCursor)
+	if FormatOf(r) == FormatLegacy {
+		JSON(w, http.StatusOK, data)
+		return
+	}
+	JSON(w, http.StatusOK, &Envelope{Success: true, Message: message, Data: data, NextCursor: &nextCursor})
+}
+
+// WriteEnvelopePage は WritePage と同じだが、互換モードでも共通の形式で書き出す
+// 以前から {"success", "message", "data"} で返していた一覧に使う
+func WriteEnvelopePage(w http.ResponseWriter, r *http.Request, message string, data interface{}, nextCursor string) {
+	setNextPage(w, r, nextCursor)
+	JSON(w, http.StatusOK, &Envelope{Success: true, Message: message, Data: data, NextCursor: &nextCursor})
+}
+
+// WriteData は成功のレスポンスを共通の形式で書き出す
+// 互換モードでは以前と同じく data だけをそのまま返す
+func WriteData(w http.ResponseWriter, r *http.Request, status int, message string, data interface{}) {
+	if FormatOf(r) == FormatLegacy {
+		JSON(w, status, data)
+		return
+	}
+	Write(w, status, message, data)
+}
+
+// NoContent は本文の無い204を返す
+func NoContent(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WriteError はエラーレスポンスを書き出す
+// ログと突き合わせられるよう、リクエストIDがあれば request_id として返す
+func WriteError(w http.ResponseWriter, r *http.Request, e *Error) {
+	requestID := w.Header().Get(logging.RequestIDHeader)
+	if FormatOf(r) == FormatLegacy {
+		body := map[string]interface{}{"error": e.Message}
+		if requestID != "" {
+			body["request_id"] = requestID
+		}
+		if e.RetryAfter > 0 {
+			body["retry_after"] = e.RetryAfter
+		}
+		for k, v := range e.Legacy {
+			body[k] = v
+		}
+		JSON(w, e.Status, body)
+		return
+	}
+	JSON(w, e.Status, &Envelope{Success: false, Error: e, RequestID: requestID})
+}
@@ -0,0 +1,110 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestFormats は各書き出し関数が共通の形式と互換モードでそれぞれ決められた形で返すことを確認する
+func TestFormats(t *testing.T) {
+	items := []string{"a", "b"}
+	tests := []struct {
+		name   string
+		format Format
+		write  func(w http.ResponseWriter, r *http.Request)
+		want   string
+		// wantNext は X-Next-Cursor ヘッダーの値、wantLink は Link ヘッダーの値
+		wantNext string
+		wantLink string
+	}{
+		{"WriteData・共通", FormatEnvelope, func(w http.ResponseWriter, r *http.Request) {
+			WriteData(w, r, http.StatusOK, "ok", items)
+		}, `{"success":true,"message":"ok","data":["a","b"]}`, "", ""},
+		{"WriteData・互換", FormatLegacy, func(w http.ResponseWriter, r *http.Request) {
+			WriteData(w, r, http.StatusOK, "ok", items)
+		}, `["a","b"]`, "", ""},
+		{"Write・互換でも共通", FormatLegacy, func(w http.ResponseWriter, r *http.Request) {
+			Write(w, http.StatusOK, "ok", items)
+		}, `{"success":true,"message":"ok","data":["a","b"]}`, "", ""},
+		{"WritePage・共通・続きあり", FormatEnvelope, func(w http.ResponseWriter, r *http.Request) {
+			WritePage(w, r, "ok", items, "c2")
+		}, `{"success":true,"message":"ok","data":["a","b"],"next_cursor":"c2"}`, "c2", `</api/items?cursor=c2&limit=2>; rel="next"`},
+		{"WritePage・共通・最後のページ", FormatEnvelope, func(w http.ResponseWriter, r *http.Request) {
+			WritePage(w, r, "ok", items, "")
+		}, `{"success":true,"message":"ok","data":["a","b"],"next_cursor":""}`, "", ""},
+		{"WritePage・互換・続きあり", FormatLegacy, func(w http.ResponseWriter, r *http.Request) {
+			WritePage(w, r, "ok", items, "c2")
+		}, `["a","b"]`, "c2", `</api/items?cursor=c2&limit=2>; rel="next"`},
+		{"WritePage・互換・最後のページ", FormatLegacy, func(w http.ResponseWriter, r *http.Request) {
+			WritePage(w, r, "ok", items, "")
+		}, `["a","b"]`, "", ""},
+		{"WriteEnvelopePage・互換でも共通", FormatLegacy, func(w http.ResponseWriter, r *http.Request) {
+			WriteEnvelopePage(w, r, "ok", items, "c2")
+		}, `{"success":true,"message":"ok","data":["a","b"],"next_cursor":"c2"}`, "c2", `</api/items?cursor=c2&limit=2>; rel="next"`},
+		{"WriteError・共通", FormatEnvelope, func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, NewError(http.StatusNotFound, CodeNotFound, "ない"))
+		}, `{"success":false,"error":{"code":"NOT_FOUND","message":"ない"}}`, "", ""},
+		{"WriteError・互換", FormatLegacy, func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, NewError(http.StatusNotFound, CodeNotFound, "ない"))
+		}, `{"error":"ない"}`, "", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/items?limit=2&cursor=c1", nil)
+		r.Header.Set(FormatHeader, string(tt.format))
+		rec := httptest.NewRecorder()
+		tt.write(rec, r)
+
+		if !jsonEqual(t, rec.Body.Bytes(), tt.want) {
+			t.Errorf("%s: body = %s, want %s", tt.name, rec.Body.String(), tt.want)
+		}
+		if got := rec.Header().Get(NextCursorHeader); got != tt.wantNext {
+			t.Errorf("%s: %s = %q, want %q", tt.name, NextCursorHeader, got, tt.wantNext)
+		}
+		if got := rec.Header().Get("Link"); got != tt.wantLink {
+			t.Errorf("%s: Link = %q, want %q", tt.name, got, tt.wantLink)
+		}
+	}
+}
+
+// TestDefaultFormat はヘッダーが無ければ既定の形式、あればヘッダーの形式を使うことを確認する
+func TestDefaultFormat(t *testing.T) {
+	defer SetDefaultFormat(FormatLegacy)
+
+	tests := []struct {
+		def    Format
+		header string
+		want   Format
+	}{
+		{FormatEnvelope, "", FormatEnvelope},
+		{FormatLegacy, "", FormatLegacy},
+		{FormatLegacy, "envelope", FormatEnvelope},
+		{FormatEnvelope, "LEGACY", FormatLegacy},
+		{FormatEnvelope, "unknown", FormatEnvelope},
+	}
+	for _, tt := range tests {
+		SetDefaultFormat(tt.def)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			r.Header.Set(FormatHeader, tt.header)
+		}
+		if got := FormatOf(r); got != tt.want {
+			t.Errorf("default %s, header %q: got %s, want %s", tt.def, tt.header, got, tt.want)
+		}
+	}
+}
+
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("JSONとして読めません: %v\n%s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	gb, _ := json.Marshal(g)
+	wb, _ := json.Marshal(w)
+	return string(gb) == string(wb)
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// DefaultInterval はお題の受付期間を確認する間隔
+const DefaultInterval = 10 * time.Second
+
+// Scheduler は opens_at・closes_at に従ってお題の Active を切り替える
+type Scheduler struct {
+	store    data.DataStore
+	interval time.Duration
+}
+
+// New は新しいSchedulerインスタンスを返す
+func New(store data.DataStore, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Scheduler{store: store, interval: interval}
+}
+
+// Run はctxがキャンセルされるまで定期的にお題の状態を更新する
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.Tick(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Tick(now)
+		}
+	}
+}
+
+// Tick は受付期間の境界を過ぎたお題の Active を切り替える
+func (s *Scheduler) Tick(now time.Time) {
+	themes, _, err := s.store.ListThemes(data.ListOptions{Submission: data.AllSubmissionStatuses})
+	if err != nil {
+		slog.Error("スケジューラー: お題の取得に失敗しました", "error", err)
+		return
+	}
+
+	for _, theme := range themes {
+		active, changed := theme.ScheduledActive(now)
+		if !changed {
+			continue
+		}
+
+		// ストアが返すポインタは共有されている場合があるため、コピーを更新する
+		updated := *theme
+		updated.Active = active
+		if err := s.store.UpdateTheme(&updated); err == data.ErrVersionConflict {
+			// 読み込んだ後に更新されたお題は、次の確認で改めて判定する
+			continue
+		} else if err != nil {
+			slog.Error("スケジューラー: お題の更新に失敗しました", "theme_id", theme.ID, "error", err)
+			continue
+		}
+		if active {
+			slog.Info("お題の受付を開始しました", "theme_id", theme.ID)
+		} else {
+			slog.Info("お題の受付を終了しました", "theme_id", theme.ID)
+		}
+	}
+}
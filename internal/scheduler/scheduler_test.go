@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+func at(t time.Time) *time.Time { return &t }
+
+// TestTick は受付期間の境界でお題の Active を切り替え、境界の後の手動の変更は上書きしないことを確認する
+func TestTick(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		opensAt  *time.Time
+		closesAt *time.Time
+		active   bool          // 作成時の Active
+		tick     time.Duration // now からの経過時間
+		want     bool
+	}{
+		{"開始前は受付しない", at(now.Add(time.Hour)), nil, false, 0, false},
+		{"開始日時で受付を始める", at(now.Add(time.Hour)), nil, false, time.Hour, true},
+		{"終了日時の直前は受付中", nil, at(now.Add(time.Hour)), true, time.Hour - time.Nanosecond, true},
+		{"終了日時で受付を終える", nil, at(now.Add(time.Hour)), true, time.Hour, false},
+		{"開始と終了を過ぎていれば受付しない", at(now.Add(time.Hour)), at(now.Add(2 * time.Hour)), false, 3 * time.Hour, false},
+		{"開始後に手動で停止したお題は再開しない", at(now.Add(-time.Hour)), nil, false, 0, false},
+		{"終了後に手動で再開したお題は停止しない", nil, at(now.Add(-time.Hour)), true, 0, true},
+		{"期間の無いお題は変えない", nil, nil, false, time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := data.NewInMemoryStore()
+			theme := &data.Theme{Title: "お題", Active: tt.active, OpensAt: tt.opensAt, ClosesAt: tt.closesAt}
+			if err := store.CreateTheme(theme); err != nil {
+				t.Fatal(err)
+			}
+
+			New(store, time.Minute).Tick(now.Add(tt.tick))
+			got, err := store.GetTheme(theme.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Active != tt.want {
+				t.Errorf("active = %v, want %v", got.Active, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// Filter はエクスポートするお題の条件（ゼロ値なら全てのお題）
+// 回答は条件に合うお題の回答を全て（確認待ち・非表示のものも）書き出す
+type Filter struct {
+	ThemeIDs      []string  // 空でなければ指定したお題だけ
+	CreatedAfter  time.Time // ゼロ値でなければ、この日時より後に作成したお題だけ
+	CreatedBefore time.Time // ゼロ値でなければ、この日時より前に作成したお題だけ
+}
+
+// Counts は書き出した・読み込んだお題と回答の数
+type Counts struct {
+	Themes  int `json:"themes"`
+	Answers int `json:"answers"`
+}
+
+// encoder はファイルの形式ごとの書き出し方
+type encoder interface {
+	encode(rec *Record) error
+	flush() error
+}
+
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) encode(rec *Record) error { return e.enc.Encode(rec) }
+func (e *jsonlEncoder) flush() error             { return nil }
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool // 項目名の行を書いたか
+}
+
+// writeHeader は最初に一度だけ項目名の行を書く
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.name
+	}
+	return e.w.Write(names)
+}
+
+func (e *csvEncoder) encode(rec *Record) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	row, err := rec.csvRow()
+	if err != nil {
+		return err
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvEncoder) flush() error {
+	// 1件も無くても項目名の行は書く
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func newEncoder(w io.Writer, format Format) encoder {
+	if format == FormatCSV {
+		return &csvEncoder{w: csv.NewWriter(w)}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonlEncoder{enc: enc}
+}
+
+// Export は条件に合うお題とその回答を format の形式で w に書き出す
+// お題の直後にそのお題の回答を続けるので、書き出したファイルはそのままインポートできる
+// 存在しないお題を指定した場合は、何も書き出さずに data.ErrNotFound を包んだエラーを返す
+func Export(store data.DataStore, w io.Writer, format Format, filter Filter) (Counts, error) {
+	var counts Counts
+	themes, err := selectThemes(store, filter)
+	if err != nil {
+		return counts, err
+	}
+
+	enc := newEncoder(w, format)
+	for _, theme := range themes {
+		if err := enc.encode(themeRecord(theme)); err != nil {
+			return counts, err
+		}
+		counts.Themes++
+
+		answers, _, err := store.ListAnswers(theme.ID, data.ListOptions{Moderation: data.AllModerationStates})
+		if err != nil {
+			return counts, fmt.Errorf("お題 %s の回答の取得に失敗しました: %w", theme.ID, err)
+		}
+		for _, answer := range answers {
+			if err := enc.encode(answerRecord(answer)); err != nil {
+				return counts, err
+			}
+			counts.Answers++
+		}
+	}
+	return counts, enc.flush()
+}
+
+// selectThemes は条件に合うお題を作成日時の古い順に返す（審査待ち・却下のものも含む）
+func selectThemes(store data.DataStore, filter Filter) ([]*data.Theme, error) {
+	if len(filter.ThemeIDs) == 0 {
+		themes, _, err := store.ListThemes(data.ListOptions{
+			Submission:    data.AllSubmissionStatuses,
+			CreatedAfter:  filter.CreatedAfter,
+			CreatedBefore: filter.CreatedBefore,
+		})
+		return themes, err
+	}
+
+	var themes []*data.Theme
+	seen := make(map[string]bool, len(filter.ThemeIDs))
+	for _, id := range filter.ThemeIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		theme, err := store.GetTheme(id)
+		if err != nil {
+			return nil, fmt.Errorf("お題 %s: %w", id, err)
+		}
+		if !filter.CreatedAfter.IsZero() && !theme.CreatedAt.After(filter.CreatedAfter) {
+			continue
+		}
+		if !filter.CreatedBefore.IsZero() && !theme.CreatedAt.Before(filter.CreatedBefore) {
+			continue
+		}
+		themes = append(themes, theme)
+	}
+	return themes, nil
+}
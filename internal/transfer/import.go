@@ -0,0 +1,383 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+	"github.com/nicest414/ogiri-server/internal/validation"
+)
+
+// インポートの制限
+const (
+	MaxLineBytes = 1 << 20 // JSON Lines の1行の最大バイト数
+	MaxErrors    = 100     // 報告する問題の最大数（超えたら読むのをやめる）
+)
+
+// utf8BOM は表計算ソフトなどがファイルの先頭に付けるBOM（読み飛ばす）
+var utf8BOM = []byte("\ufeff")
+
+// Options はインポートの指定
+type Options struct {
+	Format Format
+	// RegenerateIDs なら読み込む先のストアで新しいIDを振る
+	// 回答の theme_id が同じファイルのお題を指していれば、そのお題の新しいIDに置き換える
+	// false ならファイルのIDをそのまま使い、既にあるIDはエラーにする
+	RegenerateIDs bool
+	DryRun        bool // 検査だけを行い、保存しない
+}
+
+// Result はインポートの結果
+type Result struct {
+	DryRun bool `json:"dry_run"`
+	// 保存したお題と回答の数（DryRun なら、問題が無ければ保存される数）
+	Counts
+	// Errors は問題のあった行ごとの内容（Line に行番号）
+	// 1件でもあれば何も保存しない。MaxErrors 件を超えたところで読むのをやめる
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+// item は検査を終えたファイルの1件
+type item struct {
+	line   int
+	theme  *data.Theme  // お題なら nil でない
+	answer *data.Answer // 回答なら nil でない
+}
+
+// importer はファイルを読みながら1件ずつ検査する
+type importer struct {
+	store  data.DataStore
+	opts   Options
+	result *Result
+	items  []item
+	themes map[string]bool // ファイルの前の行にあったお題のID
+	ids    map[string]bool // ファイルの前の行にあった回答のID
+}
+
+// Import は r から format の形式のファイルを読み、全ての行を検査してから store に保存する
+// 検査で問題が見つかった場合は何も保存せず、Result.Errors に行ごとの内容を入れて返す
+// エラーを返すのは保存に失敗したときで、その場合も何も保存しない
+func Import(store data.DataStore, r io.Reader, opts Options) (*Result, error) {
+	im := &importer{
+		store:  store,
+		opts:   opts,
+		result: &Result{DryRun: opts.DryRun},
+		themes: make(map[string]bool),
+		ids:    make(map[string]bool),
+	}
+	if opts.Format == FormatCSV {
+		im.readCSV(r)
+	} else {
+		im.readJSONL(r)
+	}
+
+	if len(im.result.Errors) > 0 {
+		return im.result, nil
+	}
+	if opts.DryRun {
+		for _, it := range im.items {
+			im.result.count(it)
+		}
+		return im.result, nil
+	}
+	return im.result, im.save()
+}
+
+// count は保存した（DryRun なら保存される）数を数える
+func (res *Result) count(it item) {
+	if it.theme != nil {
+		res.Themes++
+	} else {
+		res.Answers++
+	}
+}
+
+// full は報告する問題が MaxErrors 件に達したか判定する
+func (im *importer) full() bool {
+	return len(im.result.Errors) >= MaxErrors
+}
+
+// fail は行の問題を追加する
+func (im *importer) fail(line int, errs validation.Errors) {
+	for _, d := range errs {
+		if im.full() {
+			return
+		}
+		d.Line = line
+		im.result.Errors = append(im.result.Errors, d)
+	}
+}
+
+// failLine は行全体の問題を追加する
+func (im *importer) failLine(line int, message string) {
+	var errs validation.Errors
+	errs.Add("", response.CodeInvalidValue, message)
+	im.fail(line, errs)
+}
+
+// readJSONL は1行に1件のJSONオブジェクトを読む（空行は読み飛ばす）
+func (im *importer) readJSONL(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), MaxLineBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		b := bytes.TrimSpace(scanner.Bytes())
+		if line == 1 {
+			b = bytes.TrimPrefix(b, utf8BOM)
+		}
+		if len(b) == 0 {
+			continue
+		}
+		im.decode(line, b)
+		if im.full() {
+			return
+		}
+	}
+	if err := scanner.Err(); errors.Is(err, bufio.ErrTooLong) {
+		im.failLine(line+1, fmt.Sprintf("1行は%dバイトまでです", MaxLineBytes))
+	} else if err != nil {
+		im.failLine(line+1, "読み込みに失敗しました: "+err.Error())
+	}
+}
+
+// readCSV は1行目を項目名として CSV を読む
+// 各行は項目名をキーにした JSON オブジェクトにしてから JSON Lines と同じように検査する（空の値は省略したものとみなす）
+func (im *importer) readCSV(r io.Reader) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	header, err := reader.Read()
+	if err == io.EOF {
+		return
+	}
+	if err != nil {
+		im.failLine(1, "CSVとして読めません: "+err.Error())
+		return
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], string(utf8BOM))
+	}
+	quoted := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		quoted[col.name] = col.quoted
+	}
+	var errs validation.Errors
+	for _, name := range header {
+		if _, ok := quoted[name]; !ok {
+			errs.Add(name, response.CodeUnknownField, fmt.Sprintf("%s は受け付けていない項目です", name))
+		}
+	}
+	if len(errs) > 0 {
+		im.fail(1, errs)
+		return
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) && errors.Is(perr.Err, csv.ErrFieldCount) {
+			im.failLine(perr.StartLine, fmt.Sprintf("項目の数が1行目と違います（%d個）", len(row)))
+			continue
+		}
+		if errors.As(err, &perr) {
+			im.failLine(perr.StartLine, "CSVとして読めません: "+perr.Err.Error())
+			return
+		}
+		if err != nil {
+			im.failLine(0, "読み込みに失敗しました: "+err.Error())
+			return
+		}
+		line, _ := reader.FieldPos(0)
+
+		fields := make(map[string]json.RawMessage, len(header))
+		for i, name := range header {
+			value := row[i]
+			if value == "" {
+				continue
+			}
+			if !quoted[name] && json.Valid([]byte(value)) {
+				fields[name] = json.RawMessage(value)
+			} else {
+				if quoted[name] {
+					value = unescapeCell(value)
+				}
+				fields[name], _ = json.Marshal(value)
+			}
+		}
+		b, _ := json.Marshal(fields)
+		im.decode(line, b)
+		if im.full() {
+			return
+		}
+	}
+}
+
+// decode は1件を読み込んで検査する
+func (im *importer) decode(line int, b []byte) {
+	var rec Record
+	errs, err := validation.Decode(b, &rec)
+	if err != nil {
+		im.failLine(line, "JSONオブジェクトとして読めません")
+		return
+	}
+	switch rec.Type {
+	case TypeTheme:
+		im.checkTheme(line, &rec, errs)
+	case TypeAnswer:
+		im.checkAnswer(line, &rec, errs)
+	case "":
+		errs.Add("type", response.CodeRequired, "type は必須です")
+		im.fail(line, errs)
+	default:
+		errs.Add("type", response.CodeInvalidValue, "type には theme または answer を指定してください")
+		im.fail(line, errs)
+	}
+}
+
+// checkTheme はお題の1件を検査する
+func (im *importer) checkTheme(line int, rec *Record, errs validation.Errors) {
+	notFor(&errs, "お題", map[string]bool{
+		"theme_id":   rec.ThemeID != "",
+		"content":    rec.Content != "",
+		"likes":      rec.Likes != 0,
+		"moderation": rec.Moderation != "",
+	})
+	theme := rec.theme()
+	if theme.SubmissionStatus == "" {
+		theme.SubmissionStatus = data.SubmissionApproved
+	} else if !theme.SubmissionStatus.Valid() {
+		errs.Add("submission_status", response.CodeInvalidValue, "submission_status には pending, approved, rejected のいずれかを指定してください")
+	}
+	im.checkID(&errs, rec.ID, im.themes, func() (bool, error) {
+		_, err := im.store.GetTheme(rec.ID)
+		if err == data.ErrNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	errs.Merge(validation.Theme(theme))
+	// 問題があっても、後の行の回答がこのお題を指していることは報告しない
+	if rec.ID != "" {
+		im.themes[rec.ID] = true
+	}
+	if len(errs) > 0 {
+		im.fail(line, errs)
+		return
+	}
+
+	setTimes(&theme.CreatedAt, &theme.UpdatedAt)
+	im.items = append(im.items, item{line: line, theme: theme})
+}
+
+// checkAnswer は回答の1件を検査する
+func (im *importer) checkAnswer(line int, rec *Record, errs validation.Errors) {
+	notFor(&errs, "回答", map[string]bool{
+		"title":             rec.Title != "",
+		"description":       rec.Description != "",
+		"active":            rec.Active,
+		"opens_at":          rec.OpensAt != nil,
+		"closes_at":         rec.ClosesAt != nil,
+		"submission_status": rec.SubmissionStatus != "",
+		"rejection_reason":  rec.RejectionReason != "",
+	})
+	answer := rec.answer()
+	if !answer.Moderation.Valid() {
+		errs.Add("moderation", response.CodeInvalidValue, "moderation には review, hidden のいずれかを指定してください（公開中なら省略）")
+	}
+	if answer.Likes < 0 {
+		errs.Add("likes", response.CodeInvalidValue, "likes には0以上の数を指定してください")
+	}
+	if answer.ThemeID == "" {
+		errs.Add("theme_id", response.CodeRequired, "theme_id は必須です")
+	} else if !im.themes[answer.ThemeID] {
+		// ファイルの前の行に無いお題は、読み込む先のストアにあれば良い
+		if _, err := im.store.GetTheme(answer.ThemeID); err == data.ErrNotFound {
+			errs.Add("theme_id", response.CodeInvalidValue, fmt.Sprintf("お題 %s がありません（回答より前の行にも、読み込む先にもありません）", answer.ThemeID))
+		} else if err != nil {
+			errs.Add("theme_id", response.CodeInvalidValue, "お題を確認できませんでした: "+err.Error())
+		}
+	}
+	// 回答のIDはお題をまたいで重なってはいけないので、別のお題の回答も確認する
+	im.checkID(&errs, rec.ID, im.ids, func() (bool, error) {
+		return im.store.AnswerExists(rec.ID)
+	})
+	errs.Merge(validation.Answer(answer))
+	if rec.ID != "" {
+		im.ids[rec.ID] = true
+	}
+	if len(errs) > 0 {
+		im.fail(line, errs)
+		return
+	}
+
+	setTimes(&answer.CreatedAt, &answer.UpdatedAt)
+	im.items = append(im.items, item{line: line, answer: answer})
+}
+
+// checkID はIDを検査する
+// IDを残す場合は必須で、ファイルの前の行にも読み込む先のストア（exists で確認する）にも無いこと
+// 振り直す場合も、回答からお題を指すのに使うのでファイルの中で重なってはいけない
+func (im *importer) checkID(errs *validation.Errors, id string, seen map[string]bool, exists func() (bool, error)) {
+	switch {
+	case id == "":
+		if !im.opts.RegenerateIDs {
+			errs.Add("id", response.CodeRequired, "IDを残してインポートする場合、id は必須です")
+		}
+	case seen[id]:
+		errs.Add("id", response.CodeDuplicateID, fmt.Sprintf("%s は前の行にもあります", id))
+	case !im.opts.RegenerateIDs:
+		if found, err := exists(); err != nil {
+			errs.Add("id", response.CodeInvalidValue, "IDを確認できませんでした: "+err.Error())
+		} else if found {
+			errs.Add("id", response.CodeDuplicateID, fmt.Sprintf("%s は既にあります", id))
+		}
+	}
+}
+
+// notFor は type に合わない項目が指定されていればエラーにする
+func notFor(errs *validation.Errors, kind string, fields map[string]bool) {
+	for _, col := range columns {
+		if fields[col.name] {
+			errs.Add(col.name, response.CodeUnknownField, fmt.Sprintf("%s は%sには指定できません", col.name, kind))
+		}
+	}
+}
+
+// setTimes は省略された作成日時をインポートした日時に、更新日時を作成日時にする
+func setTimes(createdAt, updatedAt *time.Time) {
+	if createdAt.IsZero() {
+		*createdAt = time.Now()
+	}
+	if updatedAt.IsZero() {
+		*updatedAt = *createdAt
+	}
+}
+
+// save は検査を終えた全ての件をまとめて保存する（失敗した場合は何も保存しない）
+func (im *importer) save() error {
+	batch := &data.ImportBatch{RegenerateIDs: im.opts.RegenerateIDs}
+	for _, it := range im.items {
+		if it.theme != nil {
+			batch.Themes = append(batch.Themes, it.theme)
+		} else {
+			batch.Answers = append(batch.Answers, it.answer)
+		}
+	}
+	if err := im.store.Import(batch); err != nil {
+		return fmt.Errorf("保存に失敗しました: %w", err)
+	}
+	for _, it := range im.items {
+		im.result.count(it)
+	}
+	return nil
+}
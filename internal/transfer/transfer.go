@@ -0,0 +1,217 @@
+// Package transfer はお題と回答をファイルに書き出し（エクスポート）、別のサーバーに読み込む（インポート）
+// データストアの種類によらず data.DataStore の操作だけを使う
+package transfer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// Format はエクスポート・インポートするファイルの形式
+type Format string
+
+const (
+	FormatJSONL Format = "jsonl" // JSON Lines（1行に1件のJSONオブジェクト）
+	FormatCSV   Format = "csv"   // 1行目が項目名の CSV
+)
+
+// Formats は対応しているファイルの形式の一覧
+var Formats = []Format{FormatJSONL, FormatCSV}
+
+// Valid は対応しているファイルの形式か判定する
+func (f Format) Valid() bool {
+	for _, format := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentType はファイルの形式の Content-Type を返す
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// RecordType はファイルの1件がお題か回答かを表す
+type RecordType string
+
+const (
+	TypeTheme  RecordType = "theme"
+	TypeAnswer RecordType = "answer"
+)
+
+// Record はファイルの1件（お題または回答）
+// お題の項目と回答の項目をまとめて持ち、type に合わない項目は空にする
+type Record struct {
+	Type             RecordType            `json:"type"`
+	ID               string                `json:"id"`
+	ThemeID          string                `json:"theme_id,omitempty"` // 回答のみ
+	Title            string                `json:"title,omitempty"`    // お題のみ
+	Description      string                `json:"description,omitempty"`
+	Content          string                `json:"content,omitempty"` // 回答のみ
+	CreatedAt        time.Time             `json:"created_at"`        // 省略するとインポートした日時
+	UpdatedAt        time.Time             `json:"updated_at"`        // 省略すると created_at と同じ
+	CreatedBy        string                `json:"created_by,omitempty"`
+	UserID           string                `json:"user_id,omitempty"`
+	Active           bool                  `json:"active,omitempty"` // お題のみ
+	OpensAt          *time.Time            `json:"opens_at,omitempty"`
+	ClosesAt         *time.Time            `json:"closes_at,omitempty"`
+	SubmissionStatus data.SubmissionStatus `json:"submission_status,omitempty"` // お題のみ。省略すると承認済み
+	RejectionReason  string                `json:"rejection_reason,omitempty"`
+	Likes            int                   `json:"likes,omitempty"`      // 回答のみ
+	Moderation       data.ModerationState  `json:"moderation,omitempty"` // 回答のみ。省略すると公開中
+}
+
+// column は CSV の列
+// CSV の値は JSON Lines と同じ形にして読み書きする（文字列でない列は JSON の値をそのまま書く）
+// 文字列の列は、数式として扱われる文字で始まれば先頭に ' を付けて書き、読むときに取り除く
+type column struct {
+	name   string
+	quoted bool // JSON では文字列になる列
+}
+
+// columns は CSV の列の順番
+var columns = []column{
+	{"type", true}, {"id", true}, {"theme_id", true},
+	{"title", true}, {"description", true}, {"content", true},
+	{"created_at", true}, {"updated_at", true}, {"created_by", true}, {"user_id", true},
+	{"active", false}, {"opens_at", true}, {"closes_at", true},
+	{"submission_status", true}, {"rejection_reason", true},
+	{"likes", false}, {"moderation", true},
+}
+
+// themeRecord はお題をファイルの1件にする
+func themeRecord(theme *data.Theme) *Record {
+	return &Record{
+		Type:             TypeTheme,
+		ID:               theme.ID,
+		Title:            theme.Title,
+		Description:      theme.Description,
+		CreatedAt:        theme.CreatedAt,
+		UpdatedAt:        theme.UpdatedAt,
+		CreatedBy:        theme.CreatedBy,
+		UserID:           theme.UserID,
+		Active:           theme.Active,
+		OpensAt:          theme.OpensAt,
+		ClosesAt:         theme.ClosesAt,
+		SubmissionStatus: theme.SubmissionStatus,
+		RejectionReason:  theme.RejectionReason,
+	}
+}
+
+// answerRecord は回答をファイルの1件にする
+func answerRecord(answer *data.Answer) *Record {
+	return &Record{
+		Type:       TypeAnswer,
+		ID:         answer.ID,
+		ThemeID:    answer.ThemeID,
+		Content:    answer.Content,
+		CreatedAt:  answer.CreatedAt,
+		UpdatedAt:  answer.UpdatedAt,
+		CreatedBy:  answer.CreatedBy,
+		UserID:     answer.UserID,
+		Likes:      answer.Likes,
+		Moderation: answer.Moderation,
+	}
+}
+
+// theme はファイルの1件からお題を組み立てる
+func (rec *Record) theme() *data.Theme {
+	return &data.Theme{
+		ID:               rec.ID,
+		Title:            rec.Title,
+		Description:      rec.Description,
+		CreatedAt:        rec.CreatedAt,
+		UpdatedAt:        rec.UpdatedAt,
+		CreatedBy:        rec.CreatedBy,
+		UserID:           rec.UserID,
+		Active:           rec.Active,
+		OpensAt:          rec.OpensAt,
+		ClosesAt:         rec.ClosesAt,
+		SubmissionStatus: rec.SubmissionStatus,
+		RejectionReason:  rec.RejectionReason,
+	}
+}
+
+// answer はファイルの1件から回答を組み立てる
+func (rec *Record) answer() *data.Answer {
+	return &data.Answer{
+		ID:         rec.ID,
+		ThemeID:    rec.ThemeID,
+		Content:    rec.Content,
+		CreatedAt:  rec.CreatedAt,
+		UpdatedAt:  rec.UpdatedAt,
+		CreatedBy:  rec.CreatedBy,
+		UserID:     rec.UserID,
+		Likes:      rec.Likes,
+		Moderation: rec.Moderation,
+	}
+}
+
+// csvRow はファイルの1件を columns の順の CSV の1行にする
+func (rec *Record) csvRow() ([]string, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	row := make([]string, len(columns))
+	for i, col := range columns {
+		value, ok := fields[col.name]
+		if !ok {
+			continue
+		}
+		if !col.quoted {
+			row[i] = string(value)
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, err
+		}
+		row[i] = escapeCell(s)
+	}
+	return row, nil
+}
+
+// formulaPrefixes は表計算ソフトがセルを数式として扱う先頭の文字
+const formulaPrefixes = "=+-@\t\r"
+
+// needsEscape は CSV のセルの先頭に ' を付ける必要があるか判定する
+// 数式として扱われる文字で始まる値と、読み込むときに ' を取り除かれてしまう値（' の後がそうなっているもの）に付ける
+func needsEscape(s string) bool {
+	if s == "" {
+		return false
+	}
+	if strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return true
+	}
+	return s[0] == '\'' && needsEscape(s[1:])
+}
+
+// escapeCell は表計算ソフトで開いたときに数式として実行されないよう、必要なら先頭に ' を付ける
+func escapeCell(s string) string {
+	if needsEscape(s) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeCell は escapeCell で付けた ' を取り除く
+func unescapeCell(s string) string {
+	if strings.HasPrefix(s, "'") && needsEscape(s[1:]) {
+		return s[1:]
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+)
+
+// TestCSVFormula は数式として扱われる値を ' を付けて書き出し、読み込むと元に戻ることを確認する
+func TestCSVFormula(t *testing.T) {
+	tests := []struct {
+		content string
+		cell    string // CSV に書き出されるセル
+	}{
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tタブ", "'\tタブ"},
+		{"\r改行", "'\r改行"},
+		{"'=既に付いている", "''=既に付いている"},
+		{"'普通", "'普通"},
+		{"普通の回答", "普通の回答"},
+	}
+	for _, tt := range tests {
+		src := data.NewInMemoryStore()
+		theme := &data.Theme{Title: "お題"}
+		if err := src.CreateTheme(theme); err != nil {
+			t.Fatal(err)
+		}
+		answer := &data.Answer{ThemeID: theme.ID, Content: tt.content}
+		if err := src.CreateAnswer(answer); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if _, err := Export(src, &buf, FormatCSV, Filter{}); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rows[2][5]; got != tt.cell {
+			t.Errorf("%q: セル = %q, want %q", tt.content, got, tt.cell)
+		}
+
+		dst := data.NewInMemoryStore()
+		result, err := Import(dst, &buf, Options{Format: FormatCSV})
+		if err != nil || len(result.Errors) > 0 {
+			t.Fatalf("%q: インポートに失敗しました: %v %+v", tt.content, err, result.Errors)
+		}
+		imported, err := dst.GetAnswer(answer.ID, theme.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported.Content != tt.content {
+			t.Errorf("読み込んだ内容 = %q, want %q", imported.Content, tt.content)
+		}
+	}
+}
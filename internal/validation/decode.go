@@ -0,0 +1,112 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// ErrNotObject はJSONオブジェクトとして読めないことを表す
+var ErrNotObject = errors.New("JSONオブジェクトではありません")
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Decode はJSONオブジェクトを v（構造体へのポインタ）に読み込む
+// v の json タグに無い項目と、型の合わない項目（RFC3339形式でない日時を含む）は全て errs に入れて返し、
+// 読み込める項目はそのまま読み込む
+// JSONオブジェクトとして読めない場合は ErrNotObject を返す
+func Decode(body []byte, v interface{}) (Errors, error) {
+	var raw map[string]json.RawMessage
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if err := dec.Decode(&raw); err != nil || raw == nil {
+		return nil, ErrNotObject
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		// オブジェクトの後ろに余計なデータがある
+		return nil, ErrNotObject
+	}
+
+	target := reflect.ValueOf(v).Elem()
+	fields := jsonFields(target.Type())
+
+	var errs Errors
+	var unknown []string
+	for name := range raw {
+		if _, ok := fields[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs.Add(name, response.CodeUnknownField, fmt.Sprintf("%s は受け付けていない項目です", name))
+	}
+
+	// 項目ごとに読み込み、型の合わない項目を全て報告する
+	for i := 0; i < target.NumField(); i++ {
+		name := jsonName(target.Type().Field(i))
+		value, ok := raw[name]
+		if name == "" || !ok {
+			continue
+		}
+		field := target.Field(i)
+		if err := json.Unmarshal(value, field.Addr().Interface()); err != nil {
+			errs.Add(name, response.CodeInvalidValue, typeMessage(name, field.Type()))
+		}
+	}
+	return errs, nil
+}
+
+// jsonFields は構造体の json タグの名前を返す
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// jsonName は構造体の項目のJSONのキーを返す（JSONに含めない項目は空）
+func jsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// typeMessage は型の合わない項目のメッセージを返す
+func typeMessage(name string, t reflect.Type) string {
+	if o, ok := reflect.Zero(t).Interface().(interface{ valueType() reflect.Type }); ok {
+		t = o.valueType()
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return fmt.Sprintf("%s はRFC3339形式（例: 2024-01-02T15:04:05+09:00）で指定してください", name)
+	case t.Kind() == reflect.String:
+		return fmt.Sprintf("%s には文字列を指定してください", name)
+	case t.Kind() == reflect.Bool:
+		return fmt.Sprintf("%s には true または false を指定してください", name)
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Float64:
+		return fmt.Sprintf("%s には数値を指定してください", name)
+	}
+	return fmt.Sprintf("%s の値が正しくありません", name)
+}
@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Optional は JSON Merge Patch（RFC 7396）の項目
+// 項目が無ければ Set が false のまま（変更しない）、null なら Null が true（消す）、それ以外は Value に値が入る
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON implements json.Unmarshaler（null でも呼ばれる）
+func (o *Optional[T]) UnmarshalJSON(b []byte) error {
+	o.Set = true
+	if string(b) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(b, &o.Value)
+}
+
+// Ptr は null なら nil、それ以外は値へのポインタを返す（省略可能な項目に使う）
+func (o Optional[T]) Ptr() *T {
+	if o.Null {
+		return nil
+	}
+	v := o.Value
+	return &v
+}
+
+// valueType は型の合わない項目のメッセージに使う、中身の型を返す
+func (Optional[T]) valueType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -0,0 +1,128 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// 文字数の上限（バイト数ではなくUnicodeの文字数で数える）
+const (
+	MaxTitleLength       = 100
+	MaxDescriptionLength = 1000
+	MaxContentLength     = 500
+)
+
+// Errors は入力の項目ごとの問題
+// 最初の問題で止めず、全ての問題を集めてまとめて返す
+type Errors []response.Detail
+
+// Add は問題を追加する
+func (e *Errors) Add(field string, code response.Code, message string) {
+	*e = append(*e, response.Detail{Field: field, Code: code, Message: message})
+}
+
+// Has は項目の問題がすでにあるか判定する
+func (e Errors) Has(field string) bool {
+	for _, d := range e {
+		if d.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge は other の問題を追加する
+// 読み込みの段階で問題があった項目は、値を検査しても同じ原因で失敗するだけなので追加しない
+func (e *Errors) Merge(other Errors) {
+	reported := *e
+	for _, d := range other {
+		if !reported.Has(d.Field) {
+			*e = append(*e, d)
+		}
+	}
+}
+
+// Err は問題があれば VALIDATION_FAILED の400エラーを返す（無ければ nil）
+func (e Errors) Err() *response.Error {
+	if len(e) == 0 {
+		return nil
+	}
+	return response.Invalid(response.CodeValidationFailed, e...)
+}
+
+// Text はテキストの項目の規則
+type Text struct {
+	Field     string // 項目名（JSONのキー）
+	Label     string // メッセージで使う項目の名前
+	Required  bool
+	Max       int  // 最大の文字数
+	Multiline bool // 改行とタブを許可する
+}
+
+// テキストの項目の規則
+var (
+	Title       = Text{Field: "title", Label: "タイトル", Required: true, Max: MaxTitleLength}
+	Description = Text{Field: "description", Label: "説明", Max: MaxDescriptionLength, Multiline: true}
+	Content     = Text{Field: "content", Label: "回答内容", Required: true, Max: MaxContentLength, Multiline: true}
+)
+
+// Check は s が規則に合うか検査し、問題を errs に追加する
+func (t Text) Check(errs *Errors, s string) {
+	if s == "" {
+		if t.Required {
+			errs.Add(t.Field, response.CodeRequired, t.Label+"は必須です")
+		}
+		return
+	}
+	if !utf8.ValidString(s) {
+		errs.Add(t.Field, response.CodeInvalidValue, t.Label+"にUTF-8として読めない文字が含まれています")
+		return
+	}
+	if strings.TrimSpace(s) == "" {
+		errs.Add(t.Field, response.CodeBlank, t.Label+"が空白だけです")
+		return
+	}
+	if t.hasControl(s) {
+		errs.Add(t.Field, response.CodeControlChar, t.Label+"に制御文字は使えません")
+	}
+	if n := utf8.RuneCountInString(s); n > t.Max {
+		errs.Add(t.Field, response.CodeTooLong, fmt.Sprintf("%sは%d文字以内で入力してください（%d文字）", t.Label, t.Max, n))
+	}
+}
+
+// hasControl は s に使えない制御文字が含まれているか判定する
+func (t Text) hasControl(s string) bool {
+	for _, r := range s {
+		if !unicode.IsControl(r) {
+			continue
+		}
+		if t.Multiline && (r == '\n' || r == '\r' || r == '\t') {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Theme はお題の入力できる項目を検査する
+func Theme(theme *data.Theme) Errors {
+	var errs Errors
+	Title.Check(&errs, theme.Title)
+	Description.Check(&errs, theme.Description)
+	if theme.OpensAt != nil && theme.ClosesAt != nil && !theme.ClosesAt.After(*theme.OpensAt) {
+		errs.Add("closes_at", response.CodeInvalidWindow, "closes_at は opens_at より後の日時を指定してください")
+	}
+	return errs
+}
+
+// Answer は回答の入力できる項目を検査する
+func Answer(answer *data.Answer) Errors {
+	var errs Errors
+	Content.Check(&errs, answer.Content)
+	return errs
+}
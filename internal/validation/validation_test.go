@@ -0,0 +1,117 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicest414/ogiri-server/internal/data"
+	"github.com/nicest414/ogiri-server/internal/response"
+)
+
+// details は問題を "項目:コード" の並びにする
+func details(errs Errors) []string {
+	got := make([]string, len(errs))
+	for i, d := range errs {
+		got[i] = fmt.Sprintf("%s:%s", d.Field, d.Code)
+	}
+	return got
+}
+
+// TestDecode は受け付けていない項目と型の合わない項目を全て報告し、読み込める項目は読み込むことを確認する
+func TestDecode(t *testing.T) {
+	type input struct {
+		Title   string     `json:"title"`
+		Active  *bool      `json:"active"`
+		OpensAt *time.Time `json:"opens_at"`
+		Count   int        `json:"count"`
+		Ignored string     `json:"-"`
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    []string // "項目:コード" の並び（受け付けていない項目は名前順で先頭）
+		wantErr bool
+	}{
+		{"問題なし", `{"title":"お題","active":true,"opens_at":"2024-01-02T15:04:05+09:00","count":1}`, nil, false},
+		{"受け付けていない項目", `{"title":"お題","zeta":1,"alpha":2,"-":3}`, []string{"-:UNKNOWN_FIELD", "alpha:UNKNOWN_FIELD", "zeta:UNKNOWN_FIELD"}, false},
+		{"型が違う", `{"title":1,"active":"yes","opens_at":"昨日","count":"many"}`, []string{"title:INVALID_VALUE", "active:INVALID_VALUE", "opens_at:INVALID_VALUE", "count:INVALID_VALUE"}, false},
+		{"JSONでない", `{"title":`, nil, true},
+		{"オブジェクトでない", `["title"]`, nil, true},
+		{"null", `null`, nil, true},
+		{"後ろに余計なデータ", `{"title":"お題"} {}`, nil, true},
+	}
+	for _, tt := range tests {
+		var in input
+		errs, err := Decode([]byte(tt.body), &in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if got := details(errs); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 型の合わない項目があっても、他の項目は読み込む
+	var in input
+	if _, err := Decode([]byte(`{"title":"お題","count":"many"}`), &in); err != nil || in.Title != "お題" {
+		t.Errorf("title = %q, %v", in.Title, err)
+	}
+}
+
+// TestText はテキストの項目の規則を確認する
+func TestText(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Text
+		s    string
+		want []string
+	}{
+		{"必須", Title, "", []string{"title:REQUIRED"}},
+		{"任意なら空でよい", Description, "", nil},
+		{"全角スペースだけ", Title, "　 ", []string{"title:BLANK"}},
+		{"上限ちょうど", Title, strings.Repeat("あ", MaxTitleLength), nil},
+		{"上限を超える", Title, strings.Repeat("あ", MaxTitleLength+1), []string{"title:TOO_LONG"}},
+		{"タイトルの改行", Title, "一行目\n二行目", []string{"title:CONTROL_CHARACTER"}},
+		{"説明の改行とタブ", Description, "一行目\n\t二行目", nil},
+		{"回答の制御文字", Content, "a\x00b", []string{"content:CONTROL_CHARACTER"}},
+		{"UTF-8でない", Content, "a\xffb", []string{"content:INVALID_VALUE"}},
+	}
+	for _, tt := range tests {
+		var errs Errors
+		tt.rule.Check(&errs, tt.s)
+		if got := details(errs); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestTheme はお題の全ての問題をまとめて返すことを確認する
+func TestTheme(t *testing.T) {
+	opens := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	closes := opens.Add(-time.Hour)
+	errs := Theme(&data.Theme{Title: " ", Description: strings.Repeat("あ", MaxDescriptionLength+1), OpensAt: &opens, ClosesAt: &closes})
+	want := []string{"title:BLANK", "description:TOO_LONG", "closes_at:INVALID_WINDOW"}
+	if got := details(errs); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if e := errs.Err(); e == nil || e.Code != response.CodeValidationFailed || e.Message != errs[0].Message {
+		t.Errorf("Err() = %+v", e)
+	}
+	if e := Answer(&data.Answer{Content: "回答"}).Err(); e != nil {
+		t.Errorf("問題の無い回答: Err() = %+v", e)
+	}
+}
+
+// TestMerge は読み込みで問題があった項目を、値の検査で重ねて報告しないことを確認する
+func TestMerge(t *testing.T) {
+	var errs Errors
+	errs.Add("title", response.CodeInvalidValue, "title には文字列を指定してください")
+	errs.Merge(Theme(&data.Theme{}))
+	if got := details(errs); fmt.Sprint(got) != fmt.Sprint([]string{"title:INVALID_VALUE"}) {
+		t.Errorf("got %v", got)
+	}
+}
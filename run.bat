@@ -1,4 +1,3 @@
 @echo off
 cd %~dp0
-cd cmd\api
-go run main.go
+go run ./cmd/api %*